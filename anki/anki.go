@@ -0,0 +1,326 @@
+// Package anki builds Anki deck packages (.apkg) that can be imported into Anki desktop, AnkiDroid and AnkiMobile
+package anki
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// modelID identifies the note type. It is constant so that repeated imports reuse the same note type
+	modelID int64 = 1745000000000
+	// deckID identifies the deck. It is constant so that repeated imports add cards to the same deck
+	deckID int64 = 1745000000001
+	// fieldSeparator separates note fields in the notes table
+	fieldSeparator = "\x1f"
+)
+
+// schema is the schema of Anki collection (version 11) that every Anki client can import
+const schema = `
+CREATE TABLE col (
+	id     INTEGER PRIMARY KEY,
+	crt    INTEGER NOT NULL,
+	mod    INTEGER NOT NULL,
+	scm    INTEGER NOT NULL,
+	ver    INTEGER NOT NULL,
+	dty    INTEGER NOT NULL,
+	usn    INTEGER NOT NULL,
+	ls     INTEGER NOT NULL,
+	conf   TEXT    NOT NULL,
+	models TEXT    NOT NULL,
+	decks  TEXT    NOT NULL,
+	dconf  TEXT    NOT NULL,
+	tags   TEXT    NOT NULL
+);
+CREATE TABLE notes (
+	id    INTEGER PRIMARY KEY,
+	guid  TEXT    NOT NULL,
+	mid   INTEGER NOT NULL,
+	mod   INTEGER NOT NULL,
+	usn   INTEGER NOT NULL,
+	tags  TEXT    NOT NULL,
+	flds  TEXT    NOT NULL,
+	sfld  TEXT    NOT NULL,
+	csum  INTEGER NOT NULL,
+	flags INTEGER NOT NULL,
+	data  TEXT    NOT NULL
+);
+CREATE TABLE cards (
+	id     INTEGER PRIMARY KEY,
+	nid    INTEGER NOT NULL,
+	did    INTEGER NOT NULL,
+	ord    INTEGER NOT NULL,
+	mod    INTEGER NOT NULL,
+	usn    INTEGER NOT NULL,
+	type   INTEGER NOT NULL,
+	queue  INTEGER NOT NULL,
+	due    INTEGER NOT NULL,
+	ivl    INTEGER NOT NULL,
+	factor INTEGER NOT NULL,
+	reps   INTEGER NOT NULL,
+	lapses INTEGER NOT NULL,
+	left   INTEGER NOT NULL,
+	odue   INTEGER NOT NULL,
+	odid   INTEGER NOT NULL,
+	flags  INTEGER NOT NULL,
+	data   TEXT    NOT NULL
+);
+CREATE TABLE revlog (
+	id      INTEGER PRIMARY KEY,
+	cid     INTEGER NOT NULL,
+	usn     INTEGER NOT NULL,
+	ease    INTEGER NOT NULL,
+	ivl     INTEGER NOT NULL,
+	lastIvl INTEGER NOT NULL,
+	factor  INTEGER NOT NULL,
+	time    INTEGER NOT NULL,
+	type    INTEGER NOT NULL
+);
+CREATE TABLE graves (
+	usn  INTEGER NOT NULL,
+	oid  INTEGER NOT NULL,
+	type INTEGER NOT NULL
+);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);`
+
+// Note is a single flashcard
+type Note struct {
+	ID          string //Stable id, notes with the same id are updated instead of duplicated on import
+	Sentence    string
+	Translation string
+	Word        string
+	Audio       []byte //mp3 audio of the sentence, may be empty
+}
+
+// Deck is a named list of notes
+type Deck struct {
+	Name  string
+	Notes []Note
+}
+
+// WriteAPKG writes the deck as .apkg package: zip archive with SQLite collection, media index and media files
+func (d *Deck) WriteAPKG(ctx context.Context, w io.Writer) error {
+	//SQLite database has to be written to a file before it can be packed
+	dir, err := os.MkdirTemp("", "anki")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collection.anki2")
+	media, err := d.writeCollection(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	collection, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	//Pack collection and media into the archive
+	zw := zip.NewWriter(w)
+	if err := writeZipFile(zw, "collection.anki2", collection); err != nil {
+		return err
+	}
+
+	index := make(map[string]string, len(media))
+	for i, m := range media {
+		name := strconv.Itoa(i)
+		index[name] = m.name
+		if err := writeZipFile(zw, name, m.data); err != nil {
+			return err
+		}
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "media", indexJSON); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type mediaFile struct {
+	name string
+	data []byte
+}
+
+// writeCollection creates Anki collection at the path, returns media files referenced by the notes
+func (d *Deck) writeCollection(ctx context.Context, path string) ([]mediaFile, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	models, decks, dconf, conf, err := d.collectionJSON(now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Truncate(24*time.Hour).Unix(), now.UnixMilli(), now.UnixMilli(), conf, models, decks, dconf); err != nil {
+		return nil, err
+	}
+
+	var media []mediaFile
+	for i, note := range d.Notes {
+		//Ids only have to be unique within the collection, milliseconds are what Anki itself uses
+		id := now.UnixMilli() + int64(i)
+
+		audio := ""
+		if len(note.Audio) > 0 {
+			name := fmt.Sprintf("wordbuddy_%s.mp3", checksumHex(note.ID+note.Sentence))
+			media = append(media, mediaFile{name: name, data: note.Audio})
+			audio = fmt.Sprintf("[sound:%s]", name)
+		}
+
+		fields := strings.Join([]string{escape(note.Sentence), escape(note.Translation), escape(note.Word), audio}, fieldSeparator)
+		sortField := note.Sentence
+		if _, err := tx.ExecContext(ctx, `INSERT INTO notes VALUES (?, ?, ?, ?, -1, '', ?, ?, ?, 0, '')`,
+			id, guid(note.ID), modelID, now.Unix(), fields, sortField, checksum(sortField)); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			id, id, deckID, now.Unix(), i+1); err != nil {
+			return nil, err
+		}
+	}
+	return media, tx.Commit()
+}
+
+// collectionJSON returns JSON columns of the col table
+func (d *Deck) collectionJSON(now time.Time) (models, decks, dconf, conf string, err error) {
+	model := map[string]any{
+		"id":    modelID,
+		"name":  "WordBuddy Sentence",
+		"type":  0,
+		"mod":   now.Unix(),
+		"usn":   -1,
+		"sortf": 0,
+		"did":   deckID,
+		"tmpls": []map[string]any{{
+			"name":  "Sentence",
+			"ord":   0,
+			"qfmt":  `<div class="sentence">{{Sentence}}</div>{{Audio}}`,
+			"afmt":  `{{FrontSide}}<hr id="answer"><div class="translation">{{Translation}}</div><div class="word">{{Word}}</div>`,
+			"did":   nil,
+			"bqfmt": "",
+			"bafmt": "",
+		}},
+		"flds": []map[string]any{
+			field("Sentence", 0), field("Translation", 1), field("Word", 2), field("Audio", 3),
+		},
+		"css": `.card { font-family: arial; font-size: 22px; text-align: center; color: black; background-color: white; }
+.translation { color: #555; }
+.word { margin-top: 12px; font-weight: bold; }`,
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"latexsvg":  false,
+		"tags":      []string{},
+		"vers":      []any{},
+		"req":       []any{[]any{0, "any", []int{0}}},
+	}
+	deck := func(id int64, name string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "mod": now.Unix(), "usn": -1, "desc": "", "dyn": 0, "conf": 1,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 0, "extendRev": 0,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	deckConf := map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new":   map[string]any{"bury": true, "delays": []int{1, 10}, "initialFactor": 2500, "ints": []int{1, 4, 7}, "order": 1, "perDay": 20, "separate": true},
+		"lapse": map[string]any{"delays": []int{10}, "leechAction": 0, "leechFails": 8, "minInt": 1, "mult": 0},
+		"rev":   map[string]any{"bury": true, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500, "minSpace": 1, "perDay": 100},
+	}
+	collectionConf := map[string]any{
+		"activeDecks": []int64{deckID}, "curDeck": deckID, "newSpread": 0, "collapseTime": 1200, "timeLim": 0,
+		"estTimes": true, "dueCounts": true, "curModel": modelID, "nextPos": len(d.Notes) + 1, "sortType": "noteFld",
+		"sortBackwards": false, "addToCur": true,
+	}
+
+	for _, v := range []struct {
+		dst *string
+		src any
+	}{
+		{&models, map[string]any{strconv.FormatInt(modelID, 10): model}},
+		{&decks, map[string]any{"1": deck(1, "Default"), strconv.FormatInt(deckID, 10): deck(deckID, d.Name)}},
+		{&dconf, map[string]any{"1": deckConf}},
+		{&conf, collectionConf},
+	} {
+		data, err := json.Marshal(v.src)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		*v.dst = string(data)
+	}
+	return models, decks, dconf, conf, nil
+}
+
+// field returns description of the note type field
+func field(name string, ord int) map[string]any {
+	return map[string]any{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+}
+
+// guid returns stable note guid derived from the note id
+func guid(id string) string {
+	return checksumHex("wordbuddy" + id)[:10]
+}
+
+// checksum returns first 8 hex digits of sha1 of the text as a number, the way Anki checksums sort fields
+func checksum(text string) int64 {
+	sum := sha1.Sum([]byte(text))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+func checksumHex(text string) string {
+	sum := sha1.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// escape escapes html special characters because Anki fields are html
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,103 @@
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readZip returns contents of every file in the archive by name
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("error opening archive: %v", err)
+	}
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %v", f.Name, err)
+		}
+		files[f.Name], err = io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", f.Name, err)
+		}
+		_ = r.Close()
+	}
+	return files
+}
+
+func TestWriteAPKG(t *testing.T) {
+	deck := &Deck{Name: "WordBuddy", Notes: []Note{
+		{ID: "1", Sentence: "Hola, <b>amigo</b>.", Translation: "Hello, friend.", Word: "amigo", Audio: []byte("mp3")},
+		{ID: "2", Sentence: "Mi casa.", Translation: "My house.", Word: "casa"},
+	}}
+	var buf bytes.Buffer
+	if err := deck.WriteAPKG(context.Background(), &buf); err != nil {
+		t.Fatalf("error writing deck: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+
+	//Only the first note has audio
+	var media map[string]string
+	if err := json.Unmarshal(files["media"], &media); err != nil {
+		t.Fatalf("error reading media index: %v", err)
+	}
+	if len(media) != 1 || string(files["0"]) != "mp3" {
+		t.Fatalf("media = %v", media)
+	}
+
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(path, files["collection.anki2"], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("error opening collection: %v", err)
+	}
+	defer db.Close()
+
+	var decks string
+	if err := db.QueryRow(`SELECT decks FROM col`).Scan(&decks); err != nil {
+		t.Fatalf("error reading collection: %v", err)
+	}
+	if !strings.Contains(decks, `"WordBuddy"`) {
+		t.Errorf("decks = %s", decks)
+	}
+
+	rows, err := db.Query(`SELECT flds FROM notes ORDER BY id`)
+	if err != nil {
+		t.Fatalf("error reading notes: %v", err)
+	}
+	defer rows.Close()
+	var notes [][]string
+	for rows.Next() {
+		var flds string
+		if err := rows.Scan(&flds); err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, strings.Split(flds, fieldSeparator))
+	}
+	if len(notes) != 2 {
+		t.Fatalf("collection has %d notes", len(notes))
+	}
+	if notes[0][0] != "Hola, &lt;b&gt;amigo&lt;/b&gt;." || notes[0][3] != "[sound:"+media["0"]+"]" {
+		t.Errorf("first note = %q", notes[0])
+	}
+	if notes[1][2] != "casa" || notes[1][3] != "" {
+		t.Errorf("second note = %q", notes[1])
+	}
+
+	var cards int
+	if err := db.QueryRow(`SELECT count(*) FROM cards`).Scan(&cards); err != nil || cards != 2 {
+		t.Fatalf("collection has %d cards, %v", cards, err)
+	}
+}
@@ -8,57 +8,100 @@ import (
 	"github.com/dafraer/sentence-gen-tg-bot/tts"
 	"go.uber.org/zap"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dafraer/sentence-gen-tg-bot/db"
-	"github.com/dafraer/sentence-gen-tg-bot/gemini"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/dafraer/sentence-gen-tg-bot/quota"
+	"github.com/dafraer/sentence-gen-tg-bot/ratelimit"
 	"github.com/go-telegram/bot"
 	tgbotapi "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
 const (
-	premiumCallback     = "premium"
-	geminiProModel      = "gemini-2.5-pro-preview-03-25"
-	freeSentencesAmount = 50
-	premiumPrice        = 100 //Premium subscription price in Telegram Stars
-	english             = "en"
-	russian             = "ru"
-	maxMessageLen       = 100 //bytes
+	english         = "en"
+	russian         = "ru"
+	maxMessageLen   = 100 //bytes
+	maxExportCards  = 1000
+	historyPageSize = 5
+	drainTimeout    = 30 * time.Second //How long to wait for updates being processed on shutdown
 )
 
+// Config contains telegram bot token and business settings of the bot
+type Config struct {
+	Token             string           `yaml:"token"`              //Telegram bot token
+	Quota             quota.Policy     `yaml:"quota"`              //Free sentences users get every day
+	Plans             []Plan           `yaml:"plans"`              //Premium plans users can choose from
+	SubscriptionPrice int              `yaml:"subscription_price"` //Monthly price of the recurring subscription in Telegram Stars, zero disables it
+	Referral          db.ReferralBonus `yaml:"referral"`           //Bonus both the invited user and the referrer get after the first sentence of the invited user
+	TrialDays         int              `yaml:"trial_days"`         //Length of the one-time premium trial of new users, zero disables it
+	Workers           int              `yaml:"workers"`            //Max amount of updates processed at the same time
+	QueueSize         int              `yaml:"queue_size"`         //Max amount of updates of a single user waiting to be processed
+	Admins            []int64          `yaml:"admins"`             //Chat ids of users allowed to use admin commands
+	RateLimit         ratelimit.Config `yaml:"rate_limit"`         //How often free and premium users can send updates and when flooding users are banned
+}
+
 type Bot struct {
-	b            *tgbotapi.Bot
-	store        *db.Store
-	geminiClient *gemini.Client
-	tts          *tts.Client
-	messages     *text.Messages
-	logger       *zap.SugaredLogger
+	cfg        Config
+	b          *tgbotapi.Bot
+	store      db.Store
+	generator  generator.SentenceGenerator
+	tts        *tts.Client
+	messages   *text.Messages
+	logger     *zap.SugaredLogger
+	dispatcher *dispatcher
+	scheduler  *scheduler
+	router     *callbackRouter
+	limiter    *ratelimit.Limiter
+	busyNotes  *ratelimit.Notifier //Limits how often users whose queue is full are asked to try again later
+	banNotes   *ratelimit.Notifier //Limits how often banned users are reminded of the ban
+
+	admission   []Middleware //Run for every update before it is queued, they can reject the update before it takes a place in the queue
+	middlewares []Middleware //Run for every update before it is handled
+
+	subscriptionMu    sync.Mutex
+	subscriptionLinks map[string]string //Invoice links of the subscription by interface language
+
+	usernameMu sync.Mutex
+	username   string //Username of the bot, fetched when it is needed for the first time
 }
 
-// New creates a new bot
-func New(token string, store *db.Store, geminiClient *gemini.Client, ttsClient *tts.Client, messages *text.Messages, logger *zap.SugaredLogger) (*Bot, error) {
+// New creates a new bot. Options are passed to the underlying telegram bot (e.g. to use a different Bot API server)
+func New(cfg Config, store db.Store, generator generator.SentenceGenerator, ttsClient *tts.Client, messages *text.Messages, logger *zap.SugaredLogger, opts ...tgbotapi.Option) (*Bot, error) {
 	//Create bot using provided dependencies
-	bot := &Bot{store: store, geminiClient: geminiClient, tts: ttsClient, messages: messages, logger: logger}
+	bot := &Bot{cfg: cfg, store: store, generator: generator, tts: ttsClient, messages: messages, logger: logger, dispatcher: newDispatcher(cfg.Workers, cfg.QueueSize), subscriptionLinks: make(map[string]string)}
 
 	//Create telegram bot with a default handler
-	b, err := tgbotapi.New(token, tgbotapi.WithDefaultHandler(bot.defaultHandler))
+	b, err := tgbotapi.New(cfg.Token, append([]tgbotapi.Option{tgbotapi.WithDefaultHandler(bot.defaultHandler)}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
 	bot.b = b
+	bot.router = bot.callbacks()
+	bot.limiter = ratelimit.New(cfg.RateLimit)
+	bot.busyNotes = ratelimit.NewNotifier()
+	bot.banNotes = ratelimit.NewNotifier()
+	bot.admission = []Middleware{bot.withRequestID, bot.checkBan, bot.limitRate}
+	bot.middlewares = []Middleware{bot.recoverPanic, bot.logLatency}
+	bot.scheduler = newScheduler(store, logger, job{name: premiumRemindersJob, interval: premiumRemindersInterval, run: bot.remindPremium})
 	return bot, nil
 }
 
 // Run runs the bot using long polling
 func (b *Bot) Run(ctx context.Context) {
+	b.scheduler.start(ctx)
 	b.b.Start(ctx)
+	b.drain()
 }
 
 // RunWebhook runs bot using webhook
 func (b *Bot) RunWebhook(ctx context.Context, address string) error {
-	//delete webhook before shutdown
+	//delete webhook and finish processing updates before shutdown
+	defer b.drain()
 	defer func() {
 		if _, err := b.b.DeleteWebhook(context.Background(), &tgbotapi.DeleteWebhookParams{DropPendingUpdates: true}); err != nil {
 			panic(err)
@@ -66,6 +109,11 @@ func (b *Bot) RunWebhook(ctx context.Context, address string) error {
 	}()
 	go b.b.StartWebhook(ctx)
 
+	//Stop background jobs when the server fails as well
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	b.scheduler.start(ctx)
+
 	//Set tup server for the webhook
 	//Create http server for the webhook
 	srv := &http.Server{
@@ -102,8 +150,70 @@ func (b *Bot) RunWebhook(ctx context.Context, address string) error {
 	return nil
 }
 
-// defaultHandler routes request to the bot
+// drain waits until updates that are already received are processed and background jobs stop
+func (b *Bot) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := b.dispatcher.drain(ctx); err != nil {
+		b.log(ctx).Errorw("error draining updates", "error", err)
+	}
+	b.scheduler.wait()
+}
+
+// HandleUpdate processes a single update the same way updates received from telegram are processed
+// and waits until it is processed
+func (b *Bot) HandleUpdate(ctx context.Context, update *models.Update) {
+	<-b.dispatch(ctx, update)
+}
+
+// defaultHandler queues update to be processed by the dispatcher
 func (b *Bot) defaultHandler(ctx context.Context, _ *bot.Bot, update *models.Update) {
+	b.dispatch(ctx, update)
+}
+
+// dispatch queues update of the user, returned channel is closed when the update is processed.
+// Banned users and users sending updates too fast are rejected before their updates are queued, so updates
+// rejected because the queue is full count against the user as well.
+// If too many updates of the user are waiting, user is asked to try again later at most once a minute
+func (b *Bot) dispatch(ctx context.Context, update *models.Update) <-chan struct{} {
+	done := make(chan struct{})
+	handle := chain(b.handleUpdate, b.middlewares)
+	queued := false
+	enqueue := func(ctx context.Context, update *models.Update) {
+		//Pre checkout query must be answered within 10 seconds, so it is not queued
+		from := sender(update)
+		if from == nil || update.PreCheckoutQuery != nil {
+			handle(ctx, update)
+			return
+		}
+
+		//Update is processed even if the bot is stopped while the update waits in the queue
+		ctx = context.WithoutCancel(ctx)
+		queued = b.dispatcher.submit(from.ID, func() {
+			defer close(done)
+			handle(ctx, update)
+		})
+		if queued {
+			return
+		}
+		b.log(ctx).Infow("Update rejected, user's queue is full", "from", from.Username)
+		if b.busyNotes.Allow(from.ID, time.Now()) {
+			b.reject(ctx, update, b.messages.Busy[language(from)])
+		} else if update.CallbackQuery != nil {
+			//Stop the progress shown on the button
+			b.reject(ctx, update, "")
+		}
+	}
+
+	chain(enqueue, b.admission)(ctx, update)
+	if !queued {
+		close(done)
+	}
+	return done
+}
+
+// handleUpdate routes request to the bot
+func (b *Bot) handleUpdate(ctx context.Context, update *models.Update) {
 	//Check if the update is a preCheckoutQuery, callbackQuery or message
 	switch {
 	case update.PreCheckoutQuery != nil:
@@ -113,15 +223,17 @@ func (b *Bot) defaultHandler(ctx context.Context, _ *bot.Bot, update *models.Upd
 	case update.Message != nil:
 		//Check if the message is successful payment, command or just a message
 		switch {
+		case update.Message.RefundedPayment != nil:
+			b.processRefundedPayment(ctx, update)
 		case update.Message.SuccessfulPayment != nil:
 			if err := b.processSuccessfulPayment(ctx, update); err != nil {
 				//If we can't process successful payment send user message about it
-				b.logger.Errorw("error processing successful payment", "error", err)
+				b.log(ctx).Errorw("error processing successful payment", "error", err)
 				if _, err := b.b.SendMessage(ctx, &bot.SendMessageParams{
 					ChatID: update.Message.Chat.ID,
 					Text:   b.messages.FailedPayment[language(update.Message.From)],
 				}); err != nil {
-					b.logger.Errorw("error sending message", "error", err)
+					b.log(ctx).Errorw("error sending message", "error", err)
 				}
 			}
 		case strings.HasPrefix(update.Message.Text, "/"):
@@ -132,65 +244,155 @@ func (b *Bot) defaultHandler(ctx context.Context, _ *bot.Bot, update *models.Upd
 	}
 }
 
-// sendInvoice sends invoice for premium subscription to the user
-func (b *Bot) sendInvoice(ctx context.Context, user *models.User, title, desc string) error {
+// sendInvoice sends invoice for the premium plan with the payload to the user
+func (b *Bot) sendInvoice(ctx context.Context, user *models.User, plan Plan, payload string) error {
+	lang := language(user)
 	_, err := b.b.SendInvoice(ctx, &tgbotapi.SendInvoiceParams{
 		ChatID:      user.ID,
-		Title:       title,
-		Description: desc,
+		Title:       b.messages.PlanTitle[lang](plan.Days),
+		Description: b.messages.PremiumDescription[lang],
 		Currency:    "XTR", // Telegram stars
-		Payload:     "premium",
+		Payload:     payload,
 		Prices: []models.LabeledPrice{
 			{
-				Label:  b.messages.Premium[language(user)],
-				Amount: premiumPrice,
+				Label:  b.messages.PlanTitle[lang](plan.Days),
+				Amount: plan.Price,
 			},
 		},
 	})
 	return err
 }
 
-// processPreCheckoutQuery process pre checkout query that is sent to us to confirm payment
+// processPreCheckoutQuery process pre checkout query that is sent to us to confirm payment.
+// Payment is rejected before stars are charged if the invoice does not match the current plans or user can't buy premium
 func (b *Bot) processPreCheckoutQuery(ctx context.Context, update *models.Update) {
+	errorMessage := b.checkPreCheckoutQuery(ctx, update.PreCheckoutQuery)
+	if errorMessage != "" {
+		b.log(ctx).Infow("Pre checkout query rejected", "from", update.PreCheckoutQuery.From.ID, "payload", update.PreCheckoutQuery.InvoicePayload, "amount", update.PreCheckoutQuery.TotalAmount)
+	}
 	_, err := b.b.AnswerPreCheckoutQuery(ctx, &bot.AnswerPreCheckoutQueryParams{
 		PreCheckoutQueryID: update.PreCheckoutQuery.ID,
-		OK:                 true,
-		ErrorMessage:       "",
+		OK:                 errorMessage == "",
+		ErrorMessage:       errorMessage,
 	})
 	if err != nil {
-		b.logger.Errorw("error processing pre checkout query", "error", err)
+		b.log(ctx).Errorw("error processing pre checkout query", "error", err)
 	}
 }
 
+// checkPreCheckoutQuery returns message explaining why the payment can't be accepted or empty string if it can
+func (b *Bot) checkPreCheckoutQuery(ctx context.Context, query *models.PreCheckoutQuery) string {
+	lang := language(query.From)
+
+	//The invoice must be for a plan from the current catalog with its current price.
+	//Invoices sent before the catalog was introduced are accepted with the price they were sent with
+	plan, recipientId, ok := b.parsePayload(query.InvoicePayload)
+	if !ok || query.Currency != "XTR" || query.TotalAmount != plan.Price {
+		return b.messages.InvoiceOutdated[lang]
+	}
+
+	//Gifts can only be given to users of the bot who are allowed to use it
+	if recipientId != 0 {
+		recipient, err := b.store.GetUser(ctx, recipientId)
+		if errors.Is(err, db.ErrUserNotFound) {
+			return b.messages.GiftRecipientNotFound[lang]
+		}
+		if err != nil {
+			b.log(ctx).Errorw("error getting user from the database", "error", err)
+			return b.messages.FailedPayment[lang]
+		}
+		if banned(recipient) {
+			return b.messages.GiftRecipientNotFound[lang]
+		}
+	}
+
+	//Premium is given to the stored user, so they must exist and be allowed to use the bot
+	user, err := b.store.GetUser(ctx, query.From.ID)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return b.messages.PaymentUserNotFound[lang]
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return b.messages.FailedPayment[lang]
+	}
+	if banned(user) {
+		return b.messages.PaymentBanned[lang]
+	}
+	return ""
+}
+
 // processSuccessfulPayment gives user premium and sends them a message saying that payment has been successful
 func (b *Bot) processSuccessfulPayment(ctx context.Context, update *models.Update) error {
-	//Get user from the database
-	user, err := b.store.GetUser(ctx, update.Message.Chat.ID)
+	payment := update.Message.SuccessfulPayment
+	plan, recipient, ok := b.parsePayload(payment.InvoicePayload)
+	if !ok {
+		//Stars have already been charged, user is asked to contact support and can be refunded by an admin
+		return fmt.Errorf("payment %s for unknown plan %q", payment.TelegramPaymentChargeID, payment.InvoicePayload)
+	}
+
+	//Record the payment and add plan's duration to user's premium.
+	//Premium period starts from the last day of user's premium if they still have it
+	err := b.store.ApplyPayment(ctx, &db.Payment{
+		ChargeID:         payment.TelegramPaymentChargeID,
+		ProviderChargeID: payment.ProviderPaymentChargeID,
+		ChatId:           update.Message.Chat.ID,
+		Recipient:        recipient,
+		Amount:           payment.TotalAmount,
+		Currency:         payment.Currency,
+		Payload:          payment.InvoicePayload,
+		CreatedAt:        time.Now().Unix(),
+		Duration:         int64(plan.Duration() / time.Second),
+		Lifetime:         plan.Days == 0,
+	})
+	if errors.Is(err, db.ErrDuplicatePayment) {
+		//Telegram delivered the same update again, premium has already been given for it
+		b.log(ctx).Infow("Duplicate payment skipped", "charge id", payment.TelegramPaymentChargeID)
+		return nil
+	}
 	if err != nil {
-		b.logger.Errorw("error getting user from the database", "error", err)
+		b.log(ctx).Errorw("error applying payment", "error", err)
 		return err
 	}
 
-	//from is a time from which we start the 30 days premium period. It is set to the last day of user's premium if they still have it
-	from := max(user.PremiumUntil, time.Now().Unix())
+	//Both the payer and the recipient of the gift are notified
+	if recipient != 0 {
+		b.notifyGift(ctx, update, plan, recipient)
+		return nil
+	}
 
-	//Add 30 days to user's premium
-	if err := b.store.UpdateUserPremium(ctx, update.Message.Chat.ID, time.Unix(from, 0).Add(time.Hour*24*30).Unix()); err != nil {
-		b.logger.Errorw("error updating user's premium", "error", err)
-		return err
+	//Subscription payments also renew the subscription
+	if payment.InvoicePayload == subscriptionPayload {
+		if err := b.processSubscriptionPayment(ctx, update); err != nil {
+			b.log(ctx).Errorw("error processing subscription payment", "error", err)
+		}
+		return nil
 	}
 
 	//Send message to the user saying that payment has been successful
 	_, err = b.b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
-		Text:   b.messages.SuccessfulPayment[language(update.Message.From)],
+		Text:   b.messages.SuccessfulPayment[language(update.Message.From)](plan.Days),
 	})
 	if err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 	return err
 }
 
+// deepLink returns link starting the bot with the parameter
+func (b *Bot) deepLink(ctx context.Context, parameter string) (string, error) {
+	b.usernameMu.Lock()
+	defer b.usernameMu.Unlock()
+	if b.username == "" {
+		me, err := b.b.GetMe(ctx)
+		if err != nil {
+			return "", err
+		}
+		b.username = me.Username
+	}
+	return "https://t.me/" + b.username + "?start=" + parameter, nil
+}
+
 // Returns user's language code if its russian or english, else returns english
 func language(user *models.User) string {
 	switch user.LanguageCode {
@@ -201,39 +403,77 @@ func language(user *models.User) string {
 	}
 }
 
+// storedLanguage returns language of the stored user the same way language does for telegram user
+func storedLanguage(user *db.User) string {
+	return language(&models.User{LanguageCode: user.Language})
+}
+
+// isAdmin returns true if user is allowed to use admin commands
+func (b *Bot) isAdmin(chatId int64) bool {
+	return slices.Contains(b.cfg.Admins, chatId)
+}
+
+// levels are language levels users can choose from
+var levels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
 // levelsMarkup returns inline keyboard markup for selecting language level
 func levelsMarkup() *models.InlineKeyboardMarkup {
-	return &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: "A1", CallbackData: "A1"},
-			}, {
-				{Text: "A2", CallbackData: "A2"},
-			}, {
-				{Text: "B1", CallbackData: "B1"},
-			}, {
-				{Text: "B2", CallbackData: "B2"},
-			}, {
-				{Text: "C1", CallbackData: "C1"},
-			}, {
-				{Text: "C2", CallbackData: "C2"},
-			},
-		},
+	markup := &models.InlineKeyboardMarkup{}
+	for _, level := range levels {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{{Text: level, CallbackData: levelCallback.data(level)}})
+	}
+	return markup
+}
+
+// languagesMarkup returns inline keyboard markup for selecting language of the sentences.
+// Buttons of the language markup of the messages contain bare language codes, they are turned into language callbacks
+func (b *Bot) languagesMarkup(lang string) *models.InlineKeyboardMarkup {
+	markup := &models.InlineKeyboardMarkup{}
+	for _, row := range b.messages.LanguageMarkup[lang].InlineKeyboard {
+		buttons := make([]models.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			buttons = append(buttons, models.InlineKeyboardButton{Text: button.Text, CallbackData: languageCallback.data(button.CallbackData)})
+		}
+		markup.InlineKeyboard = append(markup.InlineKeyboard, buttons)
 	}
+	return markup
 }
 
-// premium returns true if user is still premium
+// sentenceLanguage returns true if the language code is one of the languages users can choose from
+func (b *Bot) sentenceLanguage(code string) bool {
+	for _, markup := range b.messages.LanguageMarkup {
+		for _, row := range markup.InlineKeyboard {
+			for _, button := range row {
+				if button.CallbackData == code {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// banned returns true if user is not allowed to use the bot
+func banned(user *db.User) bool {
+	return user.BannedUntil > time.Now().Unix()
+}
+
+// premium returns true if user is still premium, either paid or on trial
 func premium(user *db.User) bool {
+	return paidPremium(user) || trial(user)
+}
+
+// paidPremium returns true if user's paid premium has not ended
+func paidPremium(user *db.User) bool {
 	return !time.Unix(user.PremiumUntil, 0).Before(time.Now())
 }
 
-// parseSentences parses gemini response into 2 sentences, returns error if fails
-func parseSentences(resp string) (string, string, error) {
-	//First sentence is in target language second is in user's language
-	sentences := strings.Split(resp, ";")
-	//Check if the sentences were not generated
-	if len(sentences) < 2 {
-		return "", "", errors.New(fmt.Sprintf("error parsing gemini response into sentences. Gemini repsonse: %s", resp))
-	}
-	return sentences[0], sentences[1], nil
+// trial returns true if user's premium trial has not ended
+func trial(user *db.User) bool {
+	return user.TrialUntil > time.Now().Unix()
+}
+
+// daysLeft returns amount of days left until the unix time rounded upwards
+func daysLeft(until int64) int {
+	return (int(time.Unix(until, 0).Sub(time.Now()).Hours()) + 23) / 24
 }
@@ -0,0 +1,345 @@
+package bot_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/dafraer/sentence-gen-tg-bot/ratelimit"
+	"github.com/go-telegram/bot/models"
+)
+
+// setUp starts the bot for the user and chooses Spanish at A1 level in /preferences
+func setUp(t *testing.T, h *bottest.Harness, user *models.User) {
+	t.Helper()
+	h.SendText(user, "/start")
+	h.SendText(user, "/preferences")
+	h.PressButton(user, h.LastCall("sendMessage").MessageID, "lang:1:es-ES")
+	h.PressButton(user, h.LastCall("sendMessage").MessageID, "level:1:A1")
+}
+
+// pay sends the update telegram sends after the user paid the invoice
+func pay(h *bottest.Harness, user *models.User, payload, chargeId string) {
+	h.Send(&models.Update{Message: &models.Message{
+		From: user,
+		Chat: models.Chat{ID: user.ID, Type: models.ChatTypePrivate},
+		SuccessfulPayment: &models.SuccessfulPayment{
+			Currency:                "XTR",
+			TotalAmount:             100,
+			InvoicePayload:          payload,
+			TelegramPaymentChargeID: chargeId,
+		},
+	}})
+}
+
+// getUser returns the user from the store of the harness or fails the test
+func getUser(t *testing.T, h *bottest.Harness, chatId int64) *db.User {
+	t.Helper()
+	user, err := h.Store.GetUser(context.Background(), chatId)
+	if err != nil {
+		t.Fatalf("error getting user: %v", err)
+	}
+	return user
+}
+
+// keepBusy sends a word from the user whose sentence is generated until the test finishes, so user's queue stays full
+func keepBusy(t *testing.T, h *bottest.Harness, user *models.User) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		close(started)
+		<-release
+		return &generator.Sentences{Sentence: "Hola, amigo.", Translation: "Hello, friend."}, nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.SendText(user, "amigo")
+	}()
+	<-started
+	t.Cleanup(func() {
+		close(release)
+		<-done
+	})
+}
+
+func TestConversation(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+
+	h.SendText(user, "/start")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.Start["en"] {
+		t.Fatalf("/start replied with %q", got)
+	}
+
+	h.SendText(user, "/preferences")
+	languages := h.LastCall("sendMessage")
+	if !strings.Contains(languages.Params["reply_markup"], `"lang:1:es-ES"`) {
+		t.Fatalf("/preferences has no Spanish button: %s", languages.Params["reply_markup"])
+	}
+
+	h.PressButton(user, languages.MessageID, "lang:1:es-ES")
+	levels := h.LastCall("sendMessage")
+	if !strings.Contains(levels.Params["reply_markup"], `"level:1:A1"`) {
+		t.Fatalf("choosing language did not ask for the level: %s", levels.Params["reply_markup"])
+	}
+
+	h.PressButton(user, levels.MessageID, "level:1:A1")
+	stored := getUser(t, h, user.ID)
+	if !stored.PreferencesSet || stored.SentenceLanguage != "es-ES" || stored.Level != "A1" {
+		t.Fatalf("preferences were not saved: %+v", stored)
+	}
+
+	h.Server.Reset()
+	h.SendText(user, "amigo")
+	sentence := h.LastCall("sendMessage")
+	if !strings.Contains(sentence.Params["text"], `Hola, amigo\.`) || !strings.Contains(sentence.Params["text"], `Hello, friend\.`) {
+		t.Fatalf("sentence message is %q", sentence.Params["text"])
+	}
+	if audio := h.LastCall("sendDocument"); len(audio.Files) != 1 {
+		t.Fatalf("audio was sent with %d files", len(audio.Files))
+	}
+	markup := h.LastCall("editMessageReplyMarkup")
+	if markup.Params["message_id"] != strconv.Itoa(sentence.MessageID) || !strings.Contains(markup.Params["reply_markup"], `"result:1:regen:`) {
+		t.Fatalf("follow-up buttons were not attached: %v", markup.Params)
+	}
+	if prompts := h.Generator.Prompts(); len(prompts) != 1 || !strings.Contains(prompts[0], "amigo") {
+		t.Fatalf("generator got prompts %q", prompts)
+	}
+
+	h.Server.Reset()
+	h.SendText(user, "/export")
+	deck := h.LastCall("sendDocument")
+	if deck.Params["caption"] != h.Messages.Export["en"] {
+		t.Fatalf("deck was sent with caption %q", deck.Params["caption"])
+	}
+	if f := deck.Files["document"]; !strings.HasSuffix(f.Name, ".apkg") || len(f.Data) == 0 {
+		t.Fatalf("deck file is %q of %d bytes", f.Name, len(f.Data))
+	}
+}
+
+func TestSentenceEscaped(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		return &generator.Sentences{Sentence: "¡Hola, amigo! (`x`)", Translation: "Hi, friend_1 - 2*3."}, nil
+	})
+
+	h.SendText(user, "amigo")
+	text := h.LastCall("sendMessage").Params["text"]
+	for _, want := range []string{"¡Hola, amigo\\! \\(\\`x\\`\\)", "Hi, friend\\_1 \\- 2\\*3\\."} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("sentence message %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestExportNothing(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	h.SendText(user, "/export")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.NothingToExport["en"] {
+		t.Fatalf("/export without cards replied with %q", got)
+	}
+	if len(h.Server.Calls("sendDocument")) != 0 {
+		t.Fatal("empty deck was sent")
+	}
+}
+
+func TestPreferencesNotSet(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	h.SendText(user, "/start")
+	h.SendText(user, "amigo")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.PreferencesNotSet["en"] {
+		t.Fatalf("word before /preferences was answered with %q", got)
+	}
+	if len(h.Generator.Prompts()) != 0 {
+		t.Fatal("sentence was generated before /preferences")
+	}
+}
+
+func TestQuota(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.Quota.DailyAllowance = 2
+	h := bottest.NewWithConfig(t, cfg)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	//Sentence that could not be generated is refunded
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		return nil, errors.New("generator is down")
+	})
+	h.SendText(user, "amigo")
+	if got := getUser(t, h, user.ID).FreeSentences; got != 2 {
+		t.Fatalf("failed generation left %d free sentences, want 2", got)
+	}
+
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		return &generator.Sentences{Sentence: "Hola, amigo.", Translation: "Hello, friend."}, nil
+	})
+	h.SendText(user, "amigo")
+	h.SendText(user, "casa")
+	if got := getUser(t, h, user.ID).FreeSentences; got != 0 {
+		t.Fatalf("%d free sentences left after using the allowance", got)
+	}
+
+	h.Server.Reset()
+	h.SendText(user, "perro")
+	limit := h.LastCall("sendMessage")
+	if !strings.Contains(limit.Params["reply_markup"], `"premium:1"`) {
+		t.Fatalf("limit message has no premium button: %v", limit.Params)
+	}
+	if len(h.Server.Calls("sendDocument")) != 0 {
+		t.Fatal("sentence was sent after the limit was reached")
+	}
+	if got := len(h.Generator.Prompts()); got != 3 {
+		t.Fatalf("generator was called %d times, want 3", got)
+	}
+}
+
+func TestPaymentAppliedOnce(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	h.Server.Reset()
+
+	pay(h, user, "plan:week", "charge-1")
+	first := getUser(t, h, user.ID).PremiumUntil
+	if left := time.Until(time.Unix(first, 0)); left < 6*24*time.Hour || left > 7*24*time.Hour {
+		t.Fatalf("week plan gave %v of premium", left)
+	}
+
+	//Telegram can deliver the same payment twice
+	pay(h, user, "plan:week", "charge-1")
+	if got := getUser(t, h, user.ID).PremiumUntil; got != first {
+		t.Fatalf("duplicate payment extended premium by %v", time.Duration(got-first)*time.Second)
+	}
+	if got := len(h.Server.Calls("sendMessage")); got != 1 {
+		t.Fatalf("user got %d messages for the same payment", got)
+	}
+
+	pay(h, user, "plan:week", "charge-2")
+	if got := getUser(t, h, user.ID).PremiumUntil - first; got != int64(7*24*time.Hour/time.Second) {
+		t.Fatalf("second payment extended premium by %v", time.Duration(got)*time.Second)
+	}
+	if !getUser(t, h, user.ID).Paid {
+		t.Fatal("user who paid is not marked as paid")
+	}
+
+	//Invoices sent before the plan catalog are still paid for a month
+	pay(h, user, "premium", "charge-3")
+	if got := getUser(t, h, user.ID).PremiumUntil - first; got != int64(37*24*time.Hour/time.Second) {
+		t.Fatalf("legacy payment extended premium by %v", time.Duration(got)*time.Second)
+	}
+}
+
+func TestBusy(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.QueueSize = 0
+	h := bottest.NewWithConfig(t, cfg)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	keepBusy(t, h, user)
+	h.Server.Reset()
+
+	//Button presses are answered with an alert instead of a message
+	h.PressButton(user, 1, "level:1:B1")
+	if answer := h.LastCall("answerCallbackQuery"); answer.Params["text"] != h.Messages.Busy["en"] || answer.Params["show_alert"] != "true" {
+		t.Fatalf("button of busy user was answered with %v", answer.Params)
+	}
+
+	//The user is asked to try again later at most once a minute
+	h.PressButton(user, 1, "level:1:B1")
+	if answer := h.LastCall("answerCallbackQuery"); answer.Params["text"] != "" {
+		t.Fatalf("second button of busy user was answered with %v", answer.Params)
+	}
+	h.SendText(user, "casa")
+	if calls := h.Server.Calls("sendMessage"); len(calls) != 0 {
+		t.Fatalf("busy user got messages: %v", calls)
+	}
+}
+
+func TestStaleButton(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	for _, data := range []string{"A1", "lang:2:es-ES", "lang:1:xx", "unknown:1", "history:1:-1"} {
+		h.PressButton(user, 1, data)
+		if got := h.LastCall("answerCallbackQuery").Params["text"]; got != h.Messages.ButtonOutdated["en"] {
+			t.Errorf("button %q was answered with %q", data, got)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.RateLimit = ratelimit.Config{Free: ratelimit.Tier{PerMinute: 1, Burst: 2}, Premium: ratelimit.Tier{PerMinute: 100, Burst: 50}, BanAfter: 3, BanMinutes: 30}
+	h := bottest.NewWithConfig(t, cfg)
+	user := bottest.User(42, "en")
+	h.SendText(user, "/start")
+	h.SendText(user, "/help")
+	h.Server.Reset()
+
+	//The burst is used up, the user is told about throttling once and banned after more updates
+	for range 5 {
+		h.SendText(user, "/help")
+	}
+	var texts []string
+	for _, c := range h.Server.Calls("sendMessage") {
+		texts = append(texts, c.Params["text"])
+	}
+	if len(texts) != 2 || texts[0] != h.Messages.Throttled["en"] || !strings.HasPrefix(texts[1], strings.Split(h.Messages.Banned["en"], "%")[0]) {
+		t.Fatalf("flooding user got %q", texts)
+	}
+	if until := time.Until(time.Unix(getUser(t, h, user.ID).BannedUntil, 0)); until < 29*time.Minute || until > 30*time.Minute {
+		t.Fatalf("user was banned for %v", until)
+	}
+
+	//Banned user is reminded of the ban at most once a minute
+	h.Server.Reset()
+	h.SendText(user, "/help")
+	h.SendText(user, "/help")
+	h.PressButton(user, 1, "lang:1:es-ES")
+	if got := len(h.Server.Calls("sendMessage")); got != 0 {
+		t.Fatalf("banned user got %d messages right after the ban", got)
+	}
+	if answer := h.LastCall("answerCallbackQuery"); answer.Params["text"] != "" {
+		t.Fatalf("button of banned user was answered with %q", answer.Params["text"])
+	}
+}
+
+func TestRateLimitBusyUser(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.QueueSize = 0
+	cfg.RateLimit = ratelimit.Config{Free: ratelimit.Tier{PerMinute: 1, Burst: 6}, BanAfter: 3, BanMinutes: 30}
+	h := bottest.NewWithConfig(t, cfg)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	keepBusy(t, h, user)
+
+	//Updates rejected because the queue is full use up the burst and get the user banned
+	h.Server.Reset()
+	for range 5 {
+		h.SendText(user, "/help")
+	}
+	var texts []string
+	for _, c := range h.Server.Calls("sendMessage") {
+		texts = append(texts, c.Params["text"])
+	}
+	if len(texts) != 3 || texts[0] != h.Messages.Busy["en"] || texts[1] != h.Messages.Throttled["en"] || !strings.HasPrefix(texts[2], strings.Split(h.Messages.Banned["en"], "%")[0]) {
+		t.Fatalf("flooding busy user got %q", texts)
+	}
+	if getUser(t, h, user.ID).BannedUntil == 0 {
+		t.Fatal("flooding busy user was not banned")
+	}
+}
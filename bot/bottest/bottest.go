@@ -0,0 +1,119 @@
+// Package bottest runs the bot against a fake Telegram Bot API server with fake sentence generator, tts and in-memory store,
+// so that whole conversations can be driven with synthetic updates and asserted on the recorded Bot API calls
+package bottest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/quota"
+	"github.com/dafraer/sentence-gen-tg-bot/text"
+	"github.com/dafraer/sentence-gen-tg-bot/tts"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// Token is the bot token used by the harness
+const Token = "123456:test-token"
+
+// Harness wires the bot with fake dependencies
+type Harness struct {
+	Bot       *bot.Bot
+	Server    *Server
+	Store     *db.MemoryStore
+	Generator *Generator
+	TTS       *TTSProvider
+	Messages  *text.Messages
+
+	tb       testing.TB
+	updateID int64
+}
+
+// DefaultConfig returns bot config used by the harness
+func DefaultConfig() bot.Config {
+	return bot.Config{Token: Token, Quota: quota.Policy{DailyAllowance: 50}, Plans: bot.DefaultPlans, SubscriptionPrice: 90, Referral: db.ReferralBonus{Sentences: 20}, TrialDays: 3, Workers: 4, QueueSize: 3}
+}
+
+// New creates harness with the default config, everything is shut down when the test finishes
+func New(tb testing.TB) *Harness {
+	return NewWithConfig(tb, DefaultConfig())
+}
+
+// NewWithConfig creates harness with the provided bot config
+func NewWithConfig(tb testing.TB, cfg bot.Config) *Harness {
+	tb.Helper()
+	h := &Harness{
+		Server:    NewServer(),
+		Store:     db.NewMemory(),
+		Generator: NewGenerator(),
+		TTS:       &TTSProvider{Audio: []byte("ID3 fake mp3")},
+		Messages:  text.Load(),
+		tb:        tb,
+	}
+	tb.Cleanup(h.Server.Close)
+
+	//Voice every language with the fake provider
+	registry, err := tts.NewRegistry([]tts.Provider{h.TTS}, map[string][]string{tts.DefaultLanguage: {h.TTS.Name()}})
+	if err != nil {
+		tb.Fatalf("error creating tts registry: %v", err)
+	}
+
+	b, err := bot.New(cfg, h.Store, h.Generator, tts.NewWithRegistry(registry), h.Messages, zap.NewNop().Sugar(), tgbotapi.WithServerURL(h.Server.URL()))
+	if err != nil {
+		tb.Fatalf("error creating bot: %v", err)
+	}
+	h.Bot = b
+	return h
+}
+
+// Send processes the update and waits until the bot is done with it
+func (h *Harness) Send(update *models.Update) {
+	h.tb.Helper()
+	h.updateID++
+	update.ID = h.updateID
+	h.Bot.HandleUpdate(context.Background(), update)
+}
+
+// SendText sends text message (or command) from the user
+func (h *Harness) SendText(user *models.User, text string) {
+	h.tb.Helper()
+	h.Send(&models.Update{Message: &models.Message{
+		ID:   int(h.updateID) + 1,
+		From: user,
+		Chat: models.Chat{ID: user.ID, Type: models.ChatTypePrivate},
+		Text: text,
+	}})
+}
+
+// PressButton presses inline keyboard button with the callback data on the message with the id
+func (h *Harness) PressButton(user *models.User, messageID int, data string) {
+	h.tb.Helper()
+	h.Send(&models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:   strconv.FormatInt(h.updateID+1, 10),
+		From: *user,
+		Message: models.MaybeInaccessibleMessage{
+			Type:    models.MaybeInaccessibleMessageTypeMessage,
+			Message: &models.Message{ID: messageID, Chat: models.Chat{ID: user.ID}},
+		},
+		Data: data,
+	}})
+}
+
+// LastCall returns the last recorded call of the methods and fails the test if there is none
+func (h *Harness) LastCall(methods ...string) Call {
+	h.tb.Helper()
+	calls := h.Server.Calls(methods...)
+	if len(calls) == 0 {
+		h.tb.Fatalf("no calls of %v were made", methods)
+	}
+	return calls[len(calls)-1]
+}
+
+// User returns telegram user with the id and interface language
+func User(id int64, languageCode string) *models.User {
+	return &models.User{ID: id, FirstName: "User", Username: "user" + strconv.FormatInt(id, 10), LanguageCode: languageCode}
+}
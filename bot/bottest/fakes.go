@@ -0,0 +1,77 @@
+package bottest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/dafraer/sentence-gen-tg-bot/tts"
+)
+
+// Generator is a fake sentence generator that returns canned responses and records prompts
+type Generator struct {
+	mu       sync.Mutex
+	prompts  []string
+	response func(prompt string) (*generator.Sentences, error)
+}
+
+// NewGenerator creates fake generator that always returns the same sentence and translation
+func NewGenerator() *Generator {
+	return &Generator{response: func(string) (*generator.Sentences, error) {
+		return &generator.Sentences{Sentence: "Hola, amigo.", Translation: "Hello, friend.", TargetWordForm: "amigo"}, nil
+	}}
+}
+
+// Respond replaces the function that produces responses
+func (g *Generator) Respond(fn func(prompt string) (*generator.Sentences, error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.response = fn
+}
+
+// Prompts returns all prompts the generator received
+func (g *Generator) Prompts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.prompts...)
+}
+
+// Generate records the prompt and returns canned response
+func (g *Generator) Generate(_ context.Context, prompt string) (*generator.Sentences, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prompts = append(g.prompts, prompt)
+	return g.response(prompt)
+}
+
+// Close does nothing
+func (g *Generator) Close() error {
+	return nil
+}
+
+// TTSProvider is a fake tts provider that voices every language with the same bytes
+type TTSProvider struct {
+	mu       sync.Mutex
+	requests []tts.Request
+	Audio    []byte
+}
+
+// Name returns provider name
+func (p *TTSProvider) Name() string {
+	return "fake"
+}
+
+// Synthesize records the request and returns fake audio
+func (p *TTSProvider) Synthesize(_ context.Context, req *tts.Request) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, *req)
+	return p.Audio, nil
+}
+
+// Requests returns all requests the provider received
+func (p *TTSProvider) Requests() []tts.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]tts.Request(nil), p.requests...)
+}
@@ -0,0 +1,170 @@
+package bottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// BotUsername is the username of the bot returned by the fake getMe
+const BotUsername = "test_bot"
+
+// Call is a single Bot API request received by the fake server
+type Call struct {
+	Method string            //e.g. sendMessage
+	Params map[string]string //Form fields of the request
+	Files  map[string]File   //Uploaded files by form field name
+
+	MessageID int //Id of the message sent by the call, zero for calls that do not send messages
+}
+
+// File is a file uploaded in a Bot API request
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Server is a fake Telegram Bot API server that records every request and answers with plausible results
+type Server struct {
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	calls     []Call
+	messageID int
+	files     map[string]File //Uploaded documents by file id
+}
+
+// NewServer starts new fake Bot API server
+func NewServer() *Server {
+	s := &Server{files: make(map[string]File)}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL returns base URL of the server to be used with tgbotapi.WithServerURL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Calls returns all recorded calls of the methods, or every recorded call if no methods are specified
+func (s *Server) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if len(methods) == 0 || slices.Contains(methods, c.Method) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets recorded calls
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+}
+
+// handle records the request and writes a response for it
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	//Serve uploaded files to the file download links
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		s.serveFile(w, r)
+		return
+	}
+
+	//Path looks like /bot<token>/<method>
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	call := Call{Method: method, Params: make(map[string]string), Files: make(map[string]File)}
+	if err := r.ParseMultipartForm(32 << 20); err == nil {
+		for name, values := range r.MultipartForm.Value {
+			call.Params[name] = values[0]
+		}
+		for name, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				continue
+			}
+			data, _ := io.ReadAll(f)
+			_ = f.Close()
+			call.Files[name] = File{Name: headers[0].Filename, Data: data}
+		}
+	}
+
+	s.mu.Lock()
+	result := s.result(call)
+	if msg, ok := result.(models.Message); ok {
+		call.MessageID = msg.ID
+	}
+	if method != "getMe" {
+		s.calls = append(s.calls, call)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// result returns result of the Bot API method, must be called with the lock held
+func (s *Server) result(call Call) any {
+	switch call.Method {
+	case "getMe":
+		return models.User{ID: 1, IsBot: true, FirstName: "Test", Username: BotUsername}
+	case "sendMessage", "sendInvoice", "editMessageText", "editMessageReplyMarkup", "sendAudio", "sendVoice":
+		return s.message(call, nil)
+	case "sendDocument":
+		doc := &models.Document{FileID: fmt.Sprintf("file-%d", s.messageID+1)}
+		if f, ok := call.Files["document"]; ok {
+			doc.FileName = f.Name
+			s.files[doc.FileID] = f
+		}
+		return s.message(call, doc)
+	case "getFile":
+		return models.File{FileID: call.Params["file_id"], FilePath: call.Params["file_id"]}
+	case "createInvoiceLink":
+		return "https://t.me/$test-invoice"
+	default:
+		return true
+	}
+}
+
+// message creates a message sent by the bot, must be called with the lock held
+func (s *Server) message(call Call, doc *models.Document) models.Message {
+	s.messageID++
+	chatID, _ := strconv.ParseInt(call.Params["chat_id"], 10, 64)
+	return models.Message{
+		ID:       s.messageID,
+		Date:     int(time.Now().Unix()),
+		Chat:     models.Chat{ID: chatID},
+		Text:     call.Params["text"],
+		Document: doc,
+	}
+}
+
+// serveFile writes previously uploaded document, path looks like /file/bot<token>/<file id>
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	fileID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	s.mu.Lock()
+	f, ok := s.files[fileID]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(f.Data)
+}
@@ -2,83 +2,154 @@ package bot
 
 import (
 	"context"
+	"slices"
+	"strconv"
+	"time"
+
 	tgbotapi "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
-const (
-	levelCallbackLength    = 2
-	languageCallbackLength = 5
-)
-
-// processCallbackQuery routes callback to the handler functions
-func (b *Bot) processCallbackQuery(ctx context.Context, update *models.Update) {
-	b.logger.Infow("Callback Query Received", "from", update.CallbackQuery.From.Username, "callback data", update.CallbackQuery.Data)
-	switch {
-	//callback to buy premium
-	case update.CallbackQuery.Data == premiumCallback:
-		b.processPremiumCallback(ctx, update)
-	//If the length of the callback data is equal to the level length (e.g. A1) - process level callback
-	case len(update.CallbackQuery.Data) == levelCallbackLength:
-		b.processLevelCallback(ctx, update)
-	//If the length of the callback data is equal to the language code length (e.g. es-ES) - process language callback
-	case len(update.CallbackQuery.Data) == languageCallbackLength:
-		b.processLanguageCallback(ctx, update)
+// processLanguageCallback handles callback choosing language
+func (b *Bot) processLanguageCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 || !b.sentenceLanguage(args[0]) {
+		return errStaleCallback
 	}
-}
 
-// processLanguageCallback handles callback choosing language
-func (b *Bot) processLanguageCallback(ctx context.Context, update *models.Update) {
 	//Update user sentence language
-	if err := b.store.SetUserSentenceLanguage(ctx, update.CallbackQuery.From.ID, update.CallbackQuery.Data); err != nil {
-		b.logger.Errorw("failed to set user sentence language", "err", err)
-		return
+	if err := b.store.SetUserSentenceLanguage(ctx, update.CallbackQuery.From.ID, args[0]); err != nil {
+		b.log(ctx).Errorw("failed to set user sentence language", "err", err)
+		return nil
 	}
 
 	//Delete message with inline keyboard
 	if _, err := b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID}); err != nil {
-		b.logger.Errorw("failed to delete message", "err", err)
-		return
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+		return nil
 	}
 
 	//Prompt user to choose language level (e.g. A1)
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.CallbackQuery.From.ID, Text: b.messages.Level[language(&update.CallbackQuery.From)], ReplyMarkup: levelsMarkup()}); err != nil {
-		b.logger.Errorw("failed to send level message", "err", err)
+		b.log(ctx).Errorw("failed to send level message", "err", err)
 	}
+	return nil
 }
 
 // processLevelCallback handles callback choosing language level
-func (b *Bot) processLevelCallback(ctx context.Context, update *models.Update) {
+func (b *Bot) processLevelCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 || !slices.Contains(levels, args[0]) {
+		return errStaleCallback
+	}
+
 	//Update user language level
-	if err := b.store.SetUserLevel(ctx, update.CallbackQuery.From.ID, update.CallbackQuery.Data); err != nil {
-		b.logger.Errorw("failed to set user language level", "err", err)
-		return
+	if err := b.store.SetUserLevel(ctx, update.CallbackQuery.From.ID, args[0]); err != nil {
+		b.log(ctx).Errorw("failed to set user language level", "err", err)
+		return nil
 	}
 	//Delete message with inline keyboard
 	if _, err := b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID}); err != nil {
-		b.logger.Errorw("failed to delete message", "err", err)
-		return
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+		return nil
+	}
+
+	//Prompt user to choose timezone, until they do it is inferred from their language
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.CallbackQuery.From.ID, Text: b.messages.Timezone[language(&update.CallbackQuery.From)], ReplyMarkup: timezonesMarkup(time.Now())}); err != nil {
+		b.log(ctx).Errorw("failed to send timezone message", "err", err)
+	}
+	return nil
+}
+
+// processTimezoneCallback handles callback choosing timezone
+func (b *Bot) processTimezoneCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 || !slices.Contains(timezones, args[0]) {
+		return errStaleCallback
+	}
+
+	//Update user timezone
+	if err := b.store.SetUserTimezone(ctx, update.CallbackQuery.From.ID, args[0]); err != nil {
+		b.log(ctx).Errorw("failed to set user timezone", "err", err)
+		return nil
+	}
+
+	//Delete message with inline keyboard
+	if _, err := b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID}); err != nil {
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+		return nil
 	}
 
 	//Send message telling user that everything is set
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.CallbackQuery.From.ID, Text: b.messages.PreferencesSet[language(&update.CallbackQuery.From)]}); err != nil {
-		b.logger.Errorw("failed to send message", "err", err)
+		b.log(ctx).Errorw("failed to send message", "err", err)
 	}
+	return nil
 }
 
-// processPremiumCallback sends an invoice to buy premium to the user
-func (b *Bot) processPremiumCallback(ctx context.Context, update *models.Update) {
-	lang := language(&update.CallbackQuery.From)
-
-	//Send the invoice
-	if err := b.sendInvoice(ctx, &update.CallbackQuery.From, b.messages.PremiumTitle[lang], b.messages.PremiumDescription[lang]); err != nil {
-		b.logger.Errorw("failed to send invoice", "err", err)
-		return
+// processPremiumCallback sends premium plans to choose from to the user
+func (b *Bot) processPremiumCallback(ctx context.Context, update *models.Update, _ []string) error {
+	//Send the plans
+	if err := b.sendPlans(ctx, &update.CallbackQuery.From); err != nil {
+		b.log(ctx).Errorw("failed to send plans", "err", err)
+		return nil
 	}
 
 	//Delete message with inline keyboard
 	_, err := b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID})
 	if err != nil {
-		b.logger.Errorw("failed to delete message", "err", err)
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+	}
+	return nil
+}
+
+// processHistoryCallback shows the requested page of the history in place of the current one
+func (b *Bot) processHistoryCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 {
+		return errStaleCallback
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 0 {
+		return errStaleCallback
+	}
+	b.editHistoryPage(ctx, update, page)
+	return nil
+}
+
+// processHistoryDeleteCallback deletes the card and shows the page it was on again
+func (b *Bot) processHistoryDeleteCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 2 {
+		return errStaleCallback
+	}
+	page, err := strconv.Atoi(args[0])
+	if err != nil || page < 0 {
+		return errStaleCallback
+	}
+
+	//Delete the card
+	if err := b.store.DeleteCard(ctx, update.CallbackQuery.From.ID, args[1]); err != nil {
+		b.log(ctx).Errorw("failed to delete card", "err", err)
+		return nil
+	}
+	b.editHistoryPage(ctx, update, page)
+	return nil
+}
+
+// editHistoryPage replaces the message with history page. If the page became empty the previous page is shown
+func (b *Bot) editHistoryPage(ctx context.Context, update *models.Update, page int) {
+	lang := language(&update.CallbackQuery.From)
+	text, markup, err := b.historyPage(ctx, update.CallbackQuery.From.ID, page, lang)
+	for err == nil && markup == nil && page > 0 {
+		page--
+		text, markup, err = b.historyPage(ctx, update.CallbackQuery.From.ID, page, lang)
+	}
+	if err != nil {
+		b.log(ctx).Errorw("failed to get history page", "err", err)
+		return
+	}
+
+	params := &tgbotapi.EditMessageTextParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID, Text: text}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	if _, err := b.b.EditMessageText(ctx, params); err != nil {
+		b.log(ctx).Errorw("failed to edit message", "err", err)
 	}
 }
@@ -1,59 +1,91 @@
 package bot
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/quota"
 	tgbotapi "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"strings"
 	"time"
 )
 
 // processCommand routes command to the method that handles it
 func (b *Bot) processCommand(ctx context.Context, update *models.Update) {
-	b.logger.Infow("Command Received", "from", update.Message.From.Username, "command", update.Message.Text)
+	b.log(ctx).Infow("Command Received", "from", update.Message.From.Username, "command", update.Message.Text)
 
-	switch update.Message.Text {
+	//Command arguments are separated from the command by a space, e.g. /refund 42 charge-id
+	command, args, _ := strings.Cut(update.Message.Text, " ")
+	switch command {
 	case "/start":
-		b.processStartCommand(ctx, update)
+		b.processStartCommand(ctx, update, args)
 	case "/help":
 		b.processHelpCommand(ctx, update)
 	case "/premium":
 		b.processPremiumCommand(ctx, update)
 	case "/preferences":
 		b.processPreferencesCommand(ctx, update)
+	case "/export":
+		b.processExportCommand(ctx, update)
+	case "/history":
+		b.processHistoryCommand(ctx, update)
+	case "/review":
+		b.processReviewCommand(ctx, update)
+	case "/quota":
+		b.processQuotaCommand(ctx, update)
+	case "/refund":
+		b.processRefundCommand(ctx, update, args)
+	case "/redeem":
+		b.processRedeemCommand(ctx, update, args)
+	case "/promo":
+		b.processPromoCommand(ctx, update, args)
+	case "/gift":
+		b.processGiftCommand(ctx, update, args)
+	case "/invite":
+		b.processInviteCommand(ctx, update)
 	default:
 		b.processUnknownCommand(ctx, update)
 	}
 }
 
-// processStartCommand creates user in the database if user does not exist and sends starting message to the user
-func (b *Bot) processStartCommand(ctx context.Context, update *models.Update) {
-	//Create user document if it does not exist
+// processStartCommand creates user in the database if user does not exist and sends starting message to the user.
+// Parameter of the deep link the bot was started with is passed in args
+func (b *Bot) processStartCommand(ctx context.Context, update *models.Update, args string) {
+	//Create user document if it does not exist, new users who opened an invite link remember who invited them
 	if _, err := b.store.GetUser(ctx, update.Message.Chat.ID); err != nil {
-		if err := b.store.CreateUser(ctx, &db.User{ChatId: update.Message.Chat.ID, UserName: update.Message.From.Username, FreeSentences: freeSentencesAmount}); err != nil {
-			b.logger.Errorw("error creating user int the database", "error", err)
+		user := &db.User{ChatId: update.Message.Chat.ID, UserName: update.Message.From.Username, Language: update.Message.From.LanguageCode, FreeSentences: b.cfg.Quota.DailyAllowance}
+		user.ReferredBy = b.referrer(ctx, user.ChatId, args)
+		if err := b.store.CreateUser(ctx, user); err != nil {
+			b.log(ctx).Errorw("error creating user int the database", "error", err)
 			return
 		}
 	}
 
 	//Send starting message
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.Start[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
 		return
 	}
+
+	//Handle the deep link
+	if strings.HasPrefix(args, giftStartPrefix) {
+		b.processGiftStart(ctx, update, args)
+	}
 }
 
 // processPreferencesCommand sends settings message to the user
 func (b *Bot) processPreferencesCommand(ctx context.Context, update *models.Update) {
-	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.Lang[language(update.Message.From)], ReplyMarkup: b.messages.LanguageMarkup[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.Lang[language(update.Message.From)], ReplyMarkup: b.languagesMarkup(language(update.Message.From))}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
 
 // processHelpCommand sends user the list of the available commands
 func (b *Bot) processHelpCommand(ctx context.Context, update *models.Update) {
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.Help[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
 
@@ -63,37 +95,123 @@ func (b *Bot) processPremiumCommand(ctx context.Context, update *models.Update)
 	//Get user from the db to check if they already have premium
 	user, err := b.store.GetUser(ctx, update.Message.Chat.ID)
 	if err != nil {
-		b.logger.Errorw("error getting user from the database", "error", err)
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
 	}
+	lang := language(update.Message.From)
 
-	//Check if the user is already premium
-	if premium(user) {
-		//daysLeft stores amount of days of premium left rounded upwards.
-		daysLeft := (int(time.Unix(user.PremiumUntil, 0).Sub(time.Now()).Hours()) + 23) / 24
+	//Subscribers see when their subscription is renewed and can cancel it
+	if subscribed(user) {
+		if err := b.sendSubscription(ctx, user, lang); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return
+	}
 
+	//Check if the user is already premium
+	if paidPremium(user) {
 		//Tell user that they  already have premium
-		//b.logger.Debugw("language", "language", language(update.Message.From), "languageCode", update.Message.From.LanguageCode)
-		_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.AlreadyPremium[language(update.Message.From)](daysLeft)})
+		text := b.messages.AlreadyPremium[lang](daysLeft(user.PremiumUntil), false)
+		if user.Lifetime {
+			text = b.messages.LifetimePremium[lang]
+		}
+		_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text})
 		if err != nil {
-			b.logger.Errorw("error sending message", "error", err)
+			b.log(ctx).Errorw("error sending message", "error", err)
 		}
 		return
 	}
 
-	//Send message with an inline keyboard prompting user to buy premium
-	_, err = b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{
-		ChatID:      update.Message.Chat.ID,
-		Text:        b.messages.Premium[language(update.Message.From)],
-		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: b.messages.PremiumTitle[language(update.Message.From)], CallbackData: premiumCallback}}}},
-	})
+	//Users on trial can buy premium before the trial ends
+	if trial(user) {
+		_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.AlreadyPremium[lang](daysLeft(user.TrialUntil), true), ReplyMarkup: b.plansMarkup(ctx, lang)})
+		if err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return
+	}
+
+	//Send message with an inline keyboard prompting user to choose a plan
+	if err := b.sendPlans(ctx, update.Message.From); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// processExportCommand sends user all their generated sentences as an Anki deck
+func (b *Bot) processExportCommand(ctx context.Context, update *models.Update) {
+	lang := language(update.Message.From)
+
+	//Get user's cards from the database
+	cards, err := b.store.GetCards(ctx, update.Message.Chat.ID)
 	if err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error getting cards from the database", "error", err)
+		return
+	}
+
+	//Tell user that there is nothing to export yet
+	if len(cards) == 0 {
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.NothingToExport[lang]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return
+	}
+
+	//Build the deck
+	var apkg bytes.Buffer
+	if err := b.buildDeck(ctx, b.messages.DeckName[lang], cards).WriteAPKG(ctx, &apkg); err != nil {
+		b.log(ctx).Errorw("error building anki deck", "error", err)
+		return
+	}
+
+	//Send the deck
+	params := &tgbotapi.SendDocumentParams{
+		ChatID:   update.Message.Chat.ID,
+		Document: &models.InputFileUpload{Filename: "wordbuddy.apkg", Data: &apkg},
+		Caption:  b.messages.Export[lang],
+	}
+	if _, err := b.b.SendDocument(ctx, params); err != nil {
+		b.log(ctx).Errorw("error sending document", "error", err)
+	}
+}
+
+// processHistoryCommand sends user the first page of their history
+func (b *Bot) processHistoryCommand(ctx context.Context, update *models.Update) {
+	text, markup, err := b.historyPage(ctx, update.Message.Chat.ID, 0, language(update.Message.From))
+	if err != nil {
+		b.log(ctx).Errorw("error getting history page", "error", err)
+		return
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text, ReplyMarkup: markup}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
 
 // processUnknownCommand sends user the message stating that the bot does not know this command
 func (b *Bot) processUnknownCommand(ctx context.Context, update *models.Update) {
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.UnknownCommand[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// processQuotaCommand sends user the amount of free sentences they have left and the time until they are reset
+func (b *Bot) processQuotaCommand(ctx context.Context, update *models.Update) {
+	lang := language(update.Message.From)
+	user, err := b.store.GetUser(ctx, update.Message.Chat.ID)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
+	}
+
+	text := b.messages.QuotaPremium[lang]
+	if !premium(user) {
+		now := time.Now()
+		remaining, resetAt := b.cfg.Quota.Remaining(user, now, quota.Location(user.Timezone, update.Message.From.LanguageCode))
+		text = b.messages.Quota[lang](remaining, b.cfg.Quota.DailyAllowance, resetAt.Sub(now))
+		if user.BonusSentences > 0 {
+			text += "\n" + fmt.Sprintf(b.messages.QuotaBonus[lang], user.BonusSentences)
+		}
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"sync"
+)
+
+// dispatcher runs jobs with a global concurrency limit. Jobs of the same chat are run one by one in the order
+// they were submitted, so concurrent updates from the same user do not race on user's data
+type dispatcher struct {
+	workers   chan struct{} //Semaphore limiting amount of jobs running at the same time
+	queueSize int           //Max amount of jobs of a single chat waiting to be run
+
+	mu     sync.Mutex
+	queues map[int64][]func() //Jobs waiting to be run, a chat is present in the map while its jobs are being run
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// newDispatcher creates dispatcher running at most workers jobs at the same time
+// and keeping at most queueSize waiting jobs per chat
+func newDispatcher(workers, queueSize int) *dispatcher {
+	return &dispatcher{
+		workers:   make(chan struct{}, workers),
+		queueSize: queueSize,
+		queues:    make(map[int64][]func()),
+	}
+}
+
+// submit queues job of the chat. Returns false if the chat's queue is full or the dispatcher is draining
+func (d *dispatcher) submit(chatId int64, job func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+
+	//If chat's jobs are already being run, the job will be picked up after them
+	queue, running := d.queues[chatId]
+	if running {
+		if len(queue) >= d.queueSize {
+			return false
+		}
+		d.queues[chatId] = append(queue, job)
+		return true
+	}
+
+	d.queues[chatId] = nil
+	d.wg.Add(1)
+	go d.run(chatId, job)
+	return true
+}
+
+// run runs the job and then the rest of chat's jobs until its queue is empty
+func (d *dispatcher) run(chatId int64, job func()) {
+	defer d.wg.Done()
+	for {
+		//Wait for a free worker, it is released after every job so other chats get their turn
+		d.workers <- struct{}{}
+		job()
+		<-d.workers
+
+		d.mu.Lock()
+		queue := d.queues[chatId]
+		if len(queue) == 0 {
+			delete(d.queues, chatId)
+			d.mu.Unlock()
+			return
+		}
+		job = queue[0]
+		d.queues[chatId] = queue[1:]
+		d.mu.Unlock()
+	}
+}
+
+// drain stops accepting new jobs and waits until submitted jobs are done or ctx is done
+func (d *dispatcher) drain(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherOrder(t *testing.T) {
+	d := newDispatcher(4, 100)
+	var mu sync.Mutex
+	got := make(map[int64][]int)
+	for i := 0; i < 50; i++ {
+		for _, chatId := range []int64{1, 2} {
+			if !d.submit(chatId, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				got[chatId] = append(got[chatId], i)
+			}) {
+				t.Fatalf("job %d of chat %d was rejected", i, chatId)
+			}
+		}
+	}
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatalf("error draining: %v", err)
+	}
+
+	//Jobs of the same chat are run in the order they were submitted
+	for chatId, jobs := range got {
+		if len(jobs) != 50 {
+			t.Fatalf("chat %d ran %d jobs", chatId, len(jobs))
+		}
+		for i, job := range jobs {
+			if job != i {
+				t.Fatalf("chat %d ran jobs in order %v", chatId, jobs)
+			}
+		}
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	d := newDispatcher(1, 1)
+	release := make(chan struct{})
+	if !d.submit(1, func() { <-release }) {
+		t.Fatal("running job was rejected")
+	}
+	if !d.submit(1, func() {}) {
+		t.Fatal("queued job was rejected")
+	}
+	if d.submit(1, func() {}) {
+		t.Fatal("job over the queue size was accepted")
+	}
+	close(release)
+
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatalf("error draining: %v", err)
+	}
+	if d.submit(2, func() {}) {
+		t.Fatal("job was accepted while draining")
+	}
+}
+
+func TestDispatcherDrainTimeout(t *testing.T) {
+	d := newDispatcher(1, 1)
+	release := make(chan struct{})
+	defer close(release)
+	d.submit(1, func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.drain(ctx); err == nil {
+		t.Fatal("drain returned before the job was done")
+	}
+}
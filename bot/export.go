@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/anki"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+)
+
+const (
+	downloadTimeout = 10 * time.Second //How long a single audio file can be downloaded
+	exportTimeout   = 2 * time.Minute  //How long audio of all cards can be downloaded, so an export does not hold the worker for too long
+)
+
+// downloadClient downloads files from telegram, unlike the default client it gives up on stalled downloads
+var downloadClient = &http.Client{Timeout: downloadTimeout}
+
+// buildDeck creates Anki deck from the newest cards, audio is downloaded from telegram.
+// Cards whose audio can not be downloaded in time are exported without audio
+func (b *Bot) buildDeck(ctx context.Context, name string, cards []*db.Card) *anki.Deck {
+	if len(cards) > maxExportCards {
+		cards = cards[len(cards)-maxExportCards:]
+	}
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	deck := &anki.Deck{Name: name, Notes: make([]anki.Note, 0, len(cards))}
+	for _, card := range cards {
+		note := anki.Note{ID: card.ID, Sentence: card.Sentence, Translation: card.Translation, Word: card.Word}
+		if card.AudioFileID != "" && ctx.Err() == nil {
+			audio, err := b.downloadFile(ctx, card.AudioFileID)
+			if err != nil {
+				b.log(ctx).Errorw("error downloading audio", "error", err, "fileID", card.AudioFileID)
+			}
+			note.Audio = audio
+		}
+		deck.Notes = append(deck.Notes, note)
+	}
+	if ctx.Err() != nil {
+		b.log(ctx).Errorw("audio download of the export timed out", "error", ctx.Err())
+	}
+	return deck
+}
+
+// downloadFile downloads file previously sent to telegram using its file id
+func (b *Bot) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := b.b.GetFile(ctx, &tgbotapi.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.b.FileDownloadLink(file), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download failed with status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// giftStartPrefix starts deep link parameter opening gift plans for the user, e.g. gift_12345
+const giftStartPrefix = "gift_"
+
+// processGiftCommand sends plans to gift premium to the user identified by @username or chat id.
+// Without arguments it sends user's own link that friends can open to gift them premium
+func (b *Bot) processGiftCommand(ctx context.Context, update *models.Update, args string) {
+	lang := language(update.Message.From)
+	target := strings.TrimSpace(args)
+	if target == "" {
+		link, err := b.deepLink(ctx, giftStartPrefix+strconv.FormatInt(update.Message.Chat.ID, 10))
+		if err != nil {
+			b.log(ctx).Errorw("error creating gift link", "error", err)
+			return
+		}
+		b.reply(ctx, update, fmt.Sprintf(b.messages.GiftUsage[lang], link))
+		return
+	}
+
+	//Find the recipient
+	var recipient *db.User
+	var err error
+	if userName, ok := strings.CutPrefix(target, "@"); ok {
+		recipient, err = b.store.FindUserByUserName(ctx, userName)
+	} else if chatId, parseErr := strconv.ParseInt(target, 10, 64); parseErr == nil {
+		recipient, err = b.store.GetUser(ctx, chatId)
+	} else {
+		err = db.ErrUserNotFound
+	}
+	if errors.Is(err, db.ErrUserNotFound) {
+		b.reply(ctx, update, b.messages.GiftRecipientNotFound[lang])
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
+	}
+	b.sendGiftPlans(ctx, update.Message.From, recipient)
+}
+
+// processGiftStart opens gift plans for the user whose gift link was opened
+func (b *Bot) processGiftStart(ctx context.Context, update *models.Update, parameter string) {
+	chatId, err := strconv.ParseInt(strings.TrimPrefix(parameter, giftStartPrefix), 10, 64)
+	if err != nil {
+		b.log(ctx).Errorw("invalid gift link", "parameter", parameter)
+		return
+	}
+	recipient, err := b.store.GetUser(ctx, chatId)
+	if errors.Is(err, db.ErrUserNotFound) {
+		b.reply(ctx, update, b.messages.GiftRecipientNotFound[language(update.Message.From)])
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
+	}
+	b.sendGiftPlans(ctx, update.Message.From, recipient)
+}
+
+// sendGiftPlans sends keyboard with plans that can be gifted to the recipient.
+// Users who open their own gift link get the usual plans
+func (b *Bot) sendGiftPlans(ctx context.Context, from *models.User, recipient *db.User) {
+	if recipient.ChatId == from.ID {
+		if err := b.sendPlans(ctx, from); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return
+	}
+
+	lang := language(from)
+	markup := &models.InlineKeyboardMarkup{}
+	for _, p := range b.cfg.Plans {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: b.messages.PlanButton[lang](p.Days, p.Price), CallbackData: giftCallback.data(strconv.FormatInt(recipient.ChatId, 10), p.ID)},
+		})
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: from.ID, Text: fmt.Sprintf(b.messages.GiftPlans[lang], displayName(recipient)), ReplyMarkup: markup}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// processGiftCallback sends an invoice for the plan gifted to the recipient
+func (b *Bot) processGiftCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 2 {
+		return errStaleCallback
+	}
+	recipient, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || recipient == 0 {
+		return errStaleCallback
+	}
+	plan, ok := b.plan(args[1])
+	if !ok {
+		//The plan was removed from the catalog after the keyboard was sent
+		return errStaleCallback
+	}
+
+	//Send the invoice
+	if err := b.sendInvoice(ctx, &update.CallbackQuery.From, plan, giftInvoicePayload(recipient, plan)); err != nil {
+		b.log(ctx).Errorw("failed to send invoice", "err", err)
+		return nil
+	}
+
+	//Delete message with inline keyboard
+	_, err = b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID})
+	if err != nil {
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+	}
+	return nil
+}
+
+// notifyGift tells the payer that the gift was delivered and the recipient that they got premium
+func (b *Bot) notifyGift(ctx context.Context, update *models.Update, plan Plan, recipientId int64) {
+	recipient, err := b.store.GetUser(ctx, recipientId)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
+	}
+	b.reply(ctx, update, fmt.Sprintf(b.messages.GiftSent[language(update.Message.From)], displayName(recipient)))
+
+	from := update.Message.From.FirstName
+	if update.Message.From.Username != "" {
+		from = "@" + update.Message.From.Username
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: recipientId, Text: b.messages.GiftReceived[storedLanguage(recipient)](plan.Days, from)}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// giftInvoicePayload returns invoice payload of the plan gifted to the recipient
+func giftInvoicePayload(recipient int64, plan Plan) string {
+	return giftPayload + ":" + strconv.FormatInt(recipient, 10) + ":" + plan.ID
+}
+
+// displayName returns @username of the user or their chat id if they have no username
+func displayName(user *db.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	return strconv.FormatInt(user.ChatId, 10)
+}
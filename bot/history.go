@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// historyPage returns text and inline keyboard of the history page (starting from 0).
+// Markup is nil when there are no cards on the page
+func (b *Bot) historyPage(ctx context.Context, chatId int64, page int, lang string) (string, *models.InlineKeyboardMarkup, error) {
+	cards, hasMore, err := b.store.ListCards(ctx, chatId, page*historyPageSize, historyPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(cards) == 0 {
+		return b.messages.HistoryEmpty[lang], nil, nil
+	}
+
+	//Write cards and a delete button for each of them
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf(b.messages.HistoryTitle[lang], page+1))
+	var deleteButtons []models.InlineKeyboardButton
+	for i, card := range cards {
+		n := page*historyPageSize + i + 1
+		text.WriteString(fmt.Sprintf("\n\n%d. %s (%s)\n%s\n%s", n, card.Word, time.Unix(card.CreatedAt, 0).UTC().Format(time.DateOnly), card.Sentence, card.Translation))
+		deleteButtons = append(deleteButtons, models.InlineKeyboardButton{
+			Text:         fmt.Sprintf("🗑 %d", n),
+			CallbackData: historyDeleteCallback.data(strconv.Itoa(page), card.ID),
+		})
+	}
+
+	//Add buttons to navigate between pages
+	var navigation []models.InlineKeyboardButton
+	if page > 0 {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "⬅️", CallbackData: historyCallback.data(strconv.Itoa(page - 1))})
+	}
+	if hasMore {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "➡️", CallbackData: historyCallback.data(strconv.Itoa(page + 1))})
+	}
+
+	markup := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{deleteButtons}}
+	if len(navigation) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, navigation)
+	}
+	return text.String(), markup, nil
+}
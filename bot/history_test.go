@@ -0,0 +1,58 @@
+package bot_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+)
+
+func TestHistory(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	h.SendText(user, "/history")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.HistoryEmpty["en"] {
+		t.Fatalf("empty history is %q", got)
+	}
+
+	//Six cards take two pages
+	ctx := context.Background()
+	for i := 1; i <= 6; i++ {
+		if err := h.Store.AddCard(ctx, &db.Card{ChatId: user.ID, Word: fmt.Sprintf("word%d", i)}); err != nil {
+			t.Fatalf("error adding card: %v", err)
+		}
+	}
+
+	h.SendText(user, "/history")
+	first := h.LastCall("sendMessage")
+	if !strings.Contains(first.Params["text"], "1. word6") || strings.Contains(first.Params["text"], "word1") {
+		t.Fatalf("first page is %q", first.Params["text"])
+	}
+	if !strings.Contains(first.Params["reply_markup"], `"history:1:1"`) {
+		t.Fatalf("first page has no next button: %s", first.Params["reply_markup"])
+	}
+
+	h.PressButton(user, first.MessageID, "history:1:1")
+	second := h.LastCall("editMessageText")
+	if !strings.Contains(second.Params["text"], "6. word1") || !strings.Contains(second.Params["reply_markup"], `"history:1:0"`) {
+		t.Fatalf("second page is %q %s", second.Params["text"], second.Params["reply_markup"])
+	}
+
+	//Deleting the only card of the second page shows the first page again
+	cards, _, err := h.Store.ListCards(ctx, user.ID, 5, 5)
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("second page cards = %+v, %v", cards, err)
+	}
+	h.PressButton(user, first.MessageID, "hdel:1:1:"+cards[0].ID)
+	if got := h.LastCall("editMessageText").Params["text"]; !strings.Contains(got, "1. word6") || strings.Contains(got, "word1") {
+		t.Fatalf("page after delete is %q", got)
+	}
+	if cards, _, _ := h.Store.ListCards(ctx, user.ID, 0, 10); len(cards) != 5 {
+		t.Fatalf("%d cards left after delete", len(cards))
+	}
+}
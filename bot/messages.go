@@ -3,10 +3,13 @@ package bot
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/dafraer/sentence-gen-tg-bot/gemini"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/dafraer/sentence-gen-tg-bot/quota"
 
 	tgbotapi "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -14,7 +17,7 @@ import (
 
 // processMessage routes message to the handler functions
 func (b *Bot) processMessage(ctx context.Context, update *models.Update) {
-	b.logger.Infow("Message Received", "from", update.Message.From.Username, "message", update.Message.Text)
+	b.log(ctx).Infow("Message Received", "from", update.Message.From.Username, "message", update.Message.Text)
 
 	//Check if message is of appropriate length
 	if len(update.Message.Text) > maxMessageLen {
@@ -25,7 +28,7 @@ func (b *Bot) processMessage(ctx context.Context, update *models.Update) {
 	//Get user from the database to check if their preferences are set
 	user, err := b.store.GetUser(ctx, update.Message.Chat.ID)
 	if err != nil {
-		b.logger.Errorw("error getting user from the database", "error", err)
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
 		return
 	}
 
@@ -45,91 +48,160 @@ func (b *Bot) processWord(ctx context.Context, update *models.Update) {
 	if update.Message.Text == "" {
 		return
 	}
+	b.generateCard(ctx, update.Message.From, update.Message.Chat.ID, update.Message.Text)
+}
 
+// generateCard generates sentences and audio for the word, sends them to the user and saves the card.
+// One sentence is taken from the free sentences of the user. Sentences in exclude are not repeated
+func (b *Bot) generateCard(ctx context.Context, from *models.User, chatId int64, word string, exclude ...string) {
 	//Get user from the database
-	user, err := b.store.GetUser(ctx, update.Message.Chat.ID)
+	user, err := b.store.GetUser(ctx, chatId)
 	if err != nil {
-		b.logger.Errorw("error getting user from the db", "error", err)
+		b.log(ctx).Errorw("error getting user from the db", "error", err)
 		return
 	}
 
-	//Add 50 free sentences if user has not used the bot today
-	if user.FreeSentences <= 0 && time.Unix(user.LastUsed, 0).Before(time.Date(time.Now().Year(), time.Now().Month(), time.Now().Day(), 0, 0, 0, 0, time.Local)) {
-		user.FreeSentences = 50
-	}
-
-	//Check if user can generate sentences
-	if !premium(user) && user.FreeSentences <= 0 {
-		//If they can't send them message notifying them that free sentence limit has been reached
+	//Take a free sentence from the user, it is returned if the sentence can't be generated
+	now, loc := time.Now(), quota.Location(user.Timezone, from.LanguageCode)
+	reservation, err := b.store.ReserveSentence(ctx, chatId, b.cfg.Quota.Reset(now, loc))
+	if errors.Is(err, db.ErrNoFreeSentences) {
+		//If user can't generate sentences send them message notifying them that free sentence limit has been reached
+		_, resetAt := b.cfg.Quota.Remaining(user, now, loc)
 		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{
-			ChatID:      update.Message.Chat.ID,
-			Text:        b.messages.LimitReached[language(update.Message.From)],
-			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: b.messages.PremiumTitle[language(update.Message.From)], CallbackData: premiumCallback}}}}}); err != nil {
-			b.logger.Errorw("error sending message", "error", err)
+			ChatID:      chatId,
+			Text:        b.messages.LimitReached[language(from)](b.cfg.Quota.DailyAllowance, resetAt.Sub(now)),
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: b.messages.PremiumTitle[language(from)], CallbackData: premiumCallback.data()}}}}}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
 		}
 		return
 	}
+	if err != nil {
+		b.log(ctx).Errorw("error reserving free sentence", "error", err)
+		return
+	}
 
-	//Request sentences from gemini
-	res, err := b.geminiClient.Request(ctx, gemini.FormatRequestString(user.Level, user.SentenceLanguage, update.Message.Text, update.Message.From.LanguageCode), geminiProModel)
+	//Request sentences from the language model
+	res := b.generateSentences(ctx, from, chatId, user, word, exclude...)
+	if res == nil {
+		b.refundSentence(ctx, reservation)
+		return
+	}
+
+	//Send sentences and audio
+	card := &db.Card{
+		ChatId:      chatId,
+		Word:        word,
+		Sentence:    res.Sentence,
+		Translation: res.Translation,
+		Language:    user.SentenceLanguage,
+		Level:       user.Level,
+		Model:       res.Model,
+		CreatedAt:   time.Now().Unix(),
+	}
+	//New card is due for review right away
+	card.Due = card.CreatedAt
+	messageID, err := b.sendCard(ctx, from, card)
 	if err != nil {
-		b.logger.Errorw("error getting response from gemini", "error", err)
+		b.log(ctx).Errorw("error sending card", "error", err)
+		b.refundSentence(ctx, reservation)
 		return
 	}
-	b.logger.Debugw("Response from gemini:", "response", res)
 
-	//Parse gemini response into 2 sentences
-	sentence1, sentence2, err := parseSentences(res)
+	//The sentence has been delivered, so it is not returned anymore
+	if err := b.store.CommitSentence(ctx, reservation, time.Now().Unix()); err != nil {
+		b.log(ctx).Errorw("error committing free sentence", "error", err)
+	}
+
+	//The first sentence of the invited user brings the referral bonus
+	if user.ReferredBy != 0 && !user.ReferralRewarded {
+		b.rewardReferral(ctx, from, chatId)
+	}
+
+	//Save the card so it can be exported later and attach the follow-up actions to it
+	if err := b.store.AddCard(ctx, card); err != nil {
+		b.log(ctx).Errorw("error saving card", "error", err)
+	} else {
+		b.attachResultMarkup(ctx, from, chatId, messageID, card)
+	}
+}
+
+// refundSentence returns free sentence that was reserved for a sentence that could not be generated
+func (b *Bot) refundSentence(ctx context.Context, reservation *db.Reservation) {
+	if err := b.store.RefundSentence(ctx, reservation); err != nil {
+		b.log(ctx).Errorw("error refunding free sentence", "error", err)
+	}
+}
+
+// generateSentences requests sentences for the word from the language model.
+// Returns nil if sentences could not be generated, user is notified if the word is the reason
+func (b *Bot) generateSentences(ctx context.Context, from *models.User, chatId int64, user *db.User, word string, exclude ...string) *generator.Sentences {
+	prompt := generator.ExcludeSentences(generator.FormatRequestString(user.Level, user.SentenceLanguage, word, from.LanguageCode), exclude...)
+	res, err := b.generator.Generate(ctx, prompt)
+	if errors.Is(err, generator.ErrInvalidResponse) {
+		b.log(ctx).Errorw("invalid response from the language model", "error", err)
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: b.messages.BadRequest[language(from)]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return nil
+	}
 	if err != nil {
-		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.BadRequest[language(update.Message.From)]}); err != nil {
-			b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error getting response from the language model", "error", err)
+		return nil
+	}
+	b.log(ctx).Debugw("Response from the language model:", "response", res)
+
+	//Check if the model refused to generate sentences
+	if res.ErrorReason != "" {
+		b.log(ctx).Infow("language model refused to generate sentences", "word", word, "reason", res.ErrorReason)
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: b.messages.BadRequest[language(from)]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
 		}
-		return
+		return nil
 	}
+	return res
+}
 
+// sendCard generates audio for the card and sends the sentences and the audio to the user.
+// Sets AudioFileID of the card and returns id of the message with the sentences
+func (b *Bot) sendCard(ctx context.Context, from *models.User, card *db.Card) (int, error) {
 	//Generate mp3 audio
-	audio, err := b.tts.Generate(ctx, sentence1, user.SentenceLanguage)
+	audio, err := b.tts.Generate(ctx, card.Sentence, card.Language)
 	if err != nil {
-		b.logger.Errorw("error generating audio", "error", err)
-		return
+		return 0, fmt.Errorf("error generating audio: %w", err)
 	}
 
-	//Send sentences
-	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: fmt.Sprintf(b.messages.ResponseMsg[language(update.Message.From)], sentence1, sentence2), ParseMode: models.ParseModeMarkdown}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
-		return
+	//Send sentences, they are escaped because generated text can contain characters reserved by MarkdownV2
+	text := fmt.Sprintf(b.messages.ResponseMsg[language(from)], tgbotapi.EscapeMarkdown(card.Sentence), tgbotapi.EscapeMarkdown(card.Translation))
+	msg, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: card.ChatId, Text: text, ParseMode: models.ParseModeMarkdown})
+	if err != nil {
+		return 0, fmt.Errorf("error sending message: %w", err)
 	}
 
 	//Send audio
 	params := &tgbotapi.SendDocumentParams{
-		ChatID:   update.Message.Chat.ID,
-		Document: &models.InputFileUpload{Filename: update.Message.Text + ".mp3", Data: bytes.NewReader(audio)},
+		ChatID:   card.ChatId,
+		Document: &models.InputFileUpload{Filename: card.Word + ".mp3", Data: bytes.NewReader(audio)},
 	}
-	if _, err := b.b.SendDocument(ctx, params); err != nil {
-		b.logger.Errorw("error sending document", "error", err)
-		return
-	}
-
-	//Update user data
-	user.LastUsed = time.Now().Unix()
-	if !premium(user) {
-		user.FreeSentences--
+	doc, err := b.b.SendDocument(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("error sending document: %w", err)
 	}
-	if err := b.store.UpdateUser(ctx, user); err != nil {
-		b.logger.Errorw("error updating user", "error", err)
+	if doc.Document != nil {
+		card.AudioFileID = doc.Document.FileID
 	}
+	return msg.ID, nil
 }
 
 // processMessageTooLong notifies user that their message is too long
 func (b *Bot) processMessageTooLong(ctx context.Context, update *models.Update) {
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.TooLong[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
 
 // processPreferencesNotSet notifies user that their preferences are not set
 func (b *Bot) processPreferencesNotSet(ctx context.Context, update *models.Update) {
 	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: b.messages.PreferencesNotSet[language(update.Message.From)]}); err != nil {
-		b.logger.Errorw("error sending message", "error", err)
+		b.log(ctx).Errorw("error sending message", "error", err)
 	}
 }
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"go.uber.org/zap"
+)
+
+// Handler processes a single update
+type Handler func(ctx context.Context, update *models.Update)
+
+// Middleware wraps handler to run code before and after it, or to stop the update from reaching it
+type Middleware func(next Handler) Handler
+
+// loggerKey is the context key of the logger of the update being processed
+type loggerKey struct{}
+
+// userKey is the context key of the sender of the update loaded from the database by checkBan
+type userKey struct{}
+
+// Use adds middlewares run for every update after the built-in ones. Request id, ban check and rate limiting are run
+// before the update is queued, panic recovery, latency logging and the added middlewares are run when it is taken from the queue.
+// Middlewares are run in the order they are added. Use must be called before the bot is run
+func (b *Bot) Use(middlewares ...Middleware) {
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// chain returns the handler wrapped in the middlewares, the first middleware is the outermost one
+func chain(h Handler, middlewares []Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// log returns logger of the update being processed, it adds request id to every entry
+func (b *Bot) log(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+	return b.logger
+}
+
+// withRequestID gives every update a random request id and puts the logger with it into the context
+func (b *Bot) withRequestID(next Handler) Handler {
+	return func(ctx context.Context, update *models.Update) {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			b.logger.Errorw("error generating request id", "error", err)
+		}
+		logger := b.logger.With("request id", hex.EncodeToString(id), "update id", update.ID)
+		next(context.WithValue(ctx, loggerKey{}, logger), update)
+	}
+}
+
+// recoverPanic stops a panic in the handler from crashing the bot, logs it and apologizes to the user
+func (b *Bot) recoverPanic(next Handler) Handler {
+	return func(ctx context.Context, update *models.Update) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			b.log(ctx).Errorw("panic while processing update", "panic", r, "stack", string(debug.Stack()))
+			from := sender(update)
+			if from == nil {
+				return
+			}
+			if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: from.ID, Text: b.messages.Apology[language(from)]}); err != nil {
+				b.log(ctx).Errorw("error sending message", "error", err)
+			}
+		}()
+		next(ctx, update)
+	}
+}
+
+// logLatency logs how long it took to process the update
+func (b *Bot) logLatency(next Handler) Handler {
+	return func(ctx context.Context, update *models.Update) {
+		start := time.Now()
+		next(ctx, update)
+		b.log(ctx).Infow("Update processed", "duration", time.Since(start))
+	}
+}
+
+// checkBan stops messages and button presses of banned users, the sender is put into the context for the next middlewares
+func (b *Bot) checkBan(next Handler) Handler {
+	return func(ctx context.Context, update *models.Update) {
+		from := actor(update)
+		if from == nil {
+			next(ctx, update)
+			return
+		}
+		user, err := b.store.GetUser(ctx, from.ID)
+		if err != nil {
+			//New users are not banned, other errors should not lock everyone out of the bot
+			if !errors.Is(err, db.ErrUserNotFound) {
+				b.log(ctx).Errorw("error getting user from the database", "error", err)
+			}
+			next(ctx, update)
+			return
+		}
+		if !banned(user) {
+			next(context.WithValue(ctx, userKey{}, user), update)
+			return
+		}
+
+		//Users banned for flooding the bot likely keep doing it, so they are reminded of the ban at most once a minute
+		b.log(ctx).Infow("Update of banned user rejected", "from", from.Username)
+		if b.banNotes.Allow(from.ID, time.Now()) {
+			b.reject(ctx, update, fmt.Sprintf(b.messages.Banned[language(from)], formatTime(user.BannedUntil)))
+		} else if update.CallbackQuery != nil {
+			b.reject(ctx, update, "")
+		}
+	}
+}
+
+// limitRate throttles users who send updates faster than their tier allows. Users are told about it once a minute,
+// and if they keep flooding the bot while being throttled, they are banned when auto bans are enabled
+func (b *Bot) limitRate(next Handler) Handler {
+	return func(ctx context.Context, update *models.Update) {
+		from := actor(update)
+		if from == nil {
+			next(ctx, update)
+			return
+		}
+		user, _ := ctx.Value(userKey{}).(*db.User)
+		now := time.Now()
+		decision := b.limiter.Allow(from.ID, user != nil && premium(user), now)
+		if decision.Allowed {
+			next(ctx, update)
+			return
+		}
+
+		lang := language(from)
+		switch {
+		case decision.Ban && user != nil:
+			until := now.Add(time.Duration(b.cfg.RateLimit.BanMinutes) * time.Minute).Unix()
+			if err := b.store.SetUserBannedUntil(ctx, from.ID, until); err != nil {
+				b.log(ctx).Errorw("error banning user", "error", err)
+				return
+			}
+			b.log(ctx).Infow("User banned for flooding", "from", from.Username, "chat id", from.ID, "until", until)
+			//The ban message counts as the first reminder of the ban
+			b.banNotes.Allow(from.ID, now)
+			b.reject(ctx, update, fmt.Sprintf(b.messages.Banned[lang], formatTime(until)))
+		case decision.Notify:
+			b.log(ctx).Infow("User throttled", "from", from.Username, "chat id", from.ID)
+			b.reject(ctx, update, b.messages.Throttled[lang])
+		case update.CallbackQuery != nil:
+			//Stop the progress shown on the button
+			b.reject(ctx, update, "")
+		}
+	}
+}
+
+// reject tells user why their update was not processed, button presses are answered with an alert
+func (b *Bot) reject(ctx context.Context, update *models.Update, text string) {
+	if update.CallbackQuery != nil {
+		if _, err := b.b.AnswerCallbackQuery(ctx, &tgbotapi.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID, Text: text, ShowAlert: text != ""}); err != nil {
+			b.log(ctx).Errorw("error answering callback query", "error", err)
+		}
+		return
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// actor returns user who sent the message or pressed the button. Returns nil for other updates and for payments,
+// so they are always recorded, pre checkout queries of banned users are rejected when they are checked
+func actor(update *models.Update) *models.User {
+	if update.PreCheckoutQuery != nil || (update.Message != nil && (update.Message.SuccessfulPayment != nil || update.Message.RefundedPayment != nil)) {
+		return nil
+	}
+	return sender(update)
+}
+
+// sender returns user who sent the update, nil if the update has no sender
+func sender(update *models.Update) *models.User {
+	switch {
+	case update.Message != nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return &update.CallbackQuery.From
+	case update.PreCheckoutQuery != nil:
+		return update.PreCheckoutQuery.From
+	}
+	return nil
+}
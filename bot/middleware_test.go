@@ -0,0 +1,99 @@
+package bot_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot"
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	h := bottest.New(t)
+	var calls []string
+	record := func(name string) bot.Middleware {
+		return func(next bot.Handler) bot.Handler {
+			return func(ctx context.Context, update *models.Update) {
+				calls = append(calls, name+" before")
+				next(ctx, update)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+	h.Bot.Use(record("first"), record("second"))
+
+	h.SendText(bottest.User(42, "en"), "/start")
+	want := []string{"first before", "second before", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("middlewares were run as %q, want %q", calls, want)
+	}
+}
+
+func TestMiddlewareStopsUpdate(t *testing.T) {
+	h := bottest.New(t)
+	h.Bot.Use(func(bot.Handler) bot.Handler {
+		return func(context.Context, *models.Update) {}
+	})
+	h.SendText(bottest.User(42, "en"), "/start")
+	if calls := h.Server.Calls("sendMessage"); len(calls) != 0 {
+		t.Fatalf("stopped update was answered: %v", calls)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		panic("generator bug")
+	})
+	h.SendText(user, "amigo")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.Apology["en"] {
+		t.Fatalf("panic was answered with %q", got)
+	}
+
+	//The bot keeps working
+	h.SendText(user, "/help")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.Help["en"] {
+		t.Fatalf("/help after panic replied with %q", got)
+	}
+}
+
+func TestBannedUser(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	stored := getUser(t, h, user.ID)
+	stored.BannedUntil = time.Now().Add(time.Hour).Unix()
+	if err := h.Store.UpdateUser(context.Background(), stored); err != nil {
+		t.Fatalf("error updating user: %v", err)
+	}
+	banned := fmt.Sprintf(h.Messages.Banned["en"], time.Unix(stored.BannedUntil, 0).UTC().Format("2006-01-02 15:04 UTC"))
+
+	h.Server.Reset()
+	h.SendText(user, "amigo")
+	if got := h.LastCall("sendMessage").Params["text"]; got != banned {
+		t.Fatalf("message of banned user was answered with %q", got)
+	}
+
+	//The user was just reminded of the ban, the button only stops loading
+	h.PressButton(user, 1, "level:1:B1")
+	if answer := h.LastCall("answerCallbackQuery"); answer.Params["text"] != "" {
+		t.Fatalf("button of banned user was answered with %v", answer.Params)
+	}
+	if len(h.Generator.Prompts()) != 0 || getUser(t, h, user.ID).Level != "A1" {
+		t.Fatal("update of banned user was processed")
+	}
+
+	//Payments are always recorded
+	pay(h, user, "plan:week", "charge-1")
+	if getUser(t, h, user.ID).PremiumUntil == 0 {
+		t.Fatal("payment of banned user was not recorded")
+	}
+}
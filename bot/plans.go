@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// lifetimeDuration is premium duration of the lifetime plan
+const lifetimeDuration = 100 * 365 * 24 * time.Hour
+
+// Plan is a premium plan users can buy
+type Plan struct {
+	ID    string `yaml:"id"`    //Sent in the invoice payload, so it should not change while old invoices can still be paid
+	Days  int    `yaml:"days"`  //Duration of premium, zero means lifetime
+	Price int    `yaml:"price"` //Price in Telegram Stars
+}
+
+// DefaultPlans is the plan catalog used when none is configured
+var DefaultPlans = []Plan{
+	{ID: "week", Days: 7, Price: 30},
+	{ID: "month", Days: 30, Price: 100},
+	{ID: "year", Days: 365, Price: 800},
+	{ID: "lifetime", Days: 0, Price: 2500},
+}
+
+// Prefixes of invoice payloads, they don't change together with the callbacks because invoices are kept in the chats
+const (
+	planPayload = "plan" //plan:<plan id>
+	giftPayload = "gift" //gift:<recipient chat id>:<plan id>
+)
+
+// legacyPlan is the only plan that existed before the catalog, invoices sent back then have "premium" payload
+var legacyPlan = Plan{ID: "premium", Days: 30, Price: 100}
+
+// Duration returns how long premium bought with the plan lasts
+func (p Plan) Duration() time.Duration {
+	if p.Days == 0 {
+		return lifetimeDuration
+	}
+	return time.Duration(p.Days) * 24 * time.Hour
+}
+
+// payload returns invoice payload of the plan
+func (p Plan) payload() string {
+	return planPayload + ":" + p.ID
+}
+
+// ParsePlans parses plan catalog written as "id=days:price" pairs separated by ";", e.g. "month=30:100;lifetime=0:2500"
+func ParsePlans(value string) ([]Plan, error) {
+	var plans []Plan
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		id, terms, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("plan %q must be written as id=days:price", entry)
+		}
+		days, price, ok := strings.Cut(terms, ":")
+		if !ok {
+			return nil, fmt.Errorf("plan %q must be written as id=days:price", entry)
+		}
+		plan := Plan{ID: strings.TrimSpace(id)}
+		var err error
+		if plan.Days, err = strconv.Atoi(strings.TrimSpace(days)); err != nil {
+			return nil, fmt.Errorf("invalid days of plan %q: %w", plan.ID, err)
+		}
+		if plan.Price, err = strconv.Atoi(strings.TrimSpace(price)); err != nil {
+			return nil, fmt.Errorf("invalid price of plan %q: %w", plan.ID, err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// plan returns plan from the catalog by its id
+func (b *Bot) plan(id string) (Plan, bool) {
+	for _, p := range b.cfg.Plans {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Plan{}, false
+}
+
+// parsePayload returns plan the invoice with the payload was sent for
+// and chat id of the user it is gifted to, zero if the payer gets the premium
+func (b *Bot) parsePayload(payload string) (Plan, int64, bool) {
+	switch payload {
+	case legacyPlan.ID:
+		return legacyPlan, 0, true
+	case subscriptionPayload:
+		plan, ok := b.subscriptionPlan()
+		return plan, 0, ok
+	}
+	if id, ok := strings.CutPrefix(payload, planPayload+":"); ok {
+		plan, ok := b.plan(id)
+		return plan, 0, ok
+	}
+
+	//gift:<recipient chat id>:<plan id>
+	gift, ok := strings.CutPrefix(payload, giftPayload+":")
+	if !ok {
+		return Plan{}, 0, false
+	}
+	recipient, id, ok := strings.Cut(gift, ":")
+	if !ok {
+		return Plan{}, 0, false
+	}
+	chatId, err := strconv.ParseInt(recipient, 10, 64)
+	if err != nil || chatId == 0 {
+		return Plan{}, 0, false
+	}
+	plan, ok := b.plan(id)
+	return plan, chatId, ok
+}
+
+// plansMarkup returns inline keyboard with a button for every plan of the catalog
+// and a button opening invoice of the subscription if it is enabled
+func (b *Bot) plansMarkup(ctx context.Context, lang string) *models.InlineKeyboardMarkup {
+	markup := &models.InlineKeyboardMarkup{}
+	if plan, ok := b.subscriptionPlan(); ok {
+		//Plans are still shown if the link can't be created
+		link, err := b.subscriptionLink(ctx, lang)
+		if err != nil {
+			b.log(ctx).Errorw("error creating subscription invoice link", "error", err)
+		} else {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{
+				{Text: b.messages.SubscribeButton[lang](plan.Price), URL: link},
+			})
+		}
+	}
+	for _, p := range b.cfg.Plans {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: b.messages.PlanButton[lang](p.Days, p.Price), CallbackData: planCallback.data(p.ID)},
+		})
+	}
+	return markup
+}
+
+// sendPlans sends the premium message with the plan selection keyboard.
+// Users who can start the premium trial get a button starting it above the plans
+func (b *Bot) sendPlans(ctx context.Context, user *models.User) error {
+	lang := language(user)
+	markup := b.plansMarkup(ctx, lang)
+	if b.trialAvailable(ctx, user.ID) {
+		markup.InlineKeyboard = append([][]models.InlineKeyboardButton{
+			{{Text: b.messages.TrialButton[lang](b.cfg.TrialDays), CallbackData: trialCallback.data()}},
+		}, markup.InlineKeyboard...)
+	}
+	_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{
+		ChatID:      user.ID,
+		Text:        b.messages.Premium[lang],
+		ReplyMarkup: markup,
+	})
+	return err
+}
+
+// processPlanCallback sends an invoice for the chosen plan to the user
+func (b *Bot) processPlanCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 {
+		return errStaleCallback
+	}
+	plan, ok := b.plan(args[0])
+	if !ok {
+		//The plan was removed from the catalog after the keyboard was sent
+		return errStaleCallback
+	}
+
+	//Send the invoice
+	if err := b.sendInvoice(ctx, &update.CallbackQuery.From, plan, plan.payload()); err != nil {
+		b.log(ctx).Errorw("failed to send invoice", "err", err)
+		return nil
+	}
+
+	//Delete message with inline keyboard
+	_, err := b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: update.CallbackQuery.From.ID, MessageID: update.CallbackQuery.Message.Message.ID})
+	if err != nil {
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package bot_test
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot"
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestParsePlans(t *testing.T) {
+	plans, err := bot.ParsePlans(" month = 30 : 100 ;lifetime=0:2500;")
+	if err != nil {
+		t.Fatalf("error parsing plans: %v", err)
+	}
+	want := []bot.Plan{{ID: "month", Days: 30, Price: 100}, {ID: "lifetime", Days: 0, Price: 2500}}
+	if !reflect.DeepEqual(plans, want) {
+		t.Fatalf("ParsePlans = %+v, want %+v", plans, want)
+	}
+
+	for _, value := range []string{"month", "month=30", "month=thirty:100", "month=30:free"} {
+		if _, err := bot.ParsePlans(value); err == nil {
+			t.Errorf("ParsePlans(%q) returned no error", value)
+		}
+	}
+}
+
+func TestPremiumPlans(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	h.SendText(user, "/premium")
+	plans := h.LastCall("sendMessage")
+	for _, p := range bot.DefaultPlans {
+		if !strings.Contains(plans.Params["reply_markup"], `"plan:1:`+p.ID+`"`) {
+			t.Fatalf("plan %s has no button: %s", p.ID, plans.Params["reply_markup"])
+		}
+	}
+
+	h.PressButton(user, plans.MessageID, "plan:1:year")
+	invoice := h.LastCall("sendInvoice")
+	if invoice.Params["payload"] != "plan:year" || !strings.Contains(invoice.Params["prices"], `"amount":800`) {
+		t.Fatalf("invoice params = %v", invoice.Params)
+	}
+}
+
+func TestLifetimePremium(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	pay(h, user, "plan:lifetime", "charge-1")
+
+	h.SendText(user, "/premium")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.LifetimePremium["en"] {
+		t.Fatalf("/premium with lifetime premium replied with %q", got)
+	}
+
+	//Long premium from other plans is not lifetime
+	other := bottest.User(43, "en")
+	setUp(t, h, other)
+	if err := h.Store.UpdateUserPremium(context.Background(), other.ID, time.Now().AddDate(80, 0, 0).Unix()); err != nil {
+		t.Fatalf("error updating premium: %v", err)
+	}
+	h.SendText(other, "/premium")
+	if got := h.LastCall("sendMessage").Params["text"]; got == h.Messages.LifetimePremium["en"] {
+		t.Fatal("/premium with 80 years of premium replied as lifetime")
+	}
+}
+
+func TestPreCheckout(t *testing.T) {
+	h := bottest.New(t)
+	user, banned := bottest.User(42, "en"), bottest.User(43, "en")
+	setUp(t, h, user)
+	setUp(t, h, banned)
+	stored := getUser(t, h, banned.ID)
+	stored.BannedUntil = time.Now().Add(time.Hour).Unix()
+	if err := h.Store.UpdateUser(context.Background(), stored); err != nil {
+		t.Fatalf("error updating user: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		from    *models.User
+		payload string
+		amount  int
+		err     string
+	}{
+		{name: "valid", from: user, payload: "plan:month", amount: 100},
+		{name: "changed price", from: user, payload: "plan:month", amount: 50, err: h.Messages.InvoiceOutdated["en"]},
+		{name: "removed plan", from: user, payload: "plan:decade", amount: 100, err: h.Messages.InvoiceOutdated["en"]},
+		{name: "legacy invoice", from: user, payload: "premium", amount: 100},
+		{name: "legacy invoice with changed price", from: user, payload: "premium", amount: 50, err: h.Messages.InvoiceOutdated["en"]},
+		{name: "subscription", from: user, payload: "subscription", amount: 90},
+		{name: "unknown user", from: bottest.User(44, "en"), payload: "plan:month", amount: 100, err: h.Messages.PaymentUserNotFound["en"]},
+		{name: "banned user", from: banned, payload: "plan:month", amount: 100, err: h.Messages.PaymentBanned["en"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.Send(&models.Update{PreCheckoutQuery: &models.PreCheckoutQuery{
+				ID:             tt.name,
+				From:           tt.from,
+				Currency:       "XTR",
+				TotalAmount:    tt.amount,
+				InvoicePayload: tt.payload,
+			}})
+			answer := h.LastCall("answerPreCheckoutQuery")
+			if answer.Params["pre_checkout_query_id"] != tt.name || answer.Params["ok"] != strconv.FormatBool(tt.err == "") || answer.Params["error_message"] != tt.err {
+				t.Fatalf("answer params = %v", answer.Params)
+			}
+		})
+	}
+}
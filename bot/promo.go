@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/go-telegram/bot/models"
+)
+
+// processRedeemCommand gives user premium for the promo code. Usage: /redeem <code>
+func (b *Bot) processRedeemCommand(ctx context.Context, update *models.Update, args string) {
+	lang := language(update.Message.From)
+	code := strings.ToUpper(strings.TrimSpace(args))
+	if code == "" {
+		b.reply(ctx, update, b.messages.RedeemUsage[lang])
+		return
+	}
+
+	promo, err := b.store.RedeemPromoCode(ctx, code, update.Message.Chat.ID, time.Now().Unix())
+	switch {
+	case errors.Is(err, db.ErrPromoCodeNotFound), errors.Is(err, db.ErrPromoCodeExpired):
+		b.reply(ctx, update, b.messages.PromoNotFound[lang])
+	case errors.Is(err, db.ErrPromoCodeUsedUp):
+		b.reply(ctx, update, b.messages.PromoUsedUp[lang])
+	case errors.Is(err, db.ErrPromoCodeRedeemed):
+		b.reply(ctx, update, b.messages.PromoRedeemed[lang])
+	case errors.Is(err, db.ErrUserNotFound):
+		b.reply(ctx, update, b.messages.PaymentUserNotFound[lang])
+	case err != nil:
+		b.log(ctx).Errorw("error redeeming promo code", "error", err)
+	default:
+		b.log(ctx).Infow("Promo code redeemed", "code", code, "chat id", update.Message.Chat.ID)
+		b.reply(ctx, update, b.messages.PromoApplied[lang](promo.Days))
+	}
+}
+
+// processPromoCommand creates promo code. Usage: /promo <code> <days> [max uses] [days valid], zero max uses means unlimited.
+// Only admins can use it
+func (b *Bot) processPromoCommand(ctx context.Context, update *models.Update, args string) {
+	//Pretend the command does not exist for everyone else
+	if !b.isAdmin(update.Message.From.ID) {
+		b.processUnknownCommand(ctx, update)
+		return
+	}
+	lang := language(update.Message.From)
+
+	//Parse arguments
+	fields := strings.Fields(args)
+	if len(fields) < 2 || len(fields) > 4 {
+		b.reply(ctx, update, b.messages.PromoUsage[lang])
+		return
+	}
+	numbers := make([]int, 3)
+	for i, field := range fields[1:] {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			b.reply(ctx, update, b.messages.PromoUsage[lang])
+			return
+		}
+		numbers[i] = n
+	}
+	now := time.Now()
+	promo := &db.PromoCode{Code: strings.ToUpper(fields[0]), Days: numbers[0], MaxUses: numbers[1], CreatedAt: now.Unix()}
+	if promo.Days == 0 {
+		b.reply(ctx, update, b.messages.PromoUsage[lang])
+		return
+	}
+	if numbers[2] != 0 {
+		promo.ExpiresAt = now.AddDate(0, 0, numbers[2]).Unix()
+	}
+
+	err := b.store.CreatePromoCode(ctx, promo)
+	if errors.Is(err, db.ErrPromoCodeExists) {
+		b.reply(ctx, update, b.messages.PromoExists[lang])
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error creating promo code", "error", err)
+		return
+	}
+	b.reply(ctx, update, fmt.Sprintf(b.messages.PromoCreated[lang], promo.Code))
+}
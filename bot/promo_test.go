@@ -0,0 +1,81 @@
+package bot_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+)
+
+func TestPromoCode(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.Admins = []int64{1}
+	h := bottest.NewWithConfig(t, cfg)
+	admin, user := bottest.User(1, "en"), bottest.User(42, "en")
+	setUp(t, h, user)
+
+	h.SendText(user, "/promo spring 7")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.UnknownCommand["en"] {
+		t.Fatalf("/promo of a user replied with %q", got)
+	}
+	h.SendText(admin, "/promo spring 7 1")
+	if got := h.LastCall("sendMessage").Params["text"]; got != fmt.Sprintf(h.Messages.PromoCreated["en"], "SPRING") {
+		t.Fatalf("/promo replied with %q", got)
+	}
+
+	//Codes are not case sensitive
+	h.SendText(user, "/redeem Spring")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.PromoApplied["en"](7) {
+		t.Fatalf("/redeem replied with %q", got)
+	}
+	if left := time.Until(time.Unix(getUser(t, h, user.ID).PremiumUntil, 0)); left < 6*24*time.Hour || left > 7*24*time.Hour {
+		t.Fatalf("promo code gave %v of premium", left)
+	}
+
+	h.SendText(user, "/redeem spring")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.PromoRedeemed["en"] {
+		t.Fatalf("second /redeem replied with %q", got)
+	}
+	h.SendText(user, "/redeem autumn")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.PromoNotFound["en"] {
+		t.Fatalf("/redeem of unknown code replied with %q", got)
+	}
+}
+
+func TestGift(t *testing.T) {
+	h := bottest.New(t)
+	payer, recipient := bottest.User(41, "en"), bottest.User(42, "en")
+	setUp(t, h, payer)
+	setUp(t, h, recipient)
+
+	//Recipient is found by the username ignoring case
+	h.SendText(payer, "/gift @USER42")
+	plans := h.LastCall("sendMessage")
+	if !strings.Contains(plans.Params["reply_markup"], `"gift:1:42:month"`) {
+		t.Fatalf("gift plans = %s", plans.Params["reply_markup"])
+	}
+	h.PressButton(payer, plans.MessageID, "gift:1:42:month")
+	if invoice := h.LastCall("sendInvoice"); invoice.Params["payload"] != "gift:42:month" {
+		t.Fatalf("gift invoice params = %v", invoice.Params)
+	}
+
+	h.Server.Reset()
+	pay(h, payer, "gift:42:month", "charge-1")
+	if getUser(t, h, payer.ID).PremiumUntil != 0 {
+		t.Fatal("payer got the gifted premium")
+	}
+	if left := time.Until(time.Unix(getUser(t, h, recipient.ID).PremiumUntil, 0)); left < 29*24*time.Hour {
+		t.Fatalf("gift gave %v of premium", left)
+	}
+	messages := h.Server.Calls("sendMessage")
+	if len(messages) != 2 || messages[0].Params["chat_id"] != "41" || messages[1].Params["chat_id"] != "42" {
+		t.Fatalf("gift messages = %v", messages)
+	}
+
+	h.SendText(payer, "/gift @nobody")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.GiftRecipientNotFound["en"] {
+		t.Fatalf("gift to unknown user replied with %q", got)
+	}
+}
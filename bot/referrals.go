@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// referralStartPrefix starts deep link parameter of user's invite link, e.g. ref_12345
+const referralStartPrefix = "ref_"
+
+// referrer returns chat id of the user whose invite link is the start parameter.
+// Returns zero if the parameter is not an invite link or the link is invalid
+func (b *Bot) referrer(ctx context.Context, chatId int64, parameter string) int64 {
+	id, ok := strings.CutPrefix(parameter, referralStartPrefix)
+	if !ok {
+		return 0
+	}
+	referrer, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || referrer == chatId {
+		return 0
+	}
+	if _, err := b.store.GetUser(ctx, referrer); err != nil {
+		if !errors.Is(err, db.ErrUserNotFound) {
+			b.log(ctx).Errorw("error getting user from the database", "error", err)
+		}
+		return 0
+	}
+	return referrer
+}
+
+// rewardReferral gives the referral bonus to the invited user and their referrer after the first sentence
+// of the invited user and notifies both of them
+func (b *Bot) rewardReferral(ctx context.Context, from *models.User, chatId int64) {
+	bonus := b.cfg.Referral
+	if bonus.Sentences == 0 && bonus.Days == 0 {
+		return
+	}
+	referrerId, err := b.store.RewardReferral(ctx, chatId, bonus, time.Now().Unix())
+	if errors.Is(err, db.ErrNoReferralBonus) {
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error rewarding referral", "error", err)
+		return
+	}
+	b.log(ctx).Infow("Referral rewarded", "chat id", chatId, "referrer", referrerId)
+
+	//Notify both users
+	lang := language(from)
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: fmt.Sprintf(b.messages.ReferralRewarded[lang], b.messages.ReferralBonus[lang](bonus.Sentences, bonus.Days))}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+	referrer, err := b.store.GetUser(ctx, referrerId)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return
+	}
+	lang = storedLanguage(referrer)
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: referrerId, Text: fmt.Sprintf(b.messages.ReferrerRewarded[lang], b.messages.ReferralBonus[lang](bonus.Sentences, bonus.Days))}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// processInviteCommand sends user their invite link and how many users they have invited
+func (b *Bot) processInviteCommand(ctx context.Context, update *models.Update) {
+	lang := language(update.Message.From)
+	link, err := b.deepLink(ctx, referralStartPrefix+strconv.FormatInt(update.Message.Chat.ID, 10))
+	if err != nil {
+		b.log(ctx).Errorw("error creating invite link", "error", err)
+		return
+	}
+	invited, err := b.store.CountReferrals(ctx, update.Message.Chat.ID)
+	if err != nil {
+		b.log(ctx).Errorw("error counting referrals", "error", err)
+		return
+	}
+	bonus := b.messages.ReferralBonus[lang](b.cfg.Referral.Sentences, b.cfg.Referral.Days)
+	b.reply(ctx, update, fmt.Sprintf(b.messages.Invite[lang], bonus, link, invited))
+}
@@ -0,0 +1,43 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+)
+
+func TestReferral(t *testing.T) {
+	h := bottest.New(t)
+	referrer, invited := bottest.User(41, "en"), bottest.User(42, "en")
+	setUp(t, h, referrer)
+
+	h.SendText(invited, "/start ref_41")
+	if got := getUser(t, h, invited.ID).ReferredBy; got != referrer.ID {
+		t.Fatalf("invited user is referred by %d", got)
+	}
+	h.SendText(invited, "/preferences")
+	h.PressButton(invited, h.LastCall("sendMessage").MessageID, "lang:1:es-ES")
+	h.PressButton(invited, h.LastCall("sendMessage").MessageID, "level:1:A1")
+
+	//The first sentence brings the bonus to both users, the second one doesn't
+	h.SendText(invited, "amigo")
+	h.SendText(invited, "casa")
+	for _, u := range []int64{referrer.ID, invited.ID} {
+		if got := getUser(t, h, u).BonusSentences; got != 20 {
+			t.Fatalf("user %d has %d bonus sentences", u, got)
+		}
+	}
+
+	h.SendText(referrer, "/invite")
+	if got := h.LastCall("sendMessage").Params["text"]; !strings.Contains(got, "ref_41") {
+		t.Fatalf("/invite replied with %q", got)
+	}
+
+	//Users can't invite themselves
+	self := bottest.User(43, "en")
+	h.SendText(self, "/start ref_43")
+	if got := getUser(t, h, self.ID).ReferredBy; got != 0 {
+		t.Fatalf("user who opened their own link is referred by %d", got)
+	}
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// processRefundCommand refunds Telegram Stars payment and takes back the premium it granted.
+// Usage: /refund <user chat id> <telegram payment charge id>. Only admins can use it
+func (b *Bot) processRefundCommand(ctx context.Context, update *models.Update, args string) {
+	//Pretend the command does not exist for everyone else
+	if !b.isAdmin(update.Message.From.ID) {
+		b.processUnknownCommand(ctx, update)
+		return
+	}
+	lang := language(update.Message.From)
+
+	//Parse arguments
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		b.reply(ctx, update, b.messages.RefundUsage[lang])
+		return
+	}
+	chatId, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		b.reply(ctx, update, b.messages.RefundUsage[lang])
+		return
+	}
+	chargeId := fields[1]
+
+	//Check that the payment belongs to the user and can be refunded
+	payment, err := b.store.GetPayment(ctx, chargeId)
+	if errors.Is(err, db.ErrPaymentNotFound) || err == nil && payment.ChatId != chatId {
+		b.reply(ctx, update, b.messages.RefundNotFound[lang])
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting payment", "error", err)
+		return
+	}
+	if payment.RefundedAt != 0 {
+		b.reply(ctx, update, b.messages.RefundAlreadyRefunded[lang])
+		return
+	}
+
+	//Return the stars
+	if _, err := b.b.RefundStarPayment(ctx, &tgbotapi.RefundStarPaymentParams{UserID: chatId, TelegramPaymentChargeID: chargeId}); err != nil {
+		b.log(ctx).Errorw("error refunding star payment", "error", err)
+		b.reply(ctx, update, fmt.Sprintf(b.messages.RefundFailed[lang], err))
+		return
+	}
+
+	//Take back premium and notify the user
+	if !b.refundPayment(ctx, chargeId) {
+		b.reply(ctx, update, fmt.Sprintf(b.messages.RefundNotRecorded[lang], chargeId))
+		return
+	}
+	b.reply(ctx, update, fmt.Sprintf(b.messages.RefundDone[lang], payment.Amount, chatId))
+}
+
+// processRefundedPayment records refund that telegram notified the user about.
+// Refunds made with /refund are already recorded, so they are skipped
+func (b *Bot) processRefundedPayment(ctx context.Context, update *models.Update) {
+	b.refundPayment(ctx, update.Message.RefundedPayment.TelegramPaymentChargeID)
+}
+
+// refundPayment marks the payment as refunded, takes back premium it granted and notifies the user.
+// Returns false if the refund could not be recorded
+func (b *Bot) refundPayment(ctx context.Context, chargeId string) bool {
+	payment, err := b.store.RefundPayment(ctx, chargeId, time.Now().Unix())
+	if errors.Is(err, db.ErrPaymentRefunded) {
+		return true
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error refunding payment", "charge id", chargeId, "error", err)
+		return false
+	}
+	b.log(ctx).Infow("Payment refunded", "chat id", payment.ChatId, "charge id", chargeId, "amount", payment.Amount)
+
+	//Notify the user in their language
+	user, err := b.store.GetUser(ctx, payment.ChatId)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return true
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: payment.ChatId, Text: fmt.Sprintf(b.messages.Refunded[storedLanguage(user)], payment.Amount)}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+	return true
+}
+
+// reply sends text to the chat the update came from
+func (b *Bot) reply(ctx context.Context, update *models.Update, text string) {
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.Message.Chat.ID, Text: text}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package bot_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestRefund(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.Admins = []int64{1}
+	h := bottest.NewWithConfig(t, cfg)
+	admin, user := bottest.User(1, "en"), bottest.User(42, "en")
+	setUp(t, h, user)
+	pay(h, user, "plan:month", "charge-1")
+
+	//Other users don't know about the command
+	h.SendText(user, "/refund 42 charge-1")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.UnknownCommand["en"] {
+		t.Fatalf("/refund of a user replied with %q", got)
+	}
+
+	h.SendText(admin, "/refund 42 charge-2")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.RefundNotFound["en"] {
+		t.Fatalf("refund of unknown payment replied with %q", got)
+	}
+
+	h.Server.Reset()
+	h.SendText(admin, "/refund 42 charge-1")
+	refund := h.LastCall("refundStarPayment")
+	if refund.Params["user_id"] != "42" || refund.Params["telegram_payment_charge_id"] != "charge-1" {
+		t.Fatalf("refundStarPayment params = %v", refund.Params)
+	}
+	if left := time.Until(time.Unix(getUser(t, h, user.ID).PremiumUntil, 0)); left > 0 {
+		t.Fatalf("refund left %v of premium", left)
+	}
+	messages := h.Server.Calls("sendMessage")
+	if len(messages) != 2 || messages[0].Params["text"] != fmt.Sprintf(h.Messages.Refunded["en"], 100) || messages[1].Params["text"] != fmt.Sprintf(h.Messages.RefundDone["en"], 100, user.ID) {
+		t.Fatalf("refund messages = %v", messages)
+	}
+
+	//Telegram notifies the user about the refund, it has already been recorded
+	h.Server.Reset()
+	h.Send(&models.Update{Message: &models.Message{
+		From:            user,
+		Chat:            models.Chat{ID: user.ID, Type: models.ChatTypePrivate},
+		RefundedPayment: &models.RefundedPayment{Currency: "XTR", TotalAmount: 100, TelegramPaymentChargeID: "charge-1"},
+	}})
+	if got := len(h.Server.Calls("sendMessage")); got != 0 {
+		t.Fatalf("user was notified about the same refund %d more times", got)
+	}
+
+	h.SendText(admin, "/refund 42 charge-1")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.RefundAlreadyRefunded["en"] {
+		t.Fatalf("second refund replied with %q", got)
+	}
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	premiumRemindersJob      = "premium_reminders"
+	premiumRemindersInterval = time.Hour
+)
+
+// premiumReminders are days before the end of premium or trial when users are reminded about it, the closest first
+var premiumReminders = []int{1, 3}
+
+// reminder describes notifications about the end of paid premium or of the premium trial
+type reminder struct {
+	ending   func(ctx context.Context, after, until int64) ([]*db.User, error) //Users whose premium or trial ends in the (after, until] interval
+	until    func(user *db.User) int64                                         //When premium or trial of the user ends
+	skip     func(user *db.User) bool                                          //User should not be notified
+	ended    map[string]string
+	expiring map[string]func(int, string) string
+	button   map[string]string
+	days     []int //Days before the end when users are reminded, the closest first
+}
+
+// remindPremium notifies users whose premium or trial has ended in the period and reminds users whose premium or trial ends in a few days.
+// If the bot was not running for a while, user gets only the most urgent of the notifications
+func (b *Bot) remindPremium(ctx context.Context, from, to time.Time) error {
+	reminders := []reminder{
+		{
+			ending:   b.store.UsersWithPremiumEnding,
+			until:    func(user *db.User) int64 { return user.PremiumUntil },
+			skip:     skipPremiumReminder,
+			ended:    b.messages.PremiumEnded,
+			expiring: b.messages.PremiumExpiring,
+			button:   b.messages.RenewPremium,
+			days:     premiumReminders,
+		},
+		{
+			ending:   b.store.UsersWithTrialEnding,
+			until:    func(user *db.User) int64 { return user.TrialUntil },
+			skip:     skipTrialReminder,
+			ended:    b.messages.TrialEnded,
+			expiring: b.messages.TrialExpiring,
+			button:   b.messages.BuyPremium,
+		},
+	}
+
+	//Reminders as early as the trial length would be sent right after the trial is started
+	for _, days := range premiumReminders {
+		if days < b.cfg.TrialDays {
+			reminders[1].days = append(reminders[1].days, days)
+		}
+	}
+	for _, r := range reminders {
+		if err := b.remind(ctx, r, from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remind sends notifications of the reminder to users whose premium or trial ends in the period or a few days after it
+func (b *Bot) remind(ctx context.Context, r reminder, from, to time.Time) error {
+	notified := make(map[int64]bool)
+
+	//Premium or trial that has ended
+	users, err := r.ending(ctx, from.Unix(), to.Unix())
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		notified[user.ChatId] = true
+		if r.skip(user) || remindedEarlier(user, from, to) {
+			continue
+		}
+		lang := storedLanguage(user)
+		b.sendReminder(ctx, user, r.ended[lang], r.button[lang], to)
+	}
+
+	//Premium or trial that ends in a few days
+	for _, days := range r.days {
+		before := time.Duration(days) * 24 * time.Hour
+		users, err := r.ending(ctx, from.Add(before).Unix(), to.Add(before).Unix())
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if notified[user.ChatId] {
+				continue
+			}
+			notified[user.ChatId] = true
+			if r.skip(user) || remindedEarlier(user, from, to) {
+				continue
+			}
+
+			//Round time left up to whole days, it is less than the reminder's days if the bot was not running
+			until := r.until(user)
+			left := int((time.Unix(until, 0).Sub(to) + 24*time.Hour - 1) / (24 * time.Hour))
+			lang := storedLanguage(user)
+			b.sendReminder(ctx, user, r.expiring[lang](left, formatDate(until)), r.button[lang], to)
+		}
+	}
+	return nil
+}
+
+// skipPremiumReminder returns true if user should not be reminded about the end of premium:
+// banned users and users whose subscription will renew premium automatically
+func skipPremiumReminder(user *db.User) bool {
+	renews := user.SubscriptionChargeID != "" && !user.SubscriptionCanceled && user.SubscriptionUntil >= user.PremiumUntil
+	return banned(user) || renews
+}
+
+// skipTrialReminder returns true if user should not be reminded about the end of the trial:
+// banned users and users who have bought premium lasting after the trial
+func skipTrialReminder(user *db.User) bool {
+	return banned(user) || user.PremiumUntil >= user.TrialUntil || subscribed(user)
+}
+
+// remindedEarlier returns true if user has already been reminded by a run over the same period that failed before it finished.
+// Users reminded by this run, which ends at to, can still get the other kind of reminder
+func remindedEarlier(user *db.User, from, to time.Time) bool {
+	return user.RemindedAt > from.Unix() && user.RemindedAt < to.Unix()
+}
+
+// sendReminder sends premium reminder with a button opening premium plans and records that the user was reminded in the period
+// ending at to. Errors are only logged, so users who have blocked the bot don't stop the rest from being notified
+func (b *Bot) sendReminder(ctx context.Context, user *db.User, text, button string, to time.Time) {
+	markup := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+		{{Text: button, CallbackData: premiumCallback.data()}},
+	}}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: user.ChatId, Text: text, ReplyMarkup: markup}); err != nil {
+		b.log(ctx).Errorw("error sending reminder", "chat id", user.ChatId, "error", err)
+		return
+	}
+	b.log(ctx).Infow("Reminder sent", "chat id", user.ChatId)
+	if err := b.store.SetUserRemindedAt(ctx, user.ChatId, to.Unix()); err != nil {
+		b.log(ctx).Errorw("error saving reminder", "chat id", user.ChatId, "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package bot_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+)
+
+// runScheduler runs the bot briefly, so the background jobs run once, and returns texts of the sent messages by chat id
+func runScheduler(t *testing.T, h *bottest.Harness) map[string]string {
+	t.Helper()
+	h.Server.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	h.Bot.Run(ctx)
+	texts := make(map[string]string)
+	for _, c := range h.Server.Calls("sendMessage") {
+		texts[c.Params["chat_id"]] = c.Params["text"]
+	}
+	return texts
+}
+
+func TestPremiumReminders(t *testing.T) {
+	h := bottest.New(t)
+	ctx := context.Background()
+	now := time.Now()
+	users := []*db.User{
+		{ChatId: 1, PremiumUntil: now.Add(-10 * time.Minute).Unix()},
+		{ChatId: 2, PremiumUntil: now.Add(72*time.Hour - 10*time.Minute).Unix()},
+		{ChatId: 3, PremiumUntil: now.Add(24*time.Hour - 10*time.Minute).Unix()},
+		{ChatId: 4, PremiumUntil: now.Add(48 * time.Hour).Unix()},
+		{ChatId: 5, PremiumUntil: now.Add(-10 * time.Minute).Unix(), SubscriptionChargeID: "charge", SubscriptionUntil: now.Add(-10 * time.Minute).Unix()},
+		{ChatId: 6, PremiumUntil: now.Add(-10 * time.Minute).Unix(), BannedUntil: now.Add(time.Hour).Unix()},
+		{ChatId: 7, TrialUntil: now.Add(-10 * time.Minute).Unix()},
+		{ChatId: 8, TrialUntil: now.Add(24*time.Hour - 10*time.Minute).Unix()},
+		{ChatId: 9, TrialUntil: now.Add(72*time.Hour - 10*time.Minute).Unix()},
+		{ChatId: 10, TrialUntil: now.Add(24*time.Hour - 10*time.Minute).Unix(), PremiumUntil: now.Add(40 * 24 * time.Hour).Unix()},
+	}
+	for _, user := range users {
+		user.Language = "en"
+		if err := h.Store.CreateUser(ctx, user); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+	}
+
+	//Texts of the expiring reminders are compared up to the date
+	beforeDate := func(text string) string {
+		return text[:strings.Index(text, "\x00")]
+	}
+	texts := runScheduler(t, h)
+	want := map[string]string{
+		"1": h.Messages.PremiumEnded["en"],
+		"2": beforeDate(h.Messages.PremiumExpiring["en"](3, "\x00")),
+		"3": beforeDate(h.Messages.PremiumExpiring["en"](1, "\x00")),
+		"7": h.Messages.TrialEnded["en"],
+		"8": beforeDate(h.Messages.TrialExpiring["en"](1, "\x00")),
+	}
+	if len(texts) != len(want) {
+		t.Errorf("reminders were sent to %d users, want %d", len(texts), len(want))
+	}
+	for chatId, text := range want {
+		if got := texts[chatId]; !strings.HasPrefix(got, text) {
+			t.Errorf("chat %s got %q, want %q", chatId, got, text)
+		}
+	}
+
+	//The next run covers only the time since the previous one
+	if texts := runScheduler(t, h); len(texts) != 0 {
+		t.Errorf("reminders were sent again: %v", texts)
+	}
+}
+
+func TestRemindersRetried(t *testing.T) {
+	h := bottest.New(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	//User 1 was reminded by a run over the same period that failed later
+	for _, user := range []*db.User{
+		{ChatId: 1, Language: "en", PremiumUntil: now.Add(-10 * time.Minute).Unix(), RemindedAt: now.Add(-5 * time.Minute).Unix()},
+		{ChatId: 2, Language: "en", PremiumUntil: now.Add(-10 * time.Minute).Unix()},
+	} {
+		if err := h.Store.CreateUser(ctx, user); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+	}
+	if texts := runScheduler(t, h); len(texts) != 1 || texts["2"] != h.Messages.PremiumEnded["en"] {
+		t.Fatalf("reminders after retry = %v", texts)
+	}
+	if getUser(t, h, 2).RemindedAt < now.Unix() {
+		t.Fatal("reminded user was not recorded")
+	}
+}
+
+func TestRemindersLeased(t *testing.T) {
+	h := bottest.New(t)
+	ctx := context.Background()
+	now := time.Now()
+	if err := h.Store.CreateUser(ctx, &db.User{ChatId: 1, Language: "en", PremiumUntil: now.Add(-10 * time.Minute).Unix()}); err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+
+	//Another instance of the bot is sending the reminders
+	if _, err := h.Store.LeaseJob(ctx, "premium_reminders", now.Unix(), now.Add(time.Hour).Unix()); err != nil {
+		t.Fatalf("error leasing job: %v", err)
+	}
+	if texts := runScheduler(t, h); len(texts) != 0 {
+		t.Fatalf("leased job sent reminders: %v", texts)
+	}
+}
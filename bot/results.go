@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/tts"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Actions of the buttons attached to generated sentences.
+// Regenerate replaces the card with a new sentence for free up to maxRegenerations times,
+// slow audio is always free and more examples creates a new card using one free sentence
+const (
+	resultRegenerateAction = "regen" //result:regen:<card id>
+	resultSlowAudioAction  = "slow"  //result:slow:<card id>
+	resultMoreAction       = "more"  //result:more:<card id>
+	maxRegenerations       = 3
+	slowSpeakingRate       = 0.7
+)
+
+// attachResultMarkup adds follow-up action buttons to the message with the card's sentences
+func (b *Bot) attachResultMarkup(ctx context.Context, from *models.User, chatId int64, messageID int, card *db.Card) {
+	lang := language(from)
+	button := func(text, action string) models.InlineKeyboardButton {
+		return models.InlineKeyboardButton{Text: text, CallbackData: resultCallback.data(action, card.ID)}
+	}
+	var buttons []models.InlineKeyboardButton
+	if card.Regenerated < maxRegenerations {
+		buttons = append(buttons, button(b.messages.RegenerateButton[lang], resultRegenerateAction))
+	}
+	buttons = append(buttons, button(b.messages.SlowAudioButton[lang], resultSlowAudioAction), button(b.messages.MoreExamplesButton[lang], resultMoreAction))
+
+	if _, err := b.b.EditMessageReplyMarkup(ctx, &tgbotapi.EditMessageReplyMarkupParams{
+		ChatID:      chatId,
+		MessageID:   messageID,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{buttons}},
+	}); err != nil {
+		b.log(ctx).Errorw("error editing message reply markup", "error", err)
+	}
+}
+
+// processResultCallback routes callback of the buttons attached to generated sentences
+func (b *Bot) processResultCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 2 {
+		return errStaleCallback
+	}
+	action, cardId := args[0], args[1]
+
+	//Get the card the button is attached to
+	card, err := b.store.GetCard(ctx, update.CallbackQuery.From.ID, cardId)
+	if errors.Is(err, db.ErrCardNotFound) {
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: update.CallbackQuery.From.ID, Text: b.messages.CardDeleted[language(&update.CallbackQuery.From)]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return nil
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting card", "error", err)
+		return nil
+	}
+
+	switch action {
+	case resultRegenerateAction:
+		b.processRegenerateCallback(ctx, update, card)
+	case resultSlowAudioAction:
+		b.processSlowAudioCallback(ctx, update, card)
+	case resultMoreAction:
+		b.generateCard(ctx, &update.CallbackQuery.From, update.CallbackQuery.From.ID, card.Word, card.Sentence)
+	default:
+		return errStaleCallback
+	}
+	return nil
+}
+
+// processRegenerateCallback replaces the card with a new sentence for the same word without using free sentences
+func (b *Bot) processRegenerateCallback(ctx context.Context, update *models.Update, card *db.Card) {
+	from := &update.CallbackQuery.From
+	if card.Regenerated >= maxRegenerations {
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: from.ID, Text: fmt.Sprintf(b.messages.RegenerateLimit[language(from)], maxRegenerations)}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return
+	}
+
+	user, err := b.store.GetUser(ctx, from.ID)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the db", "error", err)
+		return
+	}
+
+	//Generate a different sentence using the preferences the card was created with
+	user.SentenceLanguage, user.Level = card.Language, card.Level
+	res := b.generateSentences(ctx, from, from.ID, user, card.Word, card.Sentence)
+	if res == nil {
+		return
+	}
+	card.Sentence, card.Translation, card.Model = res.Sentence, res.Translation, res.Model
+	card.Regenerated++
+
+	//Send the new sentence and replace the old one in the history
+	messageID, err := b.sendCard(ctx, from, card)
+	if err != nil {
+		b.log(ctx).Errorw("error sending card", "error", err)
+		return
+	}
+	if err := b.store.UpdateCard(ctx, card); err != nil {
+		b.log(ctx).Errorw("error updating card", "error", err)
+		return
+	}
+
+	//Move the buttons from the old message to the new one
+	if _, err := b.b.EditMessageReplyMarkup(ctx, &tgbotapi.EditMessageReplyMarkupParams{ChatID: from.ID, MessageID: update.CallbackQuery.Message.Message.ID}); err != nil {
+		b.log(ctx).Errorw("error editing message reply markup", "error", err)
+	}
+	b.attachResultMarkup(ctx, from, from.ID, messageID, card)
+}
+
+// processSlowAudioCallback sends slowed down audio of the card's sentence
+func (b *Bot) processSlowAudioCallback(ctx context.Context, update *models.Update, card *db.Card) {
+	audio, err := b.tts.Synthesize(ctx, &tts.Request{Text: card.Sentence, LanguageCode: card.Language, SpeakingRate: slowSpeakingRate})
+	if err != nil {
+		b.log(ctx).Errorw("error generating audio", "error", err)
+		return
+	}
+	if _, err := b.b.SendDocument(ctx, &tgbotapi.SendDocumentParams{
+		ChatID:   update.CallbackQuery.From.ID,
+		Document: &models.InputFileUpload{Filename: card.Word + " (slow).mp3", Data: bytes.NewReader(audio)},
+	}); err != nil {
+		b.log(ctx).Errorw("error sending document", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package bot_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+)
+
+func TestResultButtons(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+	h.SendText(user, "amigo")
+	sentence := h.LastCall("sendMessage")
+	free := getUser(t, h, user.ID).FreeSentences
+
+	cards, err := h.Store.GetCards(context.Background(), user.ID)
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("cards = %+v, %v", cards, err)
+	}
+	id := cards[0].ID
+
+	//Slow audio is voiced with the lower speaking rate
+	h.PressButton(user, sentence.MessageID, "result:1:slow:"+id)
+	if f := h.LastCall("sendDocument").Files["document"]; !strings.Contains(f.Name, "slow") {
+		t.Fatalf("slow audio file is %q", f.Name)
+	}
+	if reqs := h.TTS.Requests(); reqs[len(reqs)-1].SpeakingRate != 0.7 {
+		t.Fatalf("slow audio request = %+v", reqs[len(reqs)-1])
+	}
+
+	//Regenerating replaces the card without using a free sentence
+	h.Generator.Respond(func(string) (*generator.Sentences, error) {
+		return &generator.Sentences{Sentence: "Mi amigo.", Translation: "My friend.", TargetWordForm: "amigo"}, nil
+	})
+	h.PressButton(user, sentence.MessageID, "result:1:regen:"+id)
+	if got := h.LastCall("sendMessage").Params["text"]; !strings.Contains(got, `Mi amigo\.`) {
+		t.Fatalf("regenerated sentence is %q", got)
+	}
+	if got := getUser(t, h, user.ID).FreeSentences; got != free {
+		t.Fatalf("regenerating used a free sentence: %d left, want %d", got, free)
+	}
+	cards, _ = h.Store.GetCards(context.Background(), user.ID)
+	if len(cards) != 1 || cards[0].Sentence != "Mi amigo." || cards[0].Regenerated != 1 {
+		t.Fatalf("cards after regenerating = %+v", cards)
+	}
+
+	//More examples creates a new card
+	h.PressButton(user, sentence.MessageID, "result:1:more:"+id)
+	if cards, _ := h.Store.GetCards(context.Background(), user.ID); len(cards) != 2 {
+		t.Fatalf("%d cards after more examples", len(cards))
+	}
+}
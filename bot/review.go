@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/srs"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	reviewShowAction  = "show"  //review:1:show:<card id>
+	reviewGradeAction = "grade" //review:1:grade:<card id>:<grade>
+)
+
+// processReviewCommand sends the first card that is due for review
+func (b *Bot) processReviewCommand(ctx context.Context, update *models.Update) {
+	b.sendNextReviewCard(ctx, update.Message.Chat.ID, language(update.Message.From), b.messages.ReviewEmpty)
+}
+
+// processReviewCallback routes review callback to the action handler
+func (b *Bot) processReviewCallback(ctx context.Context, update *models.Update, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == reviewShowAction:
+		b.processReviewShowCallback(ctx, update, args[1])
+	case len(args) == 3 && args[0] == reviewGradeAction:
+		grade, err := strconv.Atoi(args[2])
+		if err != nil || srs.Grade(grade) < srs.Again || srs.Grade(grade) > srs.Easy {
+			return errStaleCallback
+		}
+		b.processReviewGradeCallback(ctx, update, args[1], srs.Grade(grade))
+	default:
+		return errStaleCallback
+	}
+	return nil
+}
+
+// processReviewShowCallback reveals the sentence, sends its audio and asks user to grade the answer
+func (b *Bot) processReviewShowCallback(ctx context.Context, update *models.Update, cardId string) {
+	chatId := update.CallbackQuery.From.ID
+	lang := language(&update.CallbackQuery.From)
+	card, err := b.store.GetCard(ctx, chatId, cardId)
+	if err != nil {
+		b.log(ctx).Errorw("error getting card", "error", err)
+		return
+	}
+
+	//Show the sentence with the grade buttons
+	var buttons []models.InlineKeyboardButton
+	for grade, label := range b.messages.ReviewGrades[lang] {
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text:         label,
+			CallbackData: reviewCallback.data(reviewGradeAction, card.ID, strconv.Itoa(grade)),
+		})
+	}
+	if _, err := b.b.EditMessageText(ctx, &tgbotapi.EditMessageTextParams{
+		ChatID:      chatId,
+		MessageID:   update.CallbackQuery.Message.Message.ID,
+		Text:        b.reviewCardText(card, lang, true),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{buttons}},
+	}); err != nil {
+		b.log(ctx).Errorw("error editing message", "error", err)
+		return
+	}
+
+	//Send the audio again using the file already stored by telegram
+	if card.AudioFileID == "" {
+		return
+	}
+	if _, err := b.b.SendDocument(ctx, &tgbotapi.SendDocumentParams{ChatID: chatId, Document: &models.InputFileString{Data: card.AudioFileID}}); err != nil {
+		b.log(ctx).Errorw("error sending document", "error", err)
+	}
+}
+
+// processReviewGradeCallback reschedules the card according to the grade and sends the next due card
+func (b *Bot) processReviewGradeCallback(ctx context.Context, update *models.Update, cardId string, grade srs.Grade) {
+	chatId := update.CallbackQuery.From.ID
+	lang := language(&update.CallbackQuery.From)
+	card, err := b.store.GetCard(ctx, chatId, cardId)
+	if errors.Is(err, db.ErrCardNotFound) {
+		//Card was deleted from the history in the middle of the review
+		b.sendNextReviewCard(ctx, chatId, lang, b.messages.ReviewDone)
+		return
+	}
+	if err != nil {
+		b.log(ctx).Errorw("error getting card", "error", err)
+		return
+	}
+
+	//Calculate and save the new schedule
+	schedule := srs.Schedule{
+		Due:         time.Unix(card.Due, 0),
+		Interval:    card.Interval,
+		Ease:        card.Ease,
+		Repetitions: card.Repetitions,
+		Lapses:      card.Lapses,
+	}.Review(grade, time.Now())
+	card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses = schedule.Due.Unix(), schedule.Interval, schedule.Ease, schedule.Repetitions, schedule.Lapses
+	if err := b.store.UpdateCardSchedule(ctx, card); err != nil {
+		b.log(ctx).Errorw("error updating card schedule", "error", err)
+		return
+	}
+
+	//Remove the buttons and tell user when the card will be shown again
+	text := b.reviewCardText(card, lang, true) + "\n\n" + fmt.Sprintf(b.messages.ReviewNext[lang], schedule.Due.UTC().Format("2006-01-02 15:04"))
+	if _, err := b.b.EditMessageText(ctx, &tgbotapi.EditMessageTextParams{ChatID: chatId, MessageID: update.CallbackQuery.Message.Message.ID, Text: text}); err != nil {
+		b.log(ctx).Errorw("error editing message", "error", err)
+		return
+	}
+	b.sendNextReviewCard(ctx, chatId, lang, b.messages.ReviewDone)
+}
+
+// sendNextReviewCard sends the most overdue card with a button revealing the sentence.
+// If there are no cards to review the empty message is sent instead
+func (b *Bot) sendNextReviewCard(ctx context.Context, chatId int64, lang string, empty map[string]string) {
+	cards, err := b.store.DueCards(ctx, chatId, time.Now().Unix(), 1)
+	if err != nil {
+		b.log(ctx).Errorw("error getting due cards", "error", err)
+		return
+	}
+	params := &tgbotapi.SendMessageParams{ChatID: chatId, Text: empty[lang]}
+	if len(cards) > 0 {
+		params.Text = b.reviewCardText(cards[0], lang, false)
+		params.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: b.messages.ReviewShow[lang], CallbackData: reviewCallback.data(reviewShowAction, cards[0].ID)},
+		}}}
+	}
+	if _, err := b.b.SendMessage(ctx, params); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+	}
+}
+
+// reviewCardText returns the translation of the card and, if revealed, the sentence itself
+func (b *Bot) reviewCardText(card *db.Card, lang string, revealed bool) string {
+	text := fmt.Sprintf(b.messages.ReviewFront[lang], card.Translation)
+	if revealed {
+		text += fmt.Sprintf("\n\n%s\n(%s)", card.Sentence, card.Word)
+	}
+	return text
+}
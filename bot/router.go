@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// callbackType is a kind of inline buttons. Callback data of the buttons is "namespace:version:args" with args separated by colons.
+// Version is bumped when the meaning of the args changes, so buttons sent before that are rejected instead of being misread
+type callbackType struct {
+	namespace string
+	version   int
+}
+
+// Callback types of all inline buttons of the bot
+var (
+	premiumCallback       = callbackType{"premium", 1}
+	trialCallback         = callbackType{"trial", 1}
+	planCallback          = callbackType{"plan", 1}    //plan:1:<plan id>
+	subscriptionCallback  = callbackType{"sub", 1}     //sub:1:<cancel|resume>
+	giftCallback          = callbackType{"gift", 1}    //gift:1:<recipient chat id>:<plan id>
+	historyCallback       = callbackType{"history", 1} //history:1:<page>
+	historyDeleteCallback = callbackType{"hdel", 1}    //hdel:1:<page>:<card id>
+	reviewCallback        = callbackType{"review", 1}  //review:1:<action>:<card id>[:<grade>]
+	resultCallback        = callbackType{"result", 1}  //result:1:<action>:<card id>
+	timezoneCallback      = callbackType{"tz", 1}      //tz:1:<IANA timezone name>
+	levelCallback         = callbackType{"level", 1}   //level:1:<level>
+	languageCallback      = callbackType{"lang", 1}    //lang:1:<language code>
+)
+
+// data returns callback data of the button with the args
+func (t callbackType) data(args ...string) string {
+	return strings.Join(append([]string{t.namespace, strconv.Itoa(t.version)}, args...), ":")
+}
+
+// errStaleCallback is returned by callback handlers when args of the button are invalid or no longer valid, e.g. the plan was removed
+var errStaleCallback = errors.New("stale callback")
+
+// callbackHandler processes callback query with the args of its data
+type callbackHandler func(ctx context.Context, update *models.Update, args []string) error
+
+// callbackRoute is the handler registered for the current version of a callback type
+type callbackRoute struct {
+	version int
+	handler callbackHandler
+}
+
+// callbackRouter routes callback queries to the handlers registered for the namespaces of their data
+type callbackRouter struct {
+	routes map[string]callbackRoute
+}
+
+// newCallbackRouter creates router without handlers
+func newCallbackRouter() *callbackRouter {
+	return &callbackRouter{routes: make(map[string]callbackRoute)}
+}
+
+// handle registers handler of the callback type, registering the same namespace twice is a programming error
+func (r *callbackRouter) handle(t callbackType, handler callbackHandler) {
+	if _, ok := r.routes[t.namespace]; ok {
+		panic("callback namespace " + t.namespace + " is registered twice")
+	}
+	r.routes[t.namespace] = callbackRoute{version: t.version, handler: handler}
+}
+
+// route returns handler and args of the callback data.
+// Returns false if the namespace is unknown or the data was created for another version of the callback type
+func (r *callbackRouter) route(data string) (callbackHandler, []string, bool) {
+	fields := strings.Split(data, ":")
+	if len(fields) < 2 {
+		return nil, nil, false
+	}
+	route, ok := r.routes[fields[0]]
+	if !ok || fields[1] != strconv.Itoa(route.version) {
+		return nil, nil, false
+	}
+	return route.handler, fields[2:], true
+}
+
+// callbacks returns router with handlers of all inline buttons of the bot
+func (b *Bot) callbacks() *callbackRouter {
+	r := newCallbackRouter()
+	r.handle(premiumCallback, b.processPremiumCallback)
+	r.handle(trialCallback, b.processTrialCallback)
+	r.handle(planCallback, b.processPlanCallback)
+	r.handle(giftCallback, b.processGiftCallback)
+	r.handle(subscriptionCallback, b.processSubscriptionCallback)
+	r.handle(historyCallback, b.processHistoryCallback)
+	r.handle(historyDeleteCallback, b.processHistoryDeleteCallback)
+	r.handle(timezoneCallback, b.processTimezoneCallback)
+	r.handle(resultCallback, b.processResultCallback)
+	r.handle(reviewCallback, b.processReviewCallback)
+	r.handle(levelCallback, b.processLevelCallback)
+	r.handle(languageCallback, b.processLanguageCallback)
+	return r
+}
+
+// processCallbackQuery routes callback to the handler functions. Every callback query is answered, otherwise
+// the client keeps showing progress on the button. Unknown and stale buttons are answered with a toast
+func (b *Bot) processCallbackQuery(ctx context.Context, update *models.Update) {
+	b.log(ctx).Infow("Callback Query Received", "from", update.CallbackQuery.From.Username, "callback data", update.CallbackQuery.Data)
+	answer := &tgbotapi.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID}
+	handler, args, ok := b.router.route(update.CallbackQuery.Data)
+	if ok {
+		err := handler(ctx, update, args)
+		if errors.Is(err, errStaleCallback) {
+			ok = false
+		} else if err != nil {
+			b.log(ctx).Errorw("error processing callback", "data", update.CallbackQuery.Data, "error", err)
+		}
+	}
+	if !ok {
+		b.log(ctx).Infow("Stale callback", "from", update.CallbackQuery.From.Username, "callback data", update.CallbackQuery.Data)
+		answer.Text = b.messages.ButtonOutdated[language(&update.CallbackQuery.From)]
+	}
+	if _, err := b.b.AnswerCallbackQuery(ctx, answer); err != nil {
+		b.log(ctx).Errorw("error answering callback query", "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestCallbackRouter(t *testing.T) {
+	var called string
+	handler := func(name string) callbackHandler {
+		return func(context.Context, *models.Update, []string) error {
+			called = name
+			return nil
+		}
+	}
+	r := newCallbackRouter()
+	r.handle(callbackType{"plan", 1}, handler("plan"))
+	r.handle(callbackType{"hdel", 2}, handler("hdel"))
+
+	tests := []struct {
+		data    string
+		handler string
+		args    []string
+		ok      bool
+	}{
+		{data: "plan:1:year", handler: "plan", args: []string{"year"}, ok: true},
+		{data: "plan:1", handler: "plan", args: []string{}, ok: true},
+		{data: "hdel:2:3:card:id", handler: "hdel", args: []string{"3", "card", "id"}, ok: true},
+		{data: "hdel:1:3:card", ok: false}, //Button of the previous version
+		{data: "gift:1:7:week", ok: false}, //Unknown namespace
+		{data: "plan", ok: false},
+		{data: "A1", ok: false}, //Buttons sent before callback data had namespaces
+		{data: "", ok: false},
+	}
+	for _, tt := range tests {
+		called = ""
+		h, args, ok := r.route(tt.data)
+		if ok != tt.ok {
+			t.Errorf("route(%q) ok = %v, want %v", tt.data, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := h(context.Background(), nil, args); err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if called != tt.handler || !slices.Equal(args, tt.args) {
+			t.Errorf("route(%q) = %s%q, want %s%q", tt.data, called, args, tt.handler, tt.args)
+		}
+	}
+}
+
+func TestCallbackRouterDuplicateNamespace(t *testing.T) {
+	r := newCallbackRouter()
+	r.handle(callbackType{"plan", 1}, nil)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering namespace twice did not panic")
+		}
+	}()
+	r.handle(callbackType{"plan", 2}, nil)
+}
+
+func TestCallbackData(t *testing.T) {
+	if got := historyDeleteCallback.data("2", "abc"); got != "hdel:1:2:abc" {
+		t.Fatalf("data = %q", got)
+	}
+	if got := premiumCallback.data(); got != "premium:1" {
+		t.Fatalf("data without args = %q", got)
+	}
+}
+
+func TestCallbacksRegistered(t *testing.T) {
+	//Every button the bot sends must be routed
+	r := (&Bot{}).callbacks()
+	for _, ct := range []callbackType{premiumCallback, trialCallback, planCallback, subscriptionCallback, giftCallback, historyCallback,
+		historyDeleteCallback, reviewCallback, resultCallback, timezoneCallback, levelCallback, languageCallback} {
+		if _, _, ok := r.route(ct.data()); !ok {
+			t.Errorf("callback %s is not routed", ct.namespace)
+		}
+	}
+}
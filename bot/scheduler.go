@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"go.uber.org/zap"
+)
+
+// job is a background task run periodically by the scheduler
+type job struct {
+	name     string
+	interval time.Duration
+	//run processes the period since the previous run, the same period is processed again if it returns an error
+	run func(ctx context.Context, from, to time.Time) error
+}
+
+// scheduler runs background jobs periodically. Time of the last successful run of every job is kept in the store,
+// so the period when the bot was not running is processed after a restart. Every instance of the bot runs a scheduler,
+// jobs are leased in the store, so each period is processed by a single instance
+type scheduler struct {
+	store  db.JobStore
+	logger *zap.SugaredLogger
+	jobs   []job
+	wg     sync.WaitGroup
+}
+
+// newScheduler creates scheduler running the jobs
+func newScheduler(store db.JobStore, logger *zap.SugaredLogger, jobs ...job) *scheduler {
+	return &scheduler{store: store, logger: logger, jobs: jobs}
+}
+
+// start runs every job in its own goroutine until ctx is done
+func (s *scheduler) start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+}
+
+// wait waits until the jobs stop after the context passed to start is done
+func (s *scheduler) wait() {
+	s.wg.Wait()
+}
+
+// loop runs the job right away and then every interval until ctx is done
+func (s *scheduler) loop(ctx context.Context, j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.runJob(ctx, j, time.Now()); err != nil {
+			s.logger.Errorw("error running job", "job", j.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJob leases the job, runs it for the period since its last successful run, saves the time of this run and releases the lease.
+// The first run of the job covers a single interval. Jobs leased by another instance are skipped
+func (s *scheduler) runJob(ctx context.Context, j job, now time.Time) error {
+	//The lease expires after an interval, so the job is picked up again if the instance holding it stops mid-run
+	state, err := s.store.LeaseJob(ctx, j.name, now.Unix(), now.Add(j.interval).Unix())
+	if errors.Is(err, db.ErrJobLeased) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	from := now.Add(-j.interval)
+	if state.LastRun != 0 {
+		from = time.Unix(state.LastRun, 0)
+	}
+
+	//Clock of the previous instance may have been ahead
+	lastRun := state.LastRun
+	if from.Before(now) {
+		if err := j.run(ctx, from, now); err != nil {
+			//Release the lease, so the same period is processed again by the next run of any instance
+			if err := s.store.SaveJobState(context.WithoutCancel(ctx), &db.JobState{Name: j.name, LastRun: lastRun}); err != nil {
+				s.logger.Errorw("error releasing job", "job", j.name, "error", err)
+			}
+			return err
+		}
+		lastRun = now.Unix()
+	}
+	return s.store.SaveJobState(context.WithoutCancel(ctx), &db.JobState{Name: j.name, LastRun: lastRun})
+}
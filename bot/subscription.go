@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	subscriptionPayload = "subscription"
+	subscriptionPeriod  = 30 * 24 * time.Hour //The only period telegram supports for subscriptions
+	cancelSubscription  = "cancel"
+	resumeSubscription  = "resume"
+)
+
+// subscriptionPlan returns plan describing a single period of the recurring subscription.
+// Returns false if subscriptions are disabled
+func (b *Bot) subscriptionPlan() (Plan, bool) {
+	if b.cfg.SubscriptionPrice <= 0 {
+		return Plan{}, false
+	}
+	return Plan{ID: subscriptionPayload, Days: int(subscriptionPeriod / (24 * time.Hour)), Price: b.cfg.SubscriptionPrice}, true
+}
+
+// subscriptionLink returns invoice link of the recurring subscription.
+// The link is not bound to the user, so it is created once and reused
+func (b *Bot) subscriptionLink(ctx context.Context, lang string) (string, error) {
+	b.subscriptionMu.Lock()
+	defer b.subscriptionMu.Unlock()
+	if link, ok := b.subscriptionLinks[lang]; ok {
+		return link, nil
+	}
+
+	plan, _ := b.subscriptionPlan()
+	link, err := b.b.CreateInvoiceLink(ctx, &tgbotapi.CreateInvoiceLinkParams{
+		Title:              b.messages.SubscriptionTitle[lang],
+		Description:        b.messages.PremiumDescription[lang],
+		Payload:            subscriptionPayload,
+		Currency:           "XTR", // Telegram stars
+		Prices:             []models.LabeledPrice{{Label: b.messages.SubscriptionTitle[lang], Amount: plan.Price}},
+		SubscriptionPeriod: int(subscriptionPeriod / time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+	b.subscriptionLinks[lang] = link
+	return link, nil
+}
+
+// subscribed returns true if user's subscription is paid for the current period
+func subscribed(user *db.User) bool {
+	return user.SubscriptionChargeID != "" && user.SubscriptionUntil > time.Now().Unix()
+}
+
+// processSubscriptionPayment records the first or a renewal payment of the subscription and tells user when it is renewed next
+func (b *Bot) processSubscriptionPayment(ctx context.Context, update *models.Update) error {
+	payment := update.Message.SuccessfulPayment
+	chatId := update.Message.Chat.ID
+
+	//Keep charge id of the first payment, it identifies the subscription when canceling it
+	chargeId := payment.TelegramPaymentChargeID
+	if !payment.IsFirstRecurring {
+		user, err := b.store.GetUser(ctx, chatId)
+		if err != nil {
+			return err
+		}
+		if user.SubscriptionChargeID != "" {
+			chargeId = user.SubscriptionChargeID
+		}
+	}
+	until := int64(payment.SubscriptionExpirationDate)
+	if err := b.store.SetUserSubscription(ctx, chatId, chargeId, until); err != nil {
+		return err
+	}
+
+	lang := language(update.Message.From)
+	msg := b.messages.SubscriptionRenewed[lang]
+	if payment.IsFirstRecurring {
+		msg = b.messages.SubscriptionStarted[lang]
+	}
+	_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: fmt.Sprintf(msg, formatDate(until))})
+	return err
+}
+
+// sendSubscription sends status of user's subscription with a button to cancel or resume it
+func (b *Bot) sendSubscription(ctx context.Context, user *db.User, lang string) error {
+	text, markup := b.subscriptionStatus(user, lang)
+	_, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: user.ChatId, Text: text, ReplyMarkup: markup})
+	return err
+}
+
+// subscriptionStatus returns text and markup of the message with status of user's subscription
+func (b *Bot) subscriptionStatus(user *db.User, lang string) (string, *models.InlineKeyboardMarkup) {
+	if user.SubscriptionCanceled {
+		return fmt.Sprintf(b.messages.SubscriptionCanceled[lang], formatDate(user.SubscriptionUntil)), &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: b.messages.ResumeSubscription[lang], CallbackData: subscriptionCallback.data(resumeSubscription)}},
+		}}
+	}
+	return fmt.Sprintf(b.messages.SubscriptionActive[lang], formatDate(user.SubscriptionUntil)), &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+		{{Text: b.messages.CancelSubscription[lang], CallbackData: subscriptionCallback.data(cancelSubscription)}},
+	}}
+}
+
+// processSubscriptionCallback cancels or resumes user's subscription and updates the status message
+func (b *Bot) processSubscriptionCallback(ctx context.Context, update *models.Update, args []string) error {
+	if len(args) != 1 || (args[0] != cancelSubscription && args[0] != resumeSubscription) {
+		return errStaleCallback
+	}
+	action := args[0]
+	chatId := update.CallbackQuery.From.ID
+	lang := language(&update.CallbackQuery.From)
+
+	user, err := b.store.GetUser(ctx, chatId)
+	if err != nil {
+		b.log(ctx).Errorw("error getting user from the database", "error", err)
+		return nil
+	}
+	if !subscribed(user) {
+		//The subscription has ended since the status was sent
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: b.messages.SubscriptionEnded[lang]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return nil
+	}
+
+	//Ask telegram to stop or continue charging the user, then remember it
+	canceled := action == cancelSubscription
+	if _, err := b.b.EditUserStarSubscription(ctx, &tgbotapi.EditUserStarSubscriptionParams{UserID: chatId, TelegramPaymentChargeID: user.SubscriptionChargeID, IsCanceled: canceled}); err != nil {
+		b.log(ctx).Errorw("error editing star subscription", "error", err)
+		if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: b.messages.FailedPayment[lang]}); err != nil {
+			b.log(ctx).Errorw("error sending message", "error", err)
+		}
+		return nil
+	}
+	if err := b.store.SetUserSubscriptionCanceled(ctx, chatId, canceled); err != nil {
+		b.log(ctx).Errorw("error updating user subscription", "error", err)
+		return nil
+	}
+	user.SubscriptionCanceled = canceled
+
+	//Show the new status in place of the old one
+	text, markup := b.subscriptionStatus(user, lang)
+	if _, err := b.b.EditMessageText(ctx, &tgbotapi.EditMessageTextParams{ChatID: chatId, MessageID: update.CallbackQuery.Message.Message.ID, Text: text, ReplyMarkup: markup}); err != nil {
+		b.log(ctx).Errorw("error editing message", "error", err)
+	}
+	return nil
+}
+
+// formatDate formats unix time as a date shown to users
+func formatDate(t int64) string {
+	return time.Unix(t, 0).UTC().Format(time.DateOnly)
+}
+
+// formatTime formats unix time as a date and time shown to users
+func formatTime(t int64) string {
+	return time.Unix(t, 0).UTC().Format("2006-01-02 15:04 UTC")
+}
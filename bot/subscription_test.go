@@ -0,0 +1,77 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+	"github.com/go-telegram/bot/models"
+)
+
+// paySubscription sends the update telegram sends after the user paid a period of the subscription
+func paySubscription(h *bottest.Harness, user *models.User, chargeId string, first bool, until time.Time) {
+	h.Send(&models.Update{Message: &models.Message{
+		From: user,
+		Chat: models.Chat{ID: user.ID, Type: models.ChatTypePrivate},
+		SuccessfulPayment: &models.SuccessfulPayment{
+			Currency:                   "XTR",
+			TotalAmount:                90,
+			InvoicePayload:             "subscription",
+			TelegramPaymentChargeID:    chargeId,
+			SubscriptionExpirationDate: int(until.Unix()),
+			IsRecurring:                true,
+			IsFirstRecurring:           first,
+		},
+	}})
+}
+
+func TestSubscription(t *testing.T) {
+	h := bottest.New(t)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	//The invoice link is created once and reused
+	h.SendText(user, "/premium")
+	h.SendText(user, "/premium")
+	if got := len(h.Server.Calls("createInvoiceLink")); got != 1 {
+		t.Fatalf("invoice link was created %d times", got)
+	}
+	if markup := h.LastCall("sendMessage").Params["reply_markup"]; !strings.Contains(markup, `"url":"https://t.me/$test-invoice"`) {
+		t.Fatalf("plans have no subscription button: %s", markup)
+	}
+
+	until := time.Now().Add(30 * 24 * time.Hour)
+	paySubscription(h, user, "charge-1", true, until)
+	stored := getUser(t, h, user.ID)
+	if stored.SubscriptionChargeID != "charge-1" || stored.SubscriptionUntil != until.Unix() {
+		t.Fatalf("subscription was not recorded: %+v", stored)
+	}
+	if left := time.Until(time.Unix(stored.PremiumUntil, 0)); left < 29*24*time.Hour {
+		t.Fatalf("subscription gave %v of premium", left)
+	}
+
+	//Renewals keep the charge id of the first payment
+	until = until.Add(30 * 24 * time.Hour)
+	paySubscription(h, user, "charge-2", false, until)
+	if stored := getUser(t, h, user.ID); stored.SubscriptionChargeID != "charge-1" || stored.SubscriptionUntil != until.Unix() {
+		t.Fatalf("renewal was not recorded: %+v", stored)
+	}
+
+	h.SendText(user, "/premium")
+	status := h.LastCall("sendMessage")
+	if !strings.Contains(status.Params["reply_markup"], `"sub:1:cancel"`) {
+		t.Fatalf("subscription status has no cancel button: %s", status.Params["reply_markup"])
+	}
+	h.PressButton(user, status.MessageID, "sub:1:cancel")
+	edit := h.LastCall("editUserStarSubscription")
+	if edit.Params["telegram_payment_charge_id"] != "charge-1" || edit.Params["is_canceled"] != "true" {
+		t.Fatalf("editUserStarSubscription params = %v", edit.Params)
+	}
+	if !getUser(t, h, user.ID).SubscriptionCanceled {
+		t.Fatal("subscription was not canceled")
+	}
+	if markup := h.LastCall("editMessageText").Params["reply_markup"]; !strings.Contains(markup, `"sub:1:resume"`) {
+		t.Fatalf("canceled subscription has no resume button: %s", markup)
+	}
+}
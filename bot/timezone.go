@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// timezones user can choose in the preferences
+var timezones = []string{
+	"UTC",
+	"America/Los_Angeles",
+	"America/New_York",
+	"America/Sao_Paulo",
+	"Europe/London",
+	"Europe/Berlin",
+	"Europe/Moscow",
+	"Asia/Dubai",
+	"Asia/Almaty",
+	"Asia/Kolkata",
+	"Asia/Shanghai",
+	"Asia/Tokyo",
+	"Australia/Sydney",
+}
+
+// timezonesMarkup returns inline keyboard markup for selecting timezone with the current UTC offsets
+func timezonesMarkup(now time.Time) *models.InlineKeyboardMarkup {
+	markup := &models.InlineKeyboardMarkup{}
+	for _, timezone := range timezones {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			continue
+		}
+
+		//Show the city and the offset, e.g. "New York (UTC-05:00)"
+		_, offset := now.In(loc).Zone()
+		sign := "+"
+		if offset < 0 {
+			sign, offset = "-", -offset
+		}
+		city := strings.ReplaceAll(timezone[strings.LastIndex(timezone, "/")+1:], "_", " ")
+		button := models.InlineKeyboardButton{
+			Text:         fmt.Sprintf("%s (UTC%s%02d:%02d)", city, sign, offset/3600, offset%3600/60),
+			CallbackData: timezoneCallback.data(timezone),
+		}
+
+		//Two buttons per row
+		rows := markup.InlineKeyboard
+		if len(rows) == 0 || len(rows[len(rows)-1]) == 2 {
+			markup.InlineKeyboard = append(rows, []models.InlineKeyboardButton{button})
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	return markup
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	tgbotapi "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// trialAvailable returns true if the trial is enabled and the user has neither used it nor ever paid for premium
+func (b *Bot) trialAvailable(ctx context.Context, chatId int64) bool {
+	if b.cfg.TrialDays <= 0 {
+		return false
+	}
+	user, err := b.store.GetUser(ctx, chatId)
+	if err != nil {
+		if !errors.Is(err, db.ErrUserNotFound) {
+			b.log(ctx).Errorw("error getting user from the database", "error", err)
+		}
+		return false
+	}
+	return user.TrialUntil == 0 && !user.Paid && user.SubscriptionChargeID == "" && !banned(user)
+}
+
+// processTrialCallback starts premium trial of the user
+func (b *Bot) processTrialCallback(ctx context.Context, update *models.Update, _ []string) error {
+	if b.cfg.TrialDays <= 0 {
+		//The trial was disabled after the keyboard was sent
+		return errStaleCallback
+	}
+	chatId := update.CallbackQuery.From.ID
+	lang := language(&update.CallbackQuery.From)
+
+	//The store checks again that the trial has not been used, so pressing the button twice gives it once
+	until := time.Now().AddDate(0, 0, b.cfg.TrialDays).Unix()
+	err := b.store.StartTrial(ctx, chatId, until)
+	var text string
+	switch {
+	case errors.Is(err, db.ErrTrialUnavailable):
+		text = b.messages.TrialUnavailable[lang]
+	case errors.Is(err, db.ErrUserNotFound):
+		text = b.messages.PaymentUserNotFound[lang]
+	case err != nil:
+		b.log(ctx).Errorw("error starting trial", "error", err)
+		return nil
+	default:
+		b.log(ctx).Infow("Trial started", "chat id", chatId, "until", until)
+		text = b.messages.TrialStarted[lang](b.cfg.TrialDays, formatDate(until))
+	}
+	if _, err := b.b.SendMessage(ctx, &tgbotapi.SendMessageParams{ChatID: chatId, Text: text}); err != nil {
+		b.log(ctx).Errorw("error sending message", "error", err)
+		return nil
+	}
+
+	//Delete message with inline keyboard
+	_, err = b.b.DeleteMessage(ctx, &tgbotapi.DeleteMessageParams{ChatID: chatId, MessageID: update.CallbackQuery.Message.Message.ID})
+	if err != nil {
+		b.log(ctx).Errorw("failed to delete message", "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package bot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot/bottest"
+)
+
+func TestTrial(t *testing.T) {
+	cfg := bottest.DefaultConfig()
+	cfg.Quota.DailyAllowance = 0
+	h := bottest.NewWithConfig(t, cfg)
+	user := bottest.User(42, "en")
+	setUp(t, h, user)
+
+	h.SendText(user, "/premium")
+	plans := h.LastCall("sendMessage")
+	if !strings.Contains(plans.Params["reply_markup"], `"trial:1"`) {
+		t.Fatalf("plans have no trial button: %s", plans.Params["reply_markup"])
+	}
+	h.PressButton(user, plans.MessageID, "trial:1")
+	if left := time.Until(time.Unix(getUser(t, h, user.ID).TrialUntil, 0)); left < 2*24*time.Hour || left > 3*24*time.Hour {
+		t.Fatalf("trial gave %v of premium", left)
+	}
+
+	//Trial users generate sentences like premium ones
+	h.Server.Reset()
+	h.SendText(user, "amigo")
+	if len(h.Server.Calls("sendDocument")) != 1 {
+		t.Fatal("trial user could not generate a sentence")
+	}
+
+	//The trial is given once
+	h.PressButton(user, plans.MessageID, "trial:1")
+	if got := h.LastCall("sendMessage").Params["text"]; got != h.Messages.TrialUnavailable["en"] {
+		t.Fatalf("second trial replied with %q", got)
+	}
+	h.SendText(user, "/premium")
+	if markup := h.LastCall("sendMessage").Params["reply_markup"]; strings.Contains(markup, `"trial:1"`) {
+		t.Fatalf("trial is offered again: %s", markup)
+	}
+}
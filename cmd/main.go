@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/dafraer/sentence-gen-tg-bot/text"
 	"github.com/dafraer/sentence-gen-tg-bot/tts"
 	"go.uber.org/zap"
@@ -9,41 +10,48 @@ import (
 	"os/signal"
 
 	"github.com/dafraer/sentence-gen-tg-bot/bot"
+	"github.com/dafraer/sentence-gen-tg-bot/config"
 	"github.com/dafraer/sentence-gen-tg-bot/db"
 	"github.com/dafraer/sentence-gen-tg-bot/gemini"
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+	"github.com/dafraer/sentence-gen-tg-bot/openai"
 )
 
 func main() {
-	if len(os.Args) < 4 {
-		panic("telegram bot token, gemini API key and Narakeet API key must be passed as arguments")
+	//Load configuration from the file, environment and flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		panic(err)
 	}
-	token := os.Args[1]
-	geminiAPIKey := os.Args[2]
-	narakeetAPIKey := os.Args[3]
 
 	//Declare context that is marked Done when os.Interrupt is called
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	//Create firestore client
-	store, err := db.New(ctx)
+	//Create store using the configured backend
+	store, err := db.New(ctx, cfg.Store)
 	if err != nil {
 		panic(err)
 	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			panic(err)
+		}
+	}()
 
-	//Create gemini client
-	geminiClient, err := gemini.New(ctx, geminiAPIKey)
+	//Create sentence generator
+	sentenceGenerator, err := newGenerator(ctx, cfg.LLM)
 	if err != nil {
 		panic(err)
 	}
 	defer func() {
-		if err := geminiClient.Close(); err != nil {
+		if err := sentenceGenerator.Close(); err != nil {
 			panic(err)
 		}
 	}()
 
 	//Create tts client
-	ttsClient, err := tts.New(ctx, narakeetAPIKey)
+	ttsClient, err := tts.New(ctx, cfg.TTS)
 	if err != nil {
 		panic(err)
 	}
@@ -64,18 +72,29 @@ func main() {
 	sugar := logger.Sugar()
 
 	//Create bot
-	myBot, err := bot.New(token, store, geminiClient, ttsClient, msgs, sugar)
+	myBot, err := bot.New(cfg.Bot, store, sentenceGenerator, ttsClient, msgs, sugar)
 	if err != nil {
 		panic(err)
 	}
 
-	//If webhook flag specified run bot using webhook
-	webhook := len(os.Args) == 5 && os.Args[4] == "-w"
-	if webhook {
-		if err := myBot.RunWebhook(ctx, ":8080"); err != nil {
+	//If webhook is enabled run bot using webhook
+	if cfg.Server.Webhook {
+		if err := myBot.RunWebhook(ctx, cfg.Server.ListenAddress); err != nil {
 			panic(err)
 		}
 		return
 	}
 	myBot.Run(ctx)
 }
+
+// newGenerator creates sentence generator for the configured provider
+func newGenerator(ctx context.Context, cfg config.LLM) (generator.SentenceGenerator, error) {
+	switch cfg.Provider {
+	case config.ProviderGemini:
+		return gemini.New(ctx, cfg.Gemini)
+	case config.ProviderOpenAI:
+		return openai.New(cfg.OpenAI), nil
+	default:
+		return nil, fmt.Errorf("unknown sentence generator %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dafraer/sentence-gen-tg-bot/bot"
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/gemini"
+	"github.com/dafraer/sentence-gen-tg-bot/openai"
+	"github.com/dafraer/sentence-gen-tg-bot/quota"
+	"github.com/dafraer/sentence-gen-tg-bot/ratelimit"
+	"github.com/dafraer/sentence-gen-tg-bot/tts"
+	"gopkg.in/yaml.v3"
+)
+
+// Sentence generator providers
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+)
+
+// maxPlanIDLen keeps plan callback data within 64 bytes allowed by telegram
+const maxPlanIDLen = 32
+
+// Config is the configuration of the whole application
+type Config struct {
+	Server Server     `yaml:"server"`
+	Bot    bot.Config `yaml:"bot"`
+	Store  db.Config  `yaml:"store"`
+	LLM    LLM        `yaml:"llm"`
+	TTS    tts.Config `yaml:"tts"`
+}
+
+// Server specifies how the bot receives updates
+type Server struct {
+	Webhook       bool   `yaml:"webhook"`        //Receive updates using webhook instead of long polling
+	ListenAddress string `yaml:"listen_address"` //Address webhook server listens on
+}
+
+// LLM selects the sentence generator and its settings
+type LLM struct {
+	Provider string        `yaml:"provider"` //gemini or openai
+	Gemini   gemini.Config `yaml:"gemini"`
+	OpenAI   openai.Config `yaml:"openai"`
+}
+
+// option describes a setting that can be overridden by a flag and an environment variable
+type option struct {
+	flag   string
+	env    string
+	usage  string
+	isBool bool
+	set    func(cfg *Config, value string) error
+}
+
+// options lists every setting that can be set from command line or environment
+var options = []option{
+	{flag: "token", env: "TOKEN", usage: "telegram bot token", set: setString(func(c *Config) *string { return &c.Bot.Token })},
+	{flag: "webhook", env: "WEBHOOK", usage: "receive updates using webhook", isBool: true, set: setBool(func(c *Config) *bool { return &c.Server.Webhook })},
+	{flag: "listen-address", env: "LISTEN_ADDRESS", usage: "address webhook server listens on", set: setString(func(c *Config) *string { return &c.Server.ListenAddress })},
+	{flag: "free-sentences", env: "FREE_SENTENCES", usage: "amount of free sentences per day", set: setInt(func(c *Config) *int { return &c.Bot.Quota.DailyAllowance })},
+	{flag: "reset-hour", env: "RESET_HOUR", usage: "hour (0-23) of user's local time when free sentences are reset", set: setInt(func(c *Config) *int { return &c.Bot.Quota.ResetHour })},
+	{flag: "plans", env: "PLANS", usage: `premium plans as id=days:price in Telegram Stars, zero days for lifetime, e.g. "month=30:100;lifetime=0:2500"`, set: setPlans},
+	{flag: "subscription-price", env: "SUBSCRIPTION_PRICE", usage: "monthly price of the recurring premium subscription in Telegram Stars, 0 disables it", set: setInt(func(c *Config) *int { return &c.Bot.SubscriptionPrice })},
+	{flag: "referral-sentences", env: "REFERRAL_SENTENCES", usage: "bonus sentences the invited user and the referrer get after the first sentence of the invited user", set: setInt(func(c *Config) *int { return &c.Bot.Referral.Sentences })},
+	{flag: "referral-days", env: "REFERRAL_DAYS", usage: "days of premium the invited user and the referrer get after the first sentence of the invited user", set: setInt(func(c *Config) *int { return &c.Bot.Referral.Days })},
+	{flag: "trial-days", env: "TRIAL_DAYS", usage: "length in days of the one-time premium trial of new users, 0 disables it", set: setInt(func(c *Config) *int { return &c.Bot.TrialDays })},
+	{flag: "workers", env: "WORKERS", usage: "max amount of updates processed at the same time", set: setInt(func(c *Config) *int { return &c.Bot.Workers })},
+	{flag: "queue-size", env: "QUEUE_SIZE", usage: "max amount of updates of a single user waiting to be processed", set: setInt(func(c *Config) *int { return &c.Bot.QueueSize })},
+	{flag: "rate-free", env: "RATE_FREE", usage: "updates per minute free users can send, 0 disables the limit", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.Free.PerMinute })},
+	{flag: "rate-free-burst", env: "RATE_FREE_BURST", usage: "updates free users can send at once after being idle", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.Free.Burst })},
+	{flag: "rate-premium", env: "RATE_PREMIUM", usage: "updates per minute premium users can send, 0 disables the limit", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.Premium.PerMinute })},
+	{flag: "rate-premium-burst", env: "RATE_PREMIUM_BURST", usage: "updates premium users can send at once after being idle", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.Premium.Burst })},
+	{flag: "ban-after", env: "BAN_AFTER", usage: "throttled updates within a minute after which the user is banned, 0 disables bans", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.BanAfter })},
+	{flag: "ban-minutes", env: "BAN_MINUTES", usage: "how long users flooding the bot are banned for", set: setInt(func(c *Config) *int { return &c.Bot.RateLimit.BanMinutes })},
+	{flag: "admins", env: "ADMINS", usage: "comma separated chat ids of users allowed to use admin commands", set: setInt64s(func(c *Config) *[]int64 { return &c.Bot.Admins })},
+	{flag: "store", env: "STORE_BACKEND", usage: "storage backend: firestore, sqlite or memory", set: setString(func(c *Config) *string { return &c.Store.Backend })},
+	{flag: "firestore-project", env: "FIRESTORE_PROJECT", usage: "Google Cloud project id of the firestore database", set: setString(func(c *Config) *string { return &c.Store.FirestoreProject })},
+	{flag: "sqlite-path", env: "SQLITE_PATH", usage: "path to the SQLite database file", set: setString(func(c *Config) *string { return &c.Store.SQLitePath })},
+	{flag: "llm", env: "LLM_PROVIDER", usage: "sentence generator: gemini or openai (any OpenAI-compatible server, e.g. Ollama)", set: setString(func(c *Config) *string { return &c.LLM.Provider })},
+	{flag: "gemini-api-key", env: "GEMINI_API_KEY", usage: "gemini API key", set: setString(func(c *Config) *string { return &c.LLM.Gemini.APIKey })},
+	{flag: "gemini-model", env: "GEMINI_MODEL", usage: "gemini model name", set: setString(func(c *Config) *string { return &c.LLM.Gemini.Model })},
+	{flag: "openai-url", env: "OPENAI_BASE_URL", usage: "base URL of the OpenAI-compatible API", set: setString(func(c *Config) *string { return &c.LLM.OpenAI.BaseURL })},
+	{flag: "openai-key", env: "OPENAI_API_KEY", usage: "API key of the OpenAI-compatible API", set: setString(func(c *Config) *string { return &c.LLM.OpenAI.APIKey })},
+	{flag: "openai-model", env: "OPENAI_MODEL", usage: "model name of the OpenAI-compatible API", set: setString(func(c *Config) *string { return &c.LLM.OpenAI.Model })},
+	{flag: "narakeet-api-key", env: "NARAKEET_API_KEY", usage: "Narakeet API key", set: setString(func(c *Config) *string { return &c.TTS.NarakeetAPIKey })},
+	{flag: "tts-routes", env: "TTS_ROUTES", usage: `tts providers per language code, e.g. "ka-GE=narakeet;tatar=issai;*=google"`, set: setRoutes},
+}
+
+// DefaultRateLimit lets free users generate a sentence every few seconds and premium users three times as often.
+// Auto bans are disabled
+var DefaultRateLimit = ratelimit.Config{
+	Free:       ratelimit.Tier{PerMinute: 10, Burst: 5},
+	Premium:    ratelimit.Tier{PerMinute: 30, Burst: 10},
+	BanMinutes: 60,
+}
+
+// Default returns configuration with default values
+func Default() *Config {
+	return &Config{
+		Server: Server{ListenAddress: ":8080"},
+		Bot:    bot.Config{Quota: quota.Policy{DailyAllowance: 50}, Plans: slices.Clone(bot.DefaultPlans), SubscriptionPrice: 90, Referral: db.ReferralBonus{Sentences: 20}, TrialDays: 3, Workers: 10, QueueSize: 3, RateLimit: DefaultRateLimit},
+		Store:  db.Config{Backend: db.BackendFirestore, SQLitePath: "bot.db"},
+		LLM: LLM{
+			Provider: ProviderGemini,
+			Gemini:   gemini.Config{Model: gemini.DefaultModel},
+			OpenAI:   openai.Config{BaseURL: openai.DefaultBaseURL},
+		},
+		TTS: tts.Config{Routes: maps.Clone(tts.DefaultRoutes)},
+	}
+}
+
+// Load builds configuration from defaults, optional YAML file, environment variables and command line flags.
+// Each source overrides the previous one, except tts routes from the file that are merged with the default ones.
+// YAML file is specified with -config flag or CONFIG_FILE variable
+func Load(args []string) (*Config, error) {
+	//Register flags, values are applied after the file and environment
+	fs := flag.NewFlagSet("sentence-gen-tg-bot", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	values := make(map[string]*flagValue, len(options))
+	for _, o := range options {
+		values[o.flag] = &flagValue{isBool: o.isBool}
+		fs.Var(values[o.flag], o.flag, fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	//Read config file
+	cfg := Default()
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+	}
+
+	//Apply environment variables
+	for _, o := range options {
+		if value, ok := os.LookupEnv(o.env); ok && value != "" {
+			if err := o.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", o.env, err)
+			}
+		}
+	}
+
+	//Apply flags that were passed explicitly
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.flag == f.Name && flagErr == nil {
+				if err := o.set(cfg, values[o.flag].value); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", o.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that configuration is complete and consistent
+func (cfg *Config) Validate() error {
+	var errs []error
+	if cfg.Bot.Token == "" {
+		errs = append(errs, errors.New("telegram bot token is required"))
+	}
+	if cfg.Server.Webhook && cfg.Server.ListenAddress == "" {
+		errs = append(errs, errors.New("listen address is required in webhook mode"))
+	}
+	if cfg.Bot.Quota.DailyAllowance < 0 {
+		errs = append(errs, errors.New("free sentences amount can not be negative"))
+	}
+	if cfg.Bot.Quota.ResetHour < 0 || cfg.Bot.Quota.ResetHour > 23 {
+		errs = append(errs, errors.New("reset hour must be from 0 to 23"))
+	}
+	if len(cfg.Bot.Plans) == 0 {
+		errs = append(errs, errors.New("at least one premium plan is required"))
+	}
+	planIDs := make(map[string]bool, len(cfg.Bot.Plans))
+	for _, plan := range cfg.Bot.Plans {
+		switch {
+		case plan.ID == "" || strings.ContainsAny(plan.ID, ":; ") || len(plan.ID) > maxPlanIDLen:
+			errs = append(errs, fmt.Errorf("invalid premium plan id %q", plan.ID))
+		case planIDs[plan.ID]:
+			errs = append(errs, fmt.Errorf("duplicate premium plan id %q", plan.ID))
+		}
+		planIDs[plan.ID] = true
+		if plan.Days < 0 {
+			errs = append(errs, fmt.Errorf("days of premium plan %q can not be negative", plan.ID))
+		}
+		if plan.Price <= 0 {
+			errs = append(errs, fmt.Errorf("price of premium plan %q must be positive", plan.ID))
+		}
+	}
+	if cfg.Bot.SubscriptionPrice < 0 {
+		errs = append(errs, errors.New("subscription price can not be negative"))
+	}
+	if cfg.Bot.Referral.Sentences < 0 || cfg.Bot.Referral.Days < 0 {
+		errs = append(errs, errors.New("referral bonus can not be negative"))
+	}
+	if cfg.Bot.TrialDays < 0 {
+		errs = append(errs, errors.New("trial days can not be negative"))
+	}
+	if cfg.Bot.Workers <= 0 {
+		errs = append(errs, errors.New("amount of workers must be positive"))
+	}
+	if cfg.Bot.QueueSize < 0 {
+		errs = append(errs, errors.New("queue size can not be negative"))
+	}
+	limit := cfg.Bot.RateLimit
+	if limit.Free.PerMinute < 0 || limit.Free.Burst < 0 || limit.Premium.PerMinute < 0 || limit.Premium.Burst < 0 {
+		errs = append(errs, errors.New("rate limits can not be negative"))
+	}
+	if limit.BanAfter < 0 {
+		errs = append(errs, errors.New("ban after can not be negative"))
+	}
+	if limit.BanAfter > 0 && limit.BanMinutes <= 0 {
+		errs = append(errs, errors.New("ban minutes must be positive when bans are enabled"))
+	}
+
+	switch cfg.Store.Backend {
+	case db.BackendFirestore:
+		if cfg.Store.FirestoreProject == "" {
+			errs = append(errs, errors.New("firestore project is required"))
+		}
+	case db.BackendSQLite:
+		if cfg.Store.SQLitePath == "" {
+			errs = append(errs, errors.New("sqlite path is required"))
+		}
+	case db.BackendMemory:
+	default:
+		errs = append(errs, fmt.Errorf("unknown store backend %q", cfg.Store.Backend))
+	}
+
+	switch cfg.LLM.Provider {
+	case ProviderGemini:
+		if cfg.LLM.Gemini.APIKey == "" {
+			errs = append(errs, errors.New("gemini API key is required"))
+		}
+		if cfg.LLM.Gemini.Model == "" {
+			errs = append(errs, errors.New("gemini model is required"))
+		}
+	case ProviderOpenAI:
+		if cfg.LLM.OpenAI.BaseURL == "" {
+			errs = append(errs, errors.New("OpenAI-compatible API base URL is required"))
+		}
+		if cfg.LLM.OpenAI.Model == "" {
+			errs = append(errs, errors.New("OpenAI-compatible API model is required"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown sentence generator %q", cfg.LLM.Provider))
+	}
+
+	if len(cfg.TTS.Routes[tts.DefaultLanguage]) == 0 {
+		errs = append(errs, fmt.Errorf("tts routes must contain providers for %q", tts.DefaultLanguage))
+	}
+	for languageCode, names := range cfg.TTS.Routes {
+		for _, name := range names {
+			if name == tts.NarakeetName && cfg.TTS.NarakeetAPIKey == "" {
+				errs = append(errs, fmt.Errorf("narakeet API key is required because it is used for %q", languageCode))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flagValue keeps raw flag value until it is applied on top of the file and environment
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func setString(field func(cfg *Config) *string) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func setInt(field func(cfg *Config) *int) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func setInt64s(field func(cfg *Config) *[]int64) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		var values []int64
+		for _, s := range strings.Split(value, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return err
+			}
+			values = append(values, n)
+		}
+		*field(cfg) = values
+		return nil
+	}
+}
+
+func setBool(field func(cfg *Config) *bool) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func setPlans(cfg *Config, value string) error {
+	plans, err := bot.ParsePlans(value)
+	if err != nil {
+		return err
+	}
+	cfg.Bot.Plans = plans
+	return nil
+}
+
+func setRoutes(cfg *Config, value string) error {
+	routes, err := tts.ParseRoutes(value)
+	if err != nil {
+		return err
+	}
+	cfg.TTS.Routes = routes
+	return nil
+}
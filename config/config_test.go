@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+	"github.com/dafraer/sentence-gen-tg-bot/tts"
+)
+
+// valid returns default configuration with the required settings filled in
+func valid() *Config {
+	cfg := Default()
+	cfg.Bot.Token = "token"
+	cfg.Store.Backend = db.BackendMemory
+	cfg.LLM.Gemini.APIKey = "gemini-key"
+	cfg.TTS.NarakeetAPIKey = "narakeet-key"
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `
+bot:
+  token: file-token
+store:
+  backend: sqlite
+  sqlite_path: file.db
+llm:
+  provider: openai
+  openai:
+    model: file-model
+tts:
+  routes:
+    tatar: [google]
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SQLITE_PATH", "env.db")
+	t.Setenv("OPENAI_MODEL", "env-model")
+	t.Setenv("NARAKEET_API_KEY", "narakeet-key")
+
+	cfg, err := Load([]string{"-config", path, "-openai-model", "flag-model"})
+	if err != nil {
+		t.Fatalf("error loading config: %v", err)
+	}
+	if cfg.Bot.Token != "file-token" || cfg.Store.Backend != db.BackendSQLite {
+		t.Errorf("settings from the file were not applied: %+v", cfg)
+	}
+	if cfg.Store.SQLitePath != "env.db" {
+		t.Errorf("environment did not override the file: sqlite path %q", cfg.Store.SQLitePath)
+	}
+	if cfg.LLM.OpenAI.Model != "flag-model" {
+		t.Errorf("flag did not override the environment: model %q", cfg.LLM.OpenAI.Model)
+	}
+	if cfg.LLM.OpenAI.BaseURL == "" {
+		t.Error("default base URL was lost")
+	}
+
+	//Routes from the file are merged with the default ones
+	if got := cfg.TTS.Routes["tatar"]; len(got) != 1 || got[0] != tts.GoogleName {
+		t.Errorf("tatar route = %v", got)
+	}
+	if got := cfg.TTS.Routes[tts.DefaultLanguage]; len(got) == 0 {
+		t.Error("default route was lost")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-free-sentences", "many"},
+		{"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"positional"},
+		{"-plans", "month"},
+		{"-token", "token", "-store", "memory", "-gemini-api-key", "key", "-narakeet-api-key", "key", "-tts-routes", "ka-GE"},
+	} {
+		if _, err := Load(args); err == nil {
+			t.Errorf("Load(%q) returned no error", args)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		err    string
+	}{
+		{name: "no token", change: func(cfg *Config) { cfg.Bot.Token = "" }, err: "token is required"},
+		{name: "webhook without address", change: func(cfg *Config) { cfg.Server.Webhook, cfg.Server.ListenAddress = true, "" }, err: "listen address"},
+		{name: "unknown store", change: func(cfg *Config) { cfg.Store.Backend = "mongo" }, err: "unknown store backend"},
+		{name: "firestore without project", change: func(cfg *Config) { cfg.Store.Backend, cfg.Store.FirestoreProject = db.BackendFirestore, "" }, err: "firestore project is required"},
+		{name: "sqlite without path", change: func(cfg *Config) { cfg.Store.Backend, cfg.Store.SQLitePath = db.BackendSQLite, "" }, err: "sqlite path"},
+		{name: "unknown llm", change: func(cfg *Config) { cfg.LLM.Provider = "claude" }, err: "unknown sentence generator"},
+		{name: "gemini without key", change: func(cfg *Config) { cfg.LLM.Gemini.APIKey = "" }, err: "gemini API key"},
+		{name: "openai without model", change: func(cfg *Config) { cfg.LLM.Provider, cfg.LLM.OpenAI.Model = ProviderOpenAI, "" }, err: "model is required"},
+		{name: "no default tts route", change: func(cfg *Config) { delete(cfg.TTS.Routes, tts.DefaultLanguage) }, err: "tts routes"},
+		{name: "no plans", change: func(cfg *Config) { cfg.Bot.Plans = nil }, err: "at least one premium plan"},
+		{name: "invalid plan id", change: func(cfg *Config) { cfg.Bot.Plans[0].ID = "week:1" }, err: "invalid premium plan id"},
+		{name: "duplicate plan", change: func(cfg *Config) { cfg.Bot.Plans[1].ID = cfg.Bot.Plans[0].ID }, err: "duplicate premium plan id"},
+		{name: "free plan", change: func(cfg *Config) { cfg.Bot.Plans[0].Price = 0 }, err: "must be positive"},
+		{name: "narakeet without key", change: func(cfg *Config) { cfg.TTS.NarakeetAPIKey = "" }, err: "narakeet API key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate = %v, want error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Storage backends
+const (
+	BackendFirestore = "firestore"
+	BackendSQLite    = "sqlite"
+	BackendMemory    = "memory"
+)
+
+var (
+	// ErrUserNotFound is returned when the requested user does not exist in the store
+	ErrUserNotFound = errors.New("user not found")
+	// ErrCardNotFound is returned when the requested card does not exist in the store
+	ErrCardNotFound = errors.New("card not found")
+)
+
+// Config selects the storage backend and its settings
+type Config struct {
+	Backend          string `yaml:"backend"`           //firestore, sqlite or memory
+	FirestoreProject string `yaml:"firestore_project"` //Project id on Google cloud
+	SQLitePath       string `yaml:"sqlite_path"`       //Path to the SQLite database file
+}
+
+type User struct {
+	ChatId           int64
+	UserName         string //Telegram username
+	SentenceLanguage string //Language in which sentence should be generated
+	Level            string //e.g. A1
+	PremiumUntil     int64  //unix time until which user has paid premium
+	TrialUntil       int64  //unix time when premium trial ends, zero if user has never started it
+	Paid             bool   //User has ever got premium from a payment or a subscription
+	Lifetime         bool   //User has bought or was gifted the lifetime plan
+	PreferencesSet   bool
+	LastUsed         int64  //unix time
+	FreeSentences    int    //how many more free sentences can user generate
+	QuotaResetAt     int64  //unix time when free sentences are reset next
+	Timezone         string //IANA timezone name (e.g. Europe/Moscow), empty if user has not chosen it
+	Language         string //Telegram language code of the user, used to message them outside of their updates
+	BannedUntil      int64  //unix time until which user is not allowed to use the bot
+	RemindedAt       int64  //unix time when the reminders period in which user was last reminded that premium or trial ends ended
+
+	SubscriptionChargeID string //Telegram charge id of the first payment of user's recurring subscription, empty if user never subscribed
+	SubscriptionUntil    int64  //unix time when the current period of the subscription ends
+	SubscriptionCanceled bool   //Subscription will not be renewed when the current period ends
+
+	ReferredBy       int64 //Chat id of the user who invited this user, zero if they came on their own
+	ReferralRewarded bool  //Referral bonus has been given to the user and their referrer
+	BonusSentences   int   //Free sentences from referrals, used after the daily ones and never reset
+}
+
+// Card is a sentence generated for the user
+type Card struct {
+	ID          string //Assigned by the store
+	ChatId      int64
+	Word        string //Word user asked for
+	Sentence    string //Sentence in the language user is learning
+	Translation string //Translation of the sentence to user's language
+	Language    string //Language code of the sentence (e.g. es-ES)
+	Level       string //Language level the sentence was generated for (e.g. A1)
+	Model       string //Language model that generated the sentence
+	AudioFileID string //Telegram file id of the audio sent to the user
+	CreatedAt   int64  //unix time
+	Regenerated int    //How many times the sentence was regenerated
+
+	//Spaced repetition schedule, see srs.Schedule
+	Due         int64   //unix time when the card should be reviewed next
+	Interval    int     //days
+	Ease        float64 //zero for cards that were never reviewed
+	Repetitions int
+	Lapses      int
+}
+
+// UserStore is implemented by every storage backend the bot can keep its users in
+type UserStore interface {
+	// CreateUser Creates user if user does not exist
+	CreateUser(ctx context.Context, user *User) error
+	// GetUser retrieves user using telegram chat id, returns ErrUserNotFound if there is no such user
+	GetUser(ctx context.Context, chatId int64) (*User, error)
+	// FindUserByUserName retrieves user by telegram username ignoring case, returns ErrUserNotFound if there is no such user
+	FindUserByUserName(ctx context.Context, userName string) (*User, error)
+	// UpdateUser updates user overriding all fields with the provided user struct
+	UpdateUser(ctx context.Context, user *User) error
+	// SetUserSentenceLanguage updates user's language of generated sentences
+	SetUserSentenceLanguage(ctx context.Context, chatId int64, sentenceLanguage string) error
+	// SetUserLevel sets language level and marks user's preferences as set
+	SetUserLevel(ctx context.Context, chatId int64, level string) error
+	// UpdateUserPremium updates user premiumUntil field to a new time stamp provided in unix time format, trial is not affected
+	UpdateUserPremium(ctx context.Context, chatId int64, premiumUntil int64) error
+	// StartTrial gives user premium trial until the unix time until, tracked separately from paid premium.
+	// Returns ErrTrialUnavailable if user has already used the trial or has ever paid for premium
+	StartTrial(ctx context.Context, chatId int64, until int64) error
+	// SetUserTimezone sets user's timezone used for the daily reset of free sentences
+	SetUserTimezone(ctx context.Context, chatId int64, timezone string) error
+	// SetUserSubscription records user's recurring subscription paid until the unix time, the subscription is no longer canceled
+	SetUserSubscription(ctx context.Context, chatId int64, chargeId string, until int64) error
+	// SetUserSubscriptionCanceled marks whether user's subscription will be renewed when its current period ends
+	SetUserSubscriptionCanceled(ctx context.Context, chatId int64, canceled bool) error
+	// SetUserBannedUntil bans user until the unix time, zero lifts the ban
+	SetUserBannedUntil(ctx context.Context, chatId int64, until int64) error
+	// SetUserRemindedAt records that user was reminded that premium or trial ends in the reminders period ending at the unix time
+	SetUserRemindedAt(ctx context.Context, chatId int64, at int64) error
+	// ReserveSentence atomically resets user's free sentences if the reset time has passed and takes one of them.
+	// Returns ErrNoFreeSentences if user has neither premium nor free sentences
+	ReserveSentence(ctx context.Context, chatId int64, reset QuotaReset) (*Reservation, error)
+	// CommitSentence marks reserved sentence as used at the unix time now
+	CommitSentence(ctx context.Context, r *Reservation, now int64) error
+	// RefundSentence returns reserved sentence to the user
+	RefundSentence(ctx context.Context, r *Reservation) error
+	// Close releases resources held by the store
+	Close() error
+}
+
+// CardStore keeps the history of sentences generated for the users
+type CardStore interface {
+	// AddCard appends the card to user's history and sets its ID
+	AddCard(ctx context.Context, card *Card) error
+	// GetCards returns all user's cards from the oldest to the newest
+	GetCards(ctx context.Context, chatId int64) ([]*Card, error)
+	// ListCards returns up to limit user's cards from the newest to the oldest skipping the first offset cards.
+	// hasMore reports whether there are cards after the returned ones
+	ListCards(ctx context.Context, chatId int64, offset, limit int) (cards []*Card, hasMore bool, err error)
+	// DeleteCard deletes user's card, deleting card that does not exist is not an error
+	DeleteCard(ctx context.Context, chatId int64, cardId string) error
+	// GetCard returns user's card, returns ErrCardNotFound if there is no such card
+	GetCard(ctx context.Context, chatId int64, cardId string) (*Card, error)
+	// DueCards returns up to limit user's cards that are due at the unix time now, the most overdue first
+	DueCards(ctx context.Context, chatId int64, now int64, limit int) ([]*Card, error)
+	// UpdateCard updates card overriding all fields with the provided card struct, returns ErrCardNotFound if there is no such card
+	UpdateCard(ctx context.Context, card *Card) error
+	// UpdateCardSchedule saves spaced repetition schedule fields of the card
+	UpdateCardSchedule(ctx context.Context, card *Card) error
+}
+
+// Store is implemented by every storage backend and contains everything the bot keeps
+type Store interface {
+	UserStore
+	CardStore
+	PaymentStore
+	PromoStore
+	ReferralStore
+	JobStore
+}
+
+// New creates store for the backend specified in the config
+func New(ctx context.Context, cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendFirestore:
+		return NewFirestore(ctx, cfg.FirestoreProject)
+	case BackendSQLite:
+		return NewSQLite(ctx, cfg.SQLitePath)
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore runs the test against every backend that works without network access, the store has the user with the chat id 1.
+// Firestore is tested too when FIRESTORE_EMULATOR_HOST points to the emulator
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store {
+			return NewMemory()
+		},
+		"sqlite": func(t *testing.T) Store {
+			store, err := NewSQLite(ctx, filepath.Join(t.TempDir(), "bot.db"))
+			if err != nil {
+				t.Fatalf("error creating sqlite store: %v", err)
+			}
+			return store
+		},
+	}
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") != "" {
+		backends["firestore"] = func(t *testing.T) Store {
+			//Every test gets its own project, so the emulator starts it with no documents
+			store, err := NewFirestore(ctx, fmt.Sprintf("test-%d", time.Now().UnixNano()))
+			if err != nil {
+				t.Fatalf("error creating firestore store: %v", err)
+			}
+			return store
+		}
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			t.Cleanup(func() { _ = store.Close() })
+			if err := store.CreateUser(ctx, &User{ChatId: 1}); err != nil {
+				t.Fatalf("error creating user: %v", err)
+			}
+			test(t, store)
+		})
+	}
+}
+
+// mustGetUser returns the user or fails the test
+func mustGetUser(t *testing.T, store Store, chatId int64) *User {
+	t.Helper()
+	user, err := store.GetUser(context.Background(), chatId)
+	if err != nil {
+		t.Fatalf("error getting user: %v", err)
+	}
+	return user
+}
+
+func TestStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if _, err := store.GetUser(ctx, 2); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("getting unknown user returned %v", err)
+		}
+
+		if err := store.SetUserSentenceLanguage(ctx, 1, "es-ES"); err != nil {
+			t.Fatalf("error setting sentence language: %v", err)
+		}
+		if err := store.SetUserLevel(ctx, 1, "B1"); err != nil {
+			t.Fatalf("error setting level: %v", err)
+		}
+		if err := store.UpdateUserPremium(ctx, 1, 100); err != nil {
+			t.Fatalf("error updating premium: %v", err)
+		}
+		if err := store.SetUserTimezone(ctx, 1, "Europe/Moscow"); err != nil {
+			t.Fatalf("error setting timezone: %v", err)
+		}
+		if err := store.SetUserRemindedAt(ctx, 1, 200); err != nil {
+			t.Fatalf("error setting reminded at: %v", err)
+		}
+		user := mustGetUser(t, store, 1)
+		if user.SentenceLanguage != "es-ES" || user.Level != "B1" || !user.PreferencesSet || user.PremiumUntil != 100 || user.Timezone != "Europe/Moscow" || user.RemindedAt != 200 {
+			t.Fatalf("user = %+v", user)
+		}
+
+		user.UserName = "Foo"
+		user.FreeSentences = 7
+		if err := store.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("error updating user: %v", err)
+		}
+		if got := mustGetUser(t, store, 1); *got != *user {
+			t.Fatalf("updated user = %+v, want %+v", got, user)
+		}
+	})
+}
+
+func TestCards(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		for _, word := range []string{"amigo", "casa"} {
+			card := &Card{ChatId: 1, Word: word, Sentence: "sentence " + word, Language: "es-ES", CreatedAt: 100}
+			if err := store.AddCard(ctx, card); err != nil {
+				t.Fatalf("error adding card: %v", err)
+			}
+			if card.ID == "" {
+				t.Fatal("card id was not set")
+			}
+		}
+
+		cards, err := store.GetCards(ctx, 1)
+		if err != nil {
+			t.Fatalf("error getting cards: %v", err)
+		}
+		if len(cards) != 2 || cards[0].Word != "amigo" || cards[1].Word != "casa" || cards[0].ID == cards[1].ID {
+			t.Fatalf("cards = %+v", cards)
+		}
+		if cards, err := store.GetCards(ctx, 2); err != nil || len(cards) != 0 {
+			t.Fatalf("other user's cards = %+v, %v", cards, err)
+		}
+	})
+}
+
+func TestListCards(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		for _, word := range []string{"uno", "dos", "tres"} {
+			if err := store.AddCard(ctx, &Card{ChatId: 1, Word: word}); err != nil {
+				t.Fatalf("error adding card: %v", err)
+			}
+		}
+
+		//Cards are listed from the newest
+		cards, hasMore, err := store.ListCards(ctx, 1, 0, 2)
+		if err != nil || !hasMore || len(cards) != 2 || cards[0].Word != "tres" || cards[1].Word != "dos" {
+			t.Fatalf("first page = %+v, %v, %v", cards, hasMore, err)
+		}
+		cards, hasMore, err = store.ListCards(ctx, 1, 2, 2)
+		if err != nil || hasMore || len(cards) != 1 || cards[0].Word != "uno" {
+			t.Fatalf("second page = %+v, %v, %v", cards, hasMore, err)
+		}
+
+		if err := store.DeleteCard(ctx, 1, cards[0].ID); err != nil {
+			t.Fatalf("error deleting card: %v", err)
+		}
+		if err := store.DeleteCard(ctx, 1, cards[0].ID); err != nil {
+			t.Fatalf("error deleting card twice: %v", err)
+		}
+		if cards, hasMore, err := store.ListCards(ctx, 1, 0, 5); err != nil || hasMore || len(cards) != 2 {
+			t.Fatalf("cards after delete = %+v, %v, %v", cards, hasMore, err)
+		}
+	})
+}
+
+func TestCardSchedule(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		cards := []*Card{{ChatId: 1, Word: "uno", Due: 300}, {ChatId: 1, Word: "dos", Due: 100}, {ChatId: 1, Word: "tres", Due: 500}}
+		for _, card := range cards {
+			if err := store.AddCard(ctx, card); err != nil {
+				t.Fatalf("error adding card: %v", err)
+			}
+		}
+		if _, err := store.GetCard(ctx, 1, "missing"); !errors.Is(err, ErrCardNotFound) {
+			t.Fatalf("getting unknown card returned %v", err)
+		}
+		if _, err := store.GetCard(ctx, 2, cards[0].ID); !errors.Is(err, ErrCardNotFound) {
+			t.Fatalf("getting other user's card returned %v", err)
+		}
+
+		//The most overdue cards come first
+		due, err := store.DueCards(ctx, 1, 400, 10)
+		if err != nil || len(due) != 2 || due[0].Word != "dos" || due[1].Word != "uno" {
+			t.Fatalf("due cards = %+v, %v", due, err)
+		}
+
+		card := cards[1]
+		card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses = 1000, 6, 2.36, 2, 1
+		if err := store.UpdateCardSchedule(ctx, card); err != nil {
+			t.Fatalf("error updating schedule: %v", err)
+		}
+		got, err := store.GetCard(ctx, 1, card.ID)
+		if err != nil || got.Due != 1000 || got.Interval != 6 || got.Ease != 2.36 || got.Repetitions != 2 || got.Lapses != 1 {
+			t.Fatalf("card after update = %+v, %v", got, err)
+		}
+		if due, err := store.DueCards(ctx, 1, 400, 10); err != nil || len(due) != 1 || due[0].Word != "uno" {
+			t.Fatalf("due cards after update = %+v, %v", due, err)
+		}
+	})
+}
+
+func TestSubscription(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.SetUserSubscription(ctx, 1, "charge", 100); err != nil {
+			t.Fatalf("error setting subscription: %v", err)
+		}
+		if err := store.SetUserSubscriptionCanceled(ctx, 1, true); err != nil {
+			t.Fatalf("error canceling subscription: %v", err)
+		}
+		if user := mustGetUser(t, store, 1); user.SubscriptionChargeID != "charge" || user.SubscriptionUntil != 100 || !user.SubscriptionCanceled {
+			t.Fatalf("user after canceling = %+v", user)
+		}
+
+		//Renewing the subscription resumes it
+		if err := store.SetUserSubscription(ctx, 1, "charge", 200); err != nil {
+			t.Fatalf("error setting subscription: %v", err)
+		}
+		if user := mustGetUser(t, store, 1); user.SubscriptionUntil != 200 || user.SubscriptionCanceled {
+			t.Fatalf("user after renewal = %+v", user)
+		}
+	})
+}
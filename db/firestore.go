@@ -0,0 +1,690 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+)
+
+// FirestoreStore keeps users in Google Firestore
+type FirestoreStore struct {
+	db *firestore.Client
+}
+
+// NewFirestore creates new firestore instance for the given Google Cloud project
+func NewFirestore(ctx context.Context, projectID string) (*FirestoreStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return &FirestoreStore{client}, nil
+}
+
+// Close closes firestore client
+func (store *FirestoreStore) Close() error {
+	return store.db.Close()
+}
+
+// userDoc is the document of the user in the users collection. Firestore can't compare strings ignoring case,
+// so the username is kept in lower case as well to find users by it
+type userDoc struct {
+	User
+	UserNameLower string
+}
+
+// newUserDoc returns the document of the user
+func newUserDoc(user *User) *userDoc {
+	return &userDoc{User: *user, UserNameLower: strings.ToLower(user.UserName)}
+}
+
+// CreateUser Creates user if user does not exist
+func (store *FirestoreStore) CreateUser(ctx context.Context, user *User) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(user.ChatId))).Create(ctx, newUserDoc(user))
+	return err
+}
+
+// FindUserByUserName retrieves user by telegram username ignoring case.
+// Users saved before the username was kept in lower case are matched by the username as it was stored
+func (store *FirestoreStore) FindUserByUserName(ctx context.Context, userName string) (*User, error) {
+	users := store.db.Collection("users")
+	docs, err := users.Where("UserNameLower", "==", strings.ToLower(userName)).Limit(1).Documents(ctx).GetAll()
+	if err == nil && len(docs) == 0 {
+		docs, err = users.Where("UserName", "==", userName).Limit(1).Documents(ctx).GetAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, ErrUserNotFound
+	}
+	chatId, err := strconv.ParseInt(docs[0].Ref.ID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return store.GetUser(ctx, chatId)
+}
+
+// UpdateUser updates user overriding all fields with the provided user struct
+func (store *FirestoreStore) UpdateUser(ctx context.Context, user *User) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(user.ChatId))).Set(ctx, newUserDoc(user))
+	return err
+}
+
+// GetUser retrieves user from the database using telegram chat id
+func (store *FirestoreStore) GetUser(ctx context.Context, chatId int64) (*User, error) {
+	//Make a request to the firestore
+	res, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return userFromSnapshot(res)
+}
+
+// userFromSnapshot converts user document to the user struct.
+// Fields missing in documents of users created before they were added are left zero
+func userFromSnapshot(doc *firestore.DocumentSnapshot) (*User, error) {
+	var user User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserSentenceLanguage updates user's language of generated sentences
+func (store *FirestoreStore) SetUserSentenceLanguage(ctx context.Context, chatId int64, sentenceLanguage string) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{
+			Path:  "SentenceLanguage",
+			Value: sentenceLanguage,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateUserPremium updates user premiumUntil field to a new time stamp provided in unix time format
+func (store *FirestoreStore) UpdateUserPremium(ctx context.Context, chatId int64, premiumUntil int64) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{
+			Path:  "PremiumUntil",
+			Value: premiumUntil,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetUserLevel sets language level (e.g. A1, B2) for sentences that user will generate
+// Also sets preferencesSet field to true because setting language is the last step of preferences
+func (store *FirestoreStore) SetUserLevel(ctx context.Context, chatId int64, level string) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{
+			Path:  "Level",
+			Value: level,
+		},
+		{
+			Path:  "PreferencesSet",
+			Value: true,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetUserTimezone sets user's timezone used for the daily reset of free sentences
+func (store *FirestoreStore) SetUserTimezone(ctx context.Context, chatId int64, timezone string) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{Path: "Timezone", Value: timezone},
+	})
+	return err
+}
+
+// SetUserSubscription records user's recurring subscription paid until the unix time, the subscription is no longer canceled
+func (store *FirestoreStore) SetUserSubscription(ctx context.Context, chatId int64, chargeId string, until int64) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{Path: "SubscriptionChargeID", Value: chargeId},
+		{Path: "SubscriptionUntil", Value: until},
+		{Path: "SubscriptionCanceled", Value: false},
+		{Path: "Paid", Value: true},
+	})
+	return err
+}
+
+// SetUserSubscriptionCanceled marks whether user's subscription will be renewed when its current period ends
+func (store *FirestoreStore) SetUserSubscriptionCanceled(ctx context.Context, chatId int64, canceled bool) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{Path: "SubscriptionCanceled", Value: canceled},
+	})
+	return err
+}
+
+// SetUserBannedUntil bans user until the unix time, zero lifts the ban
+func (store *FirestoreStore) SetUserBannedUntil(ctx context.Context, chatId int64, until int64) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{Path: "BannedUntil", Value: until},
+	})
+	return err
+}
+
+// SetUserRemindedAt records that user was reminded that premium or trial ends in the reminders period ending at the unix time
+func (store *FirestoreStore) SetUserRemindedAt(ctx context.Context, chatId int64, at int64) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Update(ctx, []firestore.Update{
+		{Path: "RemindedAt", Value: at},
+	})
+	return err
+}
+
+// ReserveSentence resets user's free sentences if the reset time has passed and takes one of them in a transaction
+func (store *FirestoreStore) ReserveSentence(ctx context.Context, chatId int64, reset QuotaReset) (*Reservation, error) {
+	var r *Reservation
+	var reserveErr error
+	err := store.updateUserTx(ctx, chatId, func(user *User) {
+		r, reserveErr = reserve(user, reset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, reserveErr
+}
+
+// CommitSentence marks reserved sentence as used at the unix time now
+func (store *FirestoreStore) CommitSentence(ctx context.Context, r *Reservation, now int64) error {
+	_, err := store.db.Collection("users").Doc(strconv.Itoa(int(r.ChatId))).Update(ctx, []firestore.Update{
+		{Path: "LastUsed", Value: now},
+	})
+	return err
+}
+
+// RefundSentence returns reserved sentence to the user in a transaction
+func (store *FirestoreStore) RefundSentence(ctx context.Context, r *Reservation) error {
+	return store.updateUserTx(ctx, r.ChatId, func(user *User) {
+		refund(user, r)
+	})
+}
+
+// StartTrial gives user premium trial until the unix time until in a transaction
+func (store *FirestoreStore) StartTrial(ctx context.Context, chatId int64, until int64) error {
+	ref := store.db.Collection("users").Doc(strconv.FormatInt(chatId, 10))
+	return store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if err := startTrial(&user, until); err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "TrialUntil", Value: user.TrialUntil}})
+	})
+}
+
+// updateUserTx reads the user, applies fn and saves user's quota fields in a transaction.
+// Firestore retries the transaction if the user is changed concurrently, so fn may be called more than once
+func (store *FirestoreStore) updateUserTx(ctx context.Context, chatId int64, fn func(user *User)) error {
+	ref := store.db.Collection("users").Doc(strconv.Itoa(int(chatId)))
+	return store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		fn(&user)
+		return tx.Update(ref, []firestore.Update{
+			{Path: "FreeSentences", Value: user.FreeSentences},
+			{Path: "BonusSentences", Value: user.BonusSentences},
+			{Path: "QuotaResetAt", Value: user.QuotaResetAt},
+			{Path: "LastUsed", Value: user.LastUsed},
+		})
+	})
+}
+
+// ApplyPayment records the payment in the payments collection and extends user's premium by its duration in a transaction
+func (store *FirestoreStore) ApplyPayment(ctx context.Context, p *Payment) error {
+	paymentRef := store.db.Collection("payments").Doc(p.ChargeID)
+	userRef := store.db.Collection("users").Doc(strconv.Itoa(int(p.PremiumChatId())))
+	return store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		//Check if the payment has already been applied
+		_, err := tx.Get(paymentRef)
+		if err == nil {
+			return ErrDuplicatePayment
+		}
+		if status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		//Get the user
+		doc, err := tx.Get(userRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		//Extend premium and record the payment
+		applyPayment(&user, p)
+		if err := tx.Update(userRef, []firestore.Update{{Path: "PremiumUntil", Value: user.PremiumUntil}, {Path: "Paid", Value: true}, {Path: "Lifetime", Value: user.Lifetime}}); err != nil {
+			return err
+		}
+		return tx.Create(paymentRef, p)
+	})
+}
+
+// GetPayment returns the payment by its charge id
+func (store *FirestoreStore) GetPayment(ctx context.Context, chargeId string) (*Payment, error) {
+	doc, err := store.db.Collection("payments").Doc(chargeId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Payment
+	if err := doc.DataTo(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RefundPayment marks the payment as refunded and takes back the premium it granted in a transaction
+func (store *FirestoreStore) RefundPayment(ctx context.Context, chargeId string, now int64) (*Payment, error) {
+	paymentRef := store.db.Collection("payments").Doc(chargeId)
+	var p Payment
+	err := store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		//Get the payment and its user
+		doc, err := tx.Get(paymentRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		p = Payment{}
+		if err := doc.DataTo(&p); err != nil {
+			return err
+		}
+		userRef := store.db.Collection("users").Doc(strconv.Itoa(int(p.PremiumChatId())))
+		doc, err = tx.Get(userRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		//Take back premium and mark the payment as refunded
+		if err := refundPayment(&user, &p, now); err != nil {
+			return err
+		}
+		if err := tx.Update(userRef, []firestore.Update{{Path: "PremiumUntil", Value: user.PremiumUntil}, {Path: "Lifetime", Value: user.Lifetime}}); err != nil {
+			return err
+		}
+		return tx.Update(paymentRef, []firestore.Update{{Path: "RefundedAt", Value: p.RefundedAt}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePromoCode saves new promo code to the promo_codes collection
+func (store *FirestoreStore) CreatePromoCode(ctx context.Context, promo *PromoCode) error {
+	_, err := store.db.Collection("promo_codes").Doc(promo.Code).Create(ctx, promo)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrPromoCodeExists
+	}
+	return err
+}
+
+// RedeemPromoCode extends user's premium by the days of the code and records that the user redeemed it in a transaction.
+// Redemptions are kept in the redemptions collection of the code with user's chat id as the document id
+func (store *FirestoreStore) RedeemPromoCode(ctx context.Context, code string, chatId int64, now int64) (*PromoCode, error) {
+	promoRef := store.db.Collection("promo_codes").Doc(code)
+	redemptionRef := promoRef.Collection("redemptions").Doc(strconv.FormatInt(chatId, 10))
+	userRef := store.db.Collection("users").Doc(strconv.FormatInt(chatId, 10))
+	var promo PromoCode
+	err := store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		//Get the code, the user and check whether they have already redeemed it
+		doc, err := tx.Get(promoRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrPromoCodeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		promo = PromoCode{}
+		if err := doc.DataTo(&promo); err != nil {
+			return err
+		}
+		doc, err = tx.Get(userRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		_, err = tx.Get(redemptionRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		//Extend premium and record the redemption
+		if err := redeemPromoCode(&promo, &user, err == nil, now); err != nil {
+			return err
+		}
+		if err := tx.Update(userRef, []firestore.Update{{Path: "PremiumUntil", Value: user.PremiumUntil}}); err != nil {
+			return err
+		}
+		if err := tx.Update(promoRef, []firestore.Update{{Path: "Uses", Value: promo.Uses}}); err != nil {
+			return err
+		}
+		return tx.Create(redemptionRef, map[string]any{"ChatId": chatId, "RedeemedAt": now})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// RewardReferral gives the bonus to the invited user and their referrer in a transaction and returns referrer's chat id
+func (store *FirestoreStore) RewardReferral(ctx context.Context, chatId int64, bonus ReferralBonus, now int64) (int64, error) {
+	userRef := store.db.Collection("users").Doc(strconv.FormatInt(chatId, 10))
+	var referrerId int64
+	err := store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		//Get the user and their referrer
+		doc, err := tx.Get(userRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if user.ReferredBy == 0 || user.ReferralRewarded {
+			return ErrNoReferralBonus
+		}
+		referrerRef := store.db.Collection("users").Doc(strconv.FormatInt(user.ReferredBy, 10))
+		doc, err = tx.Get(referrerRef)
+		if status.Code(err) == codes.NotFound {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var referrer User
+		if err := doc.DataTo(&referrer); err != nil {
+			return err
+		}
+
+		//Give the bonus to both of them
+		if err := rewardReferral(&user, &referrer, bonus, now); err != nil {
+			return err
+		}
+		for ref, u := range map[*firestore.DocumentRef]*User{userRef: &user, referrerRef: &referrer} {
+			if err := tx.Update(ref, []firestore.Update{
+				{Path: "BonusSentences", Value: u.BonusSentences},
+				{Path: "PremiumUntil", Value: u.PremiumUntil},
+				{Path: "ReferralRewarded", Value: u.ReferralRewarded},
+			}); err != nil {
+				return err
+			}
+		}
+		referrerId = referrer.ChatId
+		return nil
+	})
+	return referrerId, err
+}
+
+// CountReferrals returns how many users the user has invited
+func (store *FirestoreStore) CountReferrals(ctx context.Context, chatId int64) (int, error) {
+	query := store.db.Collection("users").Where("ReferredBy", "==", chatId)
+	res, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := res["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count %v", res["count"])
+	}
+	return int(count.GetIntegerValue()), nil
+}
+
+// GetJobState returns state of the job
+func (store *FirestoreStore) GetJobState(ctx context.Context, name string) (*JobState, error) {
+	doc, err := store.db.Collection("jobs").Doc(name).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state JobState
+	if err := doc.DataTo(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveJobState saves state of the job overriding the previous one
+func (store *FirestoreStore) SaveJobState(ctx context.Context, state *JobState) error {
+	_, err := store.db.Collection("jobs").Doc(state.Name).Set(ctx, state)
+	return err
+}
+
+// LeaseJob leases the job until the unix time until in a transaction
+func (store *FirestoreStore) LeaseJob(ctx context.Context, name string, now, until int64) (*JobState, error) {
+	ref := store.db.Collection("jobs").Doc(name)
+	var state JobState
+	err := store.db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		state = JobState{Name: name}
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&state); err != nil {
+				return err
+			}
+		}
+		if err := leaseJob(&state, now, until); err != nil {
+			return err
+		}
+		return tx.Set(ref, &state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UsersWithPremiumEnding returns users whose premium ends in the (after, until] interval
+func (store *FirestoreStore) UsersWithPremiumEnding(ctx context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(ctx, "PremiumUntil", after, until)
+}
+
+// UsersWithTrialEnding returns users whose premium trial ends in the (after, until] interval
+func (store *FirestoreStore) UsersWithTrialEnding(ctx context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(ctx, "TrialUntil", after, until)
+}
+
+// usersEnding returns users whose value of the field is in the (after, until] interval
+func (store *FirestoreStore) usersEnding(ctx context.Context, field string, after, until int64) ([]*User, error) {
+	docs, err := store.db.Collection("users").Where(field, ">", after).Where(field, "<=", until).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, len(docs))
+	for _, doc := range docs {
+		user, err := userFromSnapshot(doc)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// AddCard saves the card to the user's cards collection
+func (store *FirestoreStore) AddCard(ctx context.Context, card *Card) error {
+	doc := store.cards(card.ChatId).NewDoc()
+	card.ID = doc.ID
+	_, err := doc.Create(ctx, card)
+	return err
+}
+
+// GetCards returns all user's cards from the oldest to the newest
+func (store *FirestoreStore) GetCards(ctx context.Context, chatId int64) ([]*Card, error) {
+	docs, err := store.cards(chatId).OrderBy("CreatedAt", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]*Card, 0, len(docs))
+	for _, doc := range docs {
+		var card Card
+		if err := doc.DataTo(&card); err != nil {
+			return nil, err
+		}
+		card.ID = doc.Ref.ID
+		cards = append(cards, &card)
+	}
+	return cards, nil
+}
+
+// ListCards returns up to limit user's cards from the newest to the oldest skipping the first offset cards
+func (store *FirestoreStore) ListCards(ctx context.Context, chatId int64, offset, limit int) ([]*Card, bool, error) {
+	//Request one more card to find out if there are more cards
+	docs, err := store.cards(chatId).OrderBy("CreatedAt", firestore.Desc).Offset(offset).Limit(limit + 1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(docs) > limit
+	if hasMore {
+		docs = docs[:limit]
+	}
+
+	cards := make([]*Card, 0, len(docs))
+	for _, doc := range docs {
+		var card Card
+		if err := doc.DataTo(&card); err != nil {
+			return nil, false, err
+		}
+		card.ID = doc.Ref.ID
+		cards = append(cards, &card)
+	}
+	return cards, hasMore, nil
+}
+
+// DeleteCard deletes user's card
+func (store *FirestoreStore) DeleteCard(ctx context.Context, chatId int64, cardId string) error {
+	_, err := store.cards(chatId).Doc(cardId).Delete(ctx)
+	return err
+}
+
+// GetCard returns user's card
+func (store *FirestoreStore) GetCard(ctx context.Context, chatId int64, cardId string) (*Card, error) {
+	doc, err := store.cards(chatId).Doc(cardId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var card Card
+	if err := doc.DataTo(&card); err != nil {
+		return nil, err
+	}
+	card.ID = doc.Ref.ID
+	return &card, nil
+}
+
+// DueCards returns up to limit user's cards that are due at the unix time now, the most overdue first
+func (store *FirestoreStore) DueCards(ctx context.Context, chatId int64, now int64, limit int) ([]*Card, error) {
+	docs, err := store.cards(chatId).Where("Due", "<=", now).OrderBy("Due", firestore.Asc).Limit(limit).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]*Card, 0, len(docs))
+	for _, doc := range docs {
+		var card Card
+		if err := doc.DataTo(&card); err != nil {
+			return nil, err
+		}
+		card.ID = doc.Ref.ID
+		cards = append(cards, &card)
+	}
+	return cards, nil
+}
+
+// UpdateCard updates card overriding all fields with the provided card struct
+func (store *FirestoreStore) UpdateCard(ctx context.Context, card *Card) error {
+	ref := store.cards(card.ChatId).Doc(card.ID)
+	//Set would create the card if it was deleted, so make sure it still exists
+	if _, err := ref.Get(ctx); status.Code(err) == codes.NotFound {
+		return ErrCardNotFound
+	} else if err != nil {
+		return err
+	}
+	_, err := ref.Set(ctx, card)
+	return err
+}
+
+// UpdateCardSchedule saves spaced repetition schedule fields of the card
+func (store *FirestoreStore) UpdateCardSchedule(ctx context.Context, card *Card) error {
+	_, err := store.cards(card.ChatId).Doc(card.ID).Update(ctx, []firestore.Update{
+		{Path: "Due", Value: card.Due},
+		{Path: "Interval", Value: card.Interval},
+		{Path: "Ease", Value: card.Ease},
+		{Path: "Repetitions", Value: card.Repetitions},
+		{Path: "Lapses", Value: card.Lapses},
+	})
+	if status.Code(err) == codes.NotFound {
+		return ErrCardNotFound
+	}
+	return err
+}
+
+// cards returns collection of the user's cards
+func (store *FirestoreStore) cards(chatId int64) *firestore.CollectionRef {
+	return store.db.Collection("users").Doc(strconv.Itoa(int(chatId))).Collection("cards")
+}
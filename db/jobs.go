@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrJobNotFound is returned by GetJobState when the job has never been run
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobLeased is returned by LeaseJob when another instance of the bot is running the job
+	ErrJobLeased = errors.New("job is leased by another instance")
+)
+
+// JobState is the state of a background job kept between restarts of the bot
+type JobState struct {
+	Name        string
+	LastRun     int64 //unix time of the last successful run, zero if the job has never been run
+	LeasedUntil int64 //unix time until which the job is run by the instance that leased it, zero if it is not running
+}
+
+// JobStore is implemented by every storage backend that keeps the state of background jobs
+type JobStore interface {
+	// GetJobState returns state of the job, returns ErrJobNotFound if the job has never been run
+	GetJobState(ctx context.Context, name string) (*JobState, error)
+	// SaveJobState saves state of the job overriding the previous one
+	SaveJobState(ctx context.Context, state *JobState) error
+	// LeaseJob leases the job until the unix time until in a transaction, so a single instance of the bot runs it at a time.
+	// Returns state of the job, or ErrJobLeased if the lease of another instance is still valid at the unix time now.
+	// The lease is released by saving the state with zero LeasedUntil
+	LeaseJob(ctx context.Context, name string, now, until int64) (*JobState, error)
+	// UsersWithPremiumEnding returns users whose premium ends after the unix time after and no later than the unix time until
+	UsersWithPremiumEnding(ctx context.Context, after, until int64) ([]*User, error)
+	// UsersWithTrialEnding returns users whose premium trial ends after the unix time after and no later than the unix time until
+	UsersWithTrialEnding(ctx context.Context, after, until int64) ([]*User, error)
+}
+
+// leaseJob checks that nobody else runs the job and leases it until the unix time until
+func leaseJob(state *JobState, now, until int64) error {
+	if state.LeasedUntil > now {
+		return ErrJobLeased
+	}
+	state.LeasedUntil = until
+	return nil
+}
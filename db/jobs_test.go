@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLeaseJob(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		state, err := store.LeaseJob(ctx, "reminders", 100, 200)
+		if err != nil {
+			t.Fatalf("error leasing new job: %v", err)
+		}
+		if state.LastRun != 0 || state.LeasedUntil != 200 {
+			t.Fatalf("new job state = %+v", state)
+		}
+
+		//Another instance can't run the job until the lease ends
+		if _, err := store.LeaseJob(ctx, "reminders", 150, 250); !errors.Is(err, ErrJobLeased) {
+			t.Fatalf("leasing leased job returned %v", err)
+		}
+		if _, err := store.LeaseJob(ctx, "other", 150, 250); err != nil {
+			t.Fatalf("error leasing another job: %v", err)
+		}
+
+		//Saving the state releases the lease
+		if err := store.SaveJobState(ctx, &JobState{Name: "reminders", LastRun: 160}); err != nil {
+			t.Fatalf("error saving job state: %v", err)
+		}
+		state, err = store.LeaseJob(ctx, "reminders", 170, 270)
+		if err != nil {
+			t.Fatalf("error leasing released job: %v", err)
+		}
+		if state.LastRun != 160 || state.LeasedUntil != 270 {
+			t.Fatalf("released job state = %+v", state)
+		}
+
+		//Lease of the instance that stopped expires
+		if _, err := store.LeaseJob(ctx, "reminders", 270, 370); err != nil {
+			t.Fatalf("error leasing job with expired lease: %v", err)
+		}
+	})
+}
+
+func TestUsersEnding(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		for _, user := range []*User{{ChatId: 2, PremiumUntil: 10}, {ChatId: 3, PremiumUntil: 20, TrialUntil: 15}, {ChatId: 4, TrialUntil: 30}} {
+			if err := store.CreateUser(ctx, user); err != nil {
+				t.Fatalf("error creating user: %v", err)
+			}
+		}
+
+		premium, err := store.UsersWithPremiumEnding(ctx, 10, 20)
+		if err != nil {
+			t.Fatalf("error getting users with premium ending: %v", err)
+		}
+		if len(premium) != 1 || premium[0].ChatId != 3 {
+			t.Fatalf("got %d users with premium ending", len(premium))
+		}
+
+		trial, err := store.UsersWithTrialEnding(ctx, 10, 30)
+		if err != nil {
+			t.Fatalf("error getting users with trial ending: %v", err)
+		}
+		if len(trial) != 2 {
+			t.Fatalf("got %d users with trial ending, want 2", len(trial))
+		}
+	})
+}
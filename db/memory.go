@@ -0,0 +1,475 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps users and their cards in memory. Data is lost when the process exits, so it is meant for local runs and tests
+type MemoryStore struct {
+	mu       sync.Mutex
+	users    map[int64]User
+	cards    map[int64][]Card
+	payments map[string]Payment
+	promos   map[string]PromoCode
+	redeemed map[promoRedemption]bool
+	jobs     map[string]JobState
+	lastID   int
+}
+
+// promoRedemption is the promo code redeemed by the user
+type promoRedemption struct {
+	code   string
+	chatId int64
+}
+
+// NewMemory creates new empty in-memory store
+func NewMemory() *MemoryStore {
+	return &MemoryStore{
+		users:    make(map[int64]User),
+		cards:    make(map[int64][]Card),
+		payments: make(map[string]Payment),
+		promos:   make(map[string]PromoCode),
+		redeemed: make(map[promoRedemption]bool),
+		jobs:     make(map[string]JobState),
+	}
+}
+
+// Close does nothing because there is nothing to release
+func (store *MemoryStore) Close() error {
+	return nil
+}
+
+// CreateUser Creates user if user does not exist
+func (store *MemoryStore) CreateUser(_ context.Context, user *User) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.users[user.ChatId]; ok {
+		return fmt.Errorf("user %d already exists", user.ChatId)
+	}
+	store.users[user.ChatId] = *user
+	return nil
+}
+
+// UpdateUser updates user overriding all fields with the provided user struct
+func (store *MemoryStore) UpdateUser(_ context.Context, user *User) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.users[user.ChatId] = *user
+	return nil
+}
+
+// GetUser retrieves a copy of the user using telegram chat id
+func (store *MemoryStore) GetUser(_ context.Context, chatId int64) (*User, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	user, ok := store.users[chatId]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// FindUserByUserName retrieves user by telegram username ignoring case
+func (store *MemoryStore) FindUserByUserName(_ context.Context, userName string) (*User, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, user := range store.users {
+		if strings.EqualFold(user.UserName, userName) {
+			return &user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// SetUserSentenceLanguage updates user's language of generated sentences
+func (store *MemoryStore) SetUserSentenceLanguage(_ context.Context, chatId int64, sentenceLanguage string) error {
+	return store.update(chatId, func(user *User) {
+		user.SentenceLanguage = sentenceLanguage
+	})
+}
+
+// UpdateUserPremium updates user premiumUntil field to a new time stamp provided in unix time format
+func (store *MemoryStore) UpdateUserPremium(_ context.Context, chatId int64, premiumUntil int64) error {
+	return store.update(chatId, func(user *User) {
+		user.PremiumUntil = premiumUntil
+	})
+}
+
+// SetUserLevel sets language level (e.g. A1, B2) and marks user's preferences as set
+func (store *MemoryStore) SetUserLevel(_ context.Context, chatId int64, level string) error {
+	return store.update(chatId, func(user *User) {
+		user.Level = level
+		user.PreferencesSet = true
+	})
+}
+
+// SetUserTimezone sets user's timezone used for the daily reset of free sentences
+func (store *MemoryStore) SetUserTimezone(_ context.Context, chatId int64, timezone string) error {
+	return store.update(chatId, func(user *User) {
+		user.Timezone = timezone
+	})
+}
+
+// SetUserSubscription records user's recurring subscription paid until the unix time, the subscription is no longer canceled
+func (store *MemoryStore) SetUserSubscription(_ context.Context, chatId int64, chargeId string, until int64) error {
+	return store.update(chatId, func(user *User) {
+		user.SubscriptionChargeID = chargeId
+		user.SubscriptionUntil = until
+		user.SubscriptionCanceled = false
+		user.Paid = true
+	})
+}
+
+// SetUserSubscriptionCanceled marks whether user's subscription will be renewed when its current period ends
+func (store *MemoryStore) SetUserSubscriptionCanceled(_ context.Context, chatId int64, canceled bool) error {
+	return store.update(chatId, func(user *User) {
+		user.SubscriptionCanceled = canceled
+	})
+}
+
+// SetUserBannedUntil bans user until the unix time, zero lifts the ban
+func (store *MemoryStore) SetUserBannedUntil(_ context.Context, chatId int64, until int64) error {
+	return store.update(chatId, func(user *User) {
+		user.BannedUntil = until
+	})
+}
+
+// SetUserRemindedAt records that user was reminded that premium or trial ends in the reminders period ending at the unix time
+func (store *MemoryStore) SetUserRemindedAt(_ context.Context, chatId int64, at int64) error {
+	return store.update(chatId, func(user *User) {
+		user.RemindedAt = at
+	})
+}
+
+// ReserveSentence resets user's free sentences if the reset time has passed and takes one of them
+func (store *MemoryStore) ReserveSentence(_ context.Context, chatId int64, reset QuotaReset) (*Reservation, error) {
+	var r *Reservation
+	var reserveErr error
+	if err := store.update(chatId, func(user *User) {
+		r, reserveErr = reserve(user, reset)
+	}); err != nil {
+		return nil, err
+	}
+	return r, reserveErr
+}
+
+// CommitSentence marks reserved sentence as used at the unix time now
+func (store *MemoryStore) CommitSentence(_ context.Context, r *Reservation, now int64) error {
+	return store.update(r.ChatId, func(user *User) {
+		user.LastUsed = now
+	})
+}
+
+// RefundSentence returns reserved sentence to the user
+func (store *MemoryStore) RefundSentence(_ context.Context, r *Reservation) error {
+	return store.update(r.ChatId, func(user *User) {
+		refund(user, r)
+	})
+}
+
+// ApplyPayment records the payment and extends user's premium by its duration
+func (store *MemoryStore) ApplyPayment(_ context.Context, p *Payment) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.payments[p.ChargeID]; ok {
+		return ErrDuplicatePayment
+	}
+	user, ok := store.users[p.PremiumChatId()]
+	if !ok {
+		return ErrUserNotFound
+	}
+	applyPayment(&user, p)
+	store.users[user.ChatId] = user
+	store.payments[p.ChargeID] = *p
+	return nil
+}
+
+// GetPayment returns the payment by its charge id
+func (store *MemoryStore) GetPayment(_ context.Context, chargeId string) (*Payment, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	p, ok := store.payments[chargeId]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	return &p, nil
+}
+
+// RefundPayment marks the payment as refunded and takes back the premium it granted
+func (store *MemoryStore) RefundPayment(_ context.Context, chargeId string, now int64) (*Payment, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	p, ok := store.payments[chargeId]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	user, ok := store.users[p.PremiumChatId()]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if err := refundPayment(&user, &p, now); err != nil {
+		return nil, err
+	}
+	store.users[user.ChatId] = user
+	store.payments[chargeId] = p
+	return &p, nil
+}
+
+// CreatePromoCode saves new promo code
+func (store *MemoryStore) CreatePromoCode(_ context.Context, promo *PromoCode) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.promos[promo.Code]; ok {
+		return ErrPromoCodeExists
+	}
+	store.promos[promo.Code] = *promo
+	return nil
+}
+
+// RedeemPromoCode extends user's premium by the days of the code and records that the user redeemed it
+func (store *MemoryStore) RedeemPromoCode(_ context.Context, code string, chatId int64, now int64) (*PromoCode, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	promo, ok := store.promos[code]
+	if !ok {
+		return nil, ErrPromoCodeNotFound
+	}
+	user, ok := store.users[chatId]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	redemption := promoRedemption{code: code, chatId: chatId}
+	if err := redeemPromoCode(&promo, &user, store.redeemed[redemption], now); err != nil {
+		return nil, err
+	}
+	store.promos[code] = promo
+	store.users[chatId] = user
+	store.redeemed[redemption] = true
+	return &promo, nil
+}
+
+// RewardReferral gives the bonus to the invited user and their referrer and returns referrer's chat id
+func (store *MemoryStore) RewardReferral(_ context.Context, chatId int64, bonus ReferralBonus, now int64) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	user, ok := store.users[chatId]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	referrer, ok := store.users[user.ReferredBy]
+	if !ok && user.ReferredBy != 0 {
+		return 0, ErrUserNotFound
+	}
+	if err := rewardReferral(&user, &referrer, bonus, now); err != nil {
+		return 0, err
+	}
+	store.users[chatId] = user
+	store.users[referrer.ChatId] = referrer
+	return referrer.ChatId, nil
+}
+
+// StartTrial gives user premium trial until the unix time until
+func (store *MemoryStore) StartTrial(_ context.Context, chatId int64, until int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	user, ok := store.users[chatId]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if err := startTrial(&user, until); err != nil {
+		return err
+	}
+	store.users[chatId] = user
+	return nil
+}
+
+// CountReferrals returns how many users the user has invited
+func (store *MemoryStore) CountReferrals(_ context.Context, chatId int64) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var n int
+	for _, user := range store.users {
+		if user.ReferredBy == chatId {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GetJobState returns a copy of the state of the job
+func (store *MemoryStore) GetJobState(_ context.Context, name string) (*JobState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	state, ok := store.jobs[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return &state, nil
+}
+
+// SaveJobState saves state of the job overriding the previous one
+func (store *MemoryStore) SaveJobState(_ context.Context, state *JobState) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.jobs[state.Name] = *state
+	return nil
+}
+
+// LeaseJob leases the job until the unix time until and returns a copy of its state
+func (store *MemoryStore) LeaseJob(_ context.Context, name string, now, until int64) (*JobState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	state, ok := store.jobs[name]
+	if !ok {
+		state = JobState{Name: name}
+	}
+	if err := leaseJob(&state, now, until); err != nil {
+		return nil, err
+	}
+	store.jobs[name] = state
+	return &state, nil
+}
+
+// UsersWithPremiumEnding returns copies of users whose premium ends in the (after, until] interval
+func (store *MemoryStore) UsersWithPremiumEnding(_ context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(func(user *User) int64 { return user.PremiumUntil }, after, until), nil
+}
+
+// UsersWithTrialEnding returns copies of users whose premium trial ends in the (after, until] interval
+func (store *MemoryStore) UsersWithTrialEnding(_ context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(func(user *User) int64 { return user.TrialUntil }, after, until), nil
+}
+
+// usersEnding returns copies of users whose end time returned by end is in the (after, until] interval
+func (store *MemoryStore) usersEnding(end func(user *User) int64, after, until int64) []*User {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var users []*User
+	for _, user := range store.users {
+		if end(&user) > after && end(&user) <= until {
+			users = append(users, &user)
+		}
+	}
+	return users
+}
+
+// ListCards returns up to limit user's cards from the newest to the oldest skipping the first offset cards
+func (store *MemoryStore) ListCards(_ context.Context, chatId int64, offset, limit int) ([]*Card, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	all := store.cards[chatId]
+	var cards []*Card
+	for i := len(all) - 1 - offset; i >= 0 && len(cards) < limit; i-- {
+		card := all[i]
+		cards = append(cards, &card)
+	}
+	return cards, len(all) > offset+limit, nil
+}
+
+// DeleteCard deletes user's card
+func (store *MemoryStore) DeleteCard(_ context.Context, chatId int64, cardId string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.cards[chatId] = slices.DeleteFunc(store.cards[chatId], func(card Card) bool {
+		return card.ID == cardId
+	})
+	return nil
+}
+
+// GetCard returns a copy of user's card
+func (store *MemoryStore) GetCard(_ context.Context, chatId int64, cardId string) (*Card, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, card := range store.cards[chatId] {
+		if card.ID == cardId {
+			return &card, nil
+		}
+	}
+	return nil, ErrCardNotFound
+}
+
+// DueCards returns up to limit user's cards that are due at the unix time now, the most overdue first
+func (store *MemoryStore) DueCards(_ context.Context, chatId int64, now int64, limit int) ([]*Card, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var cards []*Card
+	for _, card := range store.cards[chatId] {
+		if card.Due <= now {
+			cards = append(cards, &card)
+		}
+	}
+	slices.SortStableFunc(cards, func(a, b *Card) int {
+		return cmp.Compare(a.Due, b.Due)
+	})
+	if len(cards) > limit {
+		cards = cards[:limit]
+	}
+	return cards, nil
+}
+
+// UpdateCard updates card overriding all fields with the provided card struct
+func (store *MemoryStore) UpdateCard(_ context.Context, card *Card) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for i, c := range store.cards[card.ChatId] {
+		if c.ID == card.ID {
+			store.cards[card.ChatId][i] = *card
+			return nil
+		}
+	}
+	return ErrCardNotFound
+}
+
+// UpdateCardSchedule saves spaced repetition schedule fields of the card
+func (store *MemoryStore) UpdateCardSchedule(_ context.Context, card *Card) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for i, c := range store.cards[card.ChatId] {
+		if c.ID == card.ID {
+			c.Due, c.Interval, c.Ease, c.Repetitions, c.Lapses = card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses
+			store.cards[card.ChatId][i] = c
+			return nil
+		}
+	}
+	return ErrCardNotFound
+}
+
+// update applies fn to the stored user under the lock, returns ErrUserNotFound if there is no such user
+func (store *MemoryStore) update(chatId int64, fn func(user *User)) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	user, ok := store.users[chatId]
+	if !ok {
+		return ErrUserNotFound
+	}
+	fn(&user)
+	store.users[chatId] = user
+	return nil
+}
+
+// AddCard saves the card and sets its ID
+func (store *MemoryStore) AddCard(_ context.Context, card *Card) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.lastID++
+	card.ID = strconv.Itoa(store.lastID)
+	store.cards[card.ChatId] = append(store.cards[card.ChatId], *card)
+	return nil
+}
+
+// GetCards returns copies of all user's cards from the oldest to the newest
+func (store *MemoryStore) GetCards(_ context.Context, chatId int64) ([]*Card, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	cards := make([]*Card, 0, len(store.cards[chatId]))
+	for _, card := range store.cards[chatId] {
+		cards = append(cards, &card)
+	}
+	return cards, nil
+}
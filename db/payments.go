@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrDuplicatePayment is returned by ApplyPayment when the payment with the same charge id has already been applied
+	ErrDuplicatePayment = errors.New("payment already applied")
+	// ErrPaymentNotFound is returned when the requested payment does not exist in the ledger
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentRefunded is returned by RefundPayment when the payment has already been refunded
+	ErrPaymentRefunded = errors.New("payment already refunded")
+)
+
+// Payment is a successful payment of the user recorded in the payments ledger
+type Payment struct {
+	ChargeID         string //telegram_payment_charge_id, unique for every payment
+	ProviderChargeID string //provider_payment_charge_id
+	ChatId           int64  //Chat id of the user who paid
+	Recipient        int64  //Chat id of the user premium was gifted to, zero if the payer got it
+	Amount           int    //Total amount in the smallest units of the currency, Telegram Stars for XTR
+	Currency         string //e.g. XTR
+	Payload          string //Invoice payload
+	CreatedAt        int64  //unix time
+	Duration         int64  //Seconds of premium the payment granted
+	PremiumUntil     int64  //User's premiumUntil after the payment was applied
+	RefundedAt       int64  //unix time, zero if the payment was not refunded
+	Lifetime         bool   //The payment is for the lifetime plan
+}
+
+// PaymentStore is implemented by every storage backend that keeps the payments ledger
+type PaymentStore interface {
+	// ApplyPayment records the payment and extends premium of the user who got it by its duration in a single transaction.
+	// Premium is extended from the payment time or from the end of the current premium if it is later.
+	// Returns ErrDuplicatePayment if the payment with the same charge id has already been applied
+	ApplyPayment(ctx context.Context, p *Payment) error
+	// GetPayment returns the payment by its charge id, returns ErrPaymentNotFound if there is no such payment
+	GetPayment(ctx context.Context, chargeId string) (*Payment, error)
+	// RefundPayment marks the payment as refunded at the unix time now and takes back the premium it granted in a single transaction.
+	// Returns ErrPaymentRefunded if the payment has already been refunded
+	RefundPayment(ctx context.Context, chargeId string, now int64) (*Payment, error)
+}
+
+// PremiumChatId returns chat id of the user who got premium for the payment
+func (p *Payment) PremiumChatId() int64 {
+	if p.Recipient != 0 {
+		return p.Recipient
+	}
+	return p.ChatId
+}
+
+// refundPayment takes back the premium the payment granted and marks the payment as refunded
+func refundPayment(user *User, p *Payment, now int64) error {
+	if p.RefundedAt != 0 {
+		return ErrPaymentRefunded
+	}
+	user.PremiumUntil -= p.Duration
+	if p.Lifetime {
+		user.Lifetime = false
+	}
+	p.RefundedAt = now
+	return nil
+}
+
+// applyPayment extends user's premium by the payment duration and sets PremiumUntil of the payment
+func applyPayment(user *User, p *Payment) {
+	user.Paid = true
+	if p.Lifetime {
+		user.Lifetime = true
+	}
+	user.PremiumUntil = max(user.PremiumUntil, p.CreatedAt) + p.Duration
+	p.PremiumUntil = user.PremiumUntil
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestApplyPaymentOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		payment := func(chargeId string) *Payment {
+			return &Payment{ChargeID: chargeId, ChatId: 1, Amount: 100, Currency: "XTR", Payload: "premium", CreatedAt: 100, Duration: 10}
+		}
+
+		p := payment("a")
+		if err := store.ApplyPayment(ctx, p); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if err := store.ApplyPayment(ctx, payment("a")); !errors.Is(err, ErrDuplicatePayment) {
+			t.Fatalf("applying payment twice returned %v", err)
+		}
+		if user := mustGetUser(t, store, 1); user.PremiumUntil != 110 || p.PremiumUntil != 110 || !user.Paid {
+			t.Fatalf("user after duplicate payment has premium until %d, paid %v", user.PremiumUntil, user.Paid)
+		}
+
+		//Premium is extended from the end of the current one
+		p = payment("b")
+		if err := store.ApplyPayment(ctx, p); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if p.PremiumUntil != 120 || mustGetUser(t, store, 1).PremiumUntil != 120 {
+			t.Fatalf("second payment gave premium until %d", p.PremiumUntil)
+		}
+
+		if got, err := store.GetPayment(ctx, "b"); err != nil || *got != *p {
+			t.Fatalf("GetPayment = %+v, %v, want %+v", got, err, p)
+		}
+
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "c", ChatId: 2}); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("payment of unknown user returned %v", err)
+		}
+		if _, err := store.GetPayment(ctx, "c"); !errors.Is(err, ErrPaymentNotFound) {
+			t.Fatalf("payment of unknown user was recorded: %v", err)
+		}
+	})
+}
+
+func TestApplyGiftPayment(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.CreateUser(ctx, &User{ChatId: 2}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+
+		//Premium goes to the recipient, the payer keeps the payment
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "a", ChatId: 1, Recipient: 2, CreatedAt: 100, Duration: 10}); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if mustGetUser(t, store, 1).PremiumUntil != 0 || mustGetUser(t, store, 2).PremiumUntil != 110 {
+			t.Fatal("gifted premium was given to the payer")
+		}
+
+		if _, err := store.RefundPayment(ctx, "a", 105); err != nil {
+			t.Fatalf("error refunding payment: %v", err)
+		}
+		if got := mustGetUser(t, store, 2).PremiumUntil; got != 100 {
+			t.Fatalf("refund left recipient premium until %d", got)
+		}
+	})
+}
+
+func TestLifetimePayment(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.CreateUser(ctx, &User{ChatId: 2}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "a", ChatId: 1, CreatedAt: 100, Duration: 10}); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if mustGetUser(t, store, 1).Lifetime {
+			t.Fatal("payment for a plan with days gave lifetime premium")
+		}
+
+		//Lifetime plan gifted to another user
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "b", ChatId: 1, Recipient: 2, CreatedAt: 100, Duration: 10, Lifetime: true}); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if mustGetUser(t, store, 1).Lifetime || !mustGetUser(t, store, 2).Lifetime {
+			t.Fatal("lifetime premium was not given to the recipient")
+		}
+		if p, err := store.GetPayment(ctx, "b"); err != nil || !p.Lifetime {
+			t.Fatalf("lifetime payment = %+v, %v", p, err)
+		}
+
+		if _, err := store.RefundPayment(ctx, "b", 105); err != nil {
+			t.Fatalf("error refunding payment: %v", err)
+		}
+		if mustGetUser(t, store, 2).Lifetime {
+			t.Fatal("refund did not take back lifetime premium")
+		}
+	})
+}
+
+func TestRefundPaymentOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "a", ChatId: 1, CreatedAt: 100, Duration: 10}); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+
+		//Refund takes back only the premium, other fields of the user are kept as they are
+		user := mustGetUser(t, store, 1)
+		user.Paid, user.FreeSentences = false, 7
+		if err := store.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("error updating user: %v", err)
+		}
+
+		p, err := store.RefundPayment(ctx, "a", 105)
+		if err != nil {
+			t.Fatalf("error refunding payment: %v", err)
+		}
+		if p.RefundedAt != 105 || mustGetUser(t, store, 1).PremiumUntil != 100 {
+			t.Fatalf("refund left premium until %d, refunded at %d", mustGetUser(t, store, 1).PremiumUntil, p.RefundedAt)
+		}
+		if user := mustGetUser(t, store, 1); user.Paid || user.FreeSentences != 7 {
+			t.Fatalf("refund changed the user: %+v", user)
+		}
+		if got, err := store.GetPayment(ctx, "a"); err != nil || got.RefundedAt != 105 {
+			t.Fatalf("refunded payment = %+v, %v", got, err)
+		}
+		if _, err := store.RefundPayment(ctx, "a", 106); !errors.Is(err, ErrPaymentRefunded) {
+			t.Fatalf("refunding payment twice returned %v", err)
+		}
+		if _, err := store.RefundPayment(ctx, "b", 106); !errors.Is(err, ErrPaymentNotFound) {
+			t.Fatalf("refunding unknown payment returned %v", err)
+		}
+	})
+}
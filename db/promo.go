@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrPromoCodeNotFound is returned when the promo code does not exist
+	ErrPromoCodeNotFound = errors.New("promo code not found")
+	// ErrPromoCodeExists is returned by CreatePromoCode when the code is already taken
+	ErrPromoCodeExists = errors.New("promo code already exists")
+	// ErrPromoCodeExpired is returned by RedeemPromoCode when the code has expired
+	ErrPromoCodeExpired = errors.New("promo code expired")
+	// ErrPromoCodeUsedUp is returned by RedeemPromoCode when the code has been redeemed the maximum amount of times
+	ErrPromoCodeUsedUp = errors.New("promo code used up")
+	// ErrPromoCodeRedeemed is returned by RedeemPromoCode when the user has already redeemed the code
+	ErrPromoCodeRedeemed = errors.New("promo code already redeemed by the user")
+)
+
+// PromoCode grants premium to every user who redeems it
+type PromoCode struct {
+	Code      string
+	Days      int   //Days of premium the code grants
+	MaxUses   int   //How many users can redeem the code, zero means unlimited
+	Uses      int   //How many users have redeemed the code
+	ExpiresAt int64 //unix time after which the code can't be redeemed, zero means never
+	CreatedAt int64 //unix time
+}
+
+// PromoStore is implemented by every storage backend that keeps promo codes
+type PromoStore interface {
+	// CreatePromoCode saves new promo code, returns ErrPromoCodeExists if the code is already taken
+	CreatePromoCode(ctx context.Context, promo *PromoCode) error
+	// RedeemPromoCode extends user's premium by the days of the code and records that the user redeemed it in a single transaction.
+	// Premium is extended from the unix time now or from the end of the current premium if it is later
+	RedeemPromoCode(ctx context.Context, code string, chatId int64, now int64) (*PromoCode, error)
+}
+
+// redeemPromoCode checks that the user can redeem the code, counts the use and extends user's premium
+func redeemPromoCode(promo *PromoCode, user *User, redeemed bool, now int64) error {
+	switch {
+	case redeemed:
+		return ErrPromoCodeRedeemed
+	case promo.ExpiresAt != 0 && promo.ExpiresAt <= now:
+		return ErrPromoCodeExpired
+	case promo.MaxUses != 0 && promo.Uses >= promo.MaxUses:
+		return ErrPromoCodeUsedUp
+	}
+	promo.Uses++
+	user.PremiumUntil = max(user.PremiumUntil, now) + int64(promo.Days)*24*60*60
+	return nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRedeemPromoCode(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.CreateUser(ctx, &User{ChatId: 2}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		if err := store.CreatePromoCode(ctx, &PromoCode{Code: "SPRING", Days: 1, MaxUses: 1, ExpiresAt: 1000}); err != nil {
+			t.Fatalf("error creating promo code: %v", err)
+		}
+		if err := store.CreatePromoCode(ctx, &PromoCode{Code: "SPRING", Days: 2}); !errors.Is(err, ErrPromoCodeExists) {
+			t.Fatalf("creating promo code twice returned %v", err)
+		}
+
+		promo, err := store.RedeemPromoCode(ctx, "SPRING", 1, 100)
+		if err != nil {
+			t.Fatalf("error redeeming promo code: %v", err)
+		}
+		if promo.Uses != 1 || mustGetUser(t, store, 1).PremiumUntil != 100+24*60*60 {
+			t.Fatalf("redeemed promo code = %+v, premium until %d", promo, mustGetUser(t, store, 1).PremiumUntil)
+		}
+
+		tests := []struct {
+			name   string
+			code   string
+			chatId int64
+			now    int64
+			err    error
+		}{
+			{name: "redeemed twice", code: "SPRING", chatId: 1, now: 100, err: ErrPromoCodeRedeemed},
+			{name: "used up", code: "SPRING", chatId: 2, now: 100, err: ErrPromoCodeUsedUp},
+			{name: "unknown", code: "AUTUMN", chatId: 2, now: 100, err: ErrPromoCodeNotFound},
+		}
+		for _, tt := range tests {
+			if _, err := store.RedeemPromoCode(ctx, tt.code, tt.chatId, tt.now); !errors.Is(err, tt.err) {
+				t.Errorf("%s: RedeemPromoCode returned %v, want %v", tt.name, err, tt.err)
+			}
+		}
+
+		if err := store.CreatePromoCode(ctx, &PromoCode{Code: "SUMMER", Days: 1, ExpiresAt: 1000}); err != nil {
+			t.Fatalf("error creating promo code: %v", err)
+		}
+		if _, err := store.RedeemPromoCode(ctx, "SUMMER", 2, 1000); !errors.Is(err, ErrPromoCodeExpired) {
+			t.Fatalf("redeeming expired promo code returned %v", err)
+		}
+		if mustGetUser(t, store, 2).PremiumUntil != 0 {
+			t.Fatal("failed redemption gave premium")
+		}
+	})
+}
+
+func TestFindUserByUserName(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.CreateUser(ctx, &User{ChatId: 2, UserName: "Amigo"}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		for _, name := range []string{"Amigo", "amigo", "AMIGO"} {
+			if user, err := store.FindUserByUserName(ctx, name); err != nil || user.ChatId != 2 {
+				t.Fatalf("FindUserByUserName(%q) = %+v, %v", name, user, err)
+			}
+		}
+		if _, err := store.FindUserByUserName(ctx, "amiga"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("finding unknown user returned %v", err)
+		}
+
+		//User who changed the username is found by the new one
+		if err := store.UpdateUser(ctx, &User{ChatId: 2, UserName: "AmigaNueva"}); err != nil {
+			t.Fatalf("error updating user: %v", err)
+		}
+		if user, err := store.FindUserByUserName(ctx, "amiganueva"); err != nil || user.ChatId != 2 || user.UserName != "AmigaNueva" {
+			t.Fatalf("user with new username = %+v, %v", user, err)
+		}
+		if _, err := store.FindUserByUserName(ctx, "amigo"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("finding user by the old username returned %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package db
+
+import "errors"
+
+// ErrNoFreeSentences is returned by ReserveSentence when user without premium has no free sentences left
+var ErrNoFreeSentences = errors.New("no free sentences left")
+
+// QuotaReset describes the daily reset of free sentences applied by ReserveSentence
+type QuotaReset struct {
+	Now       int64 //unix time of the reservation
+	Allowance int   //Free sentences user gets when the quota is reset
+	NextReset int64 //unix time of the reset following Now
+}
+
+// Reservation is a free sentence taken from the user by ReserveSentence.
+// It must be either committed after the sentence is sent or refunded if generating it failed
+type Reservation struct {
+	ChatId  int64
+	Charged bool  //False for premium users, they don't use free sentences
+	Bonus   bool  //The sentence was taken from the bonus sentences
+	ResetAt int64 //QuotaResetAt of the user when the sentence was taken
+}
+
+// reserve resets user's quota if the reset time has passed and takes one free sentence from the user.
+// Bonus sentences are used when daily ones run out
+func reserve(user *User, reset QuotaReset) (*Reservation, error) {
+	if user.QuotaResetAt <= reset.Now {
+		user.FreeSentences = reset.Allowance
+		user.QuotaResetAt = reset.NextReset
+	}
+
+	r := &Reservation{ChatId: user.ChatId, ResetAt: user.QuotaResetAt}
+	if user.PremiumUntil > reset.Now || user.TrialUntil > reset.Now {
+		return r, nil
+	}
+	switch {
+	case user.FreeSentences > 0:
+		user.FreeSentences--
+	case user.BonusSentences > 0:
+		user.BonusSentences--
+		r.Bonus = true
+	default:
+		return nil, ErrNoFreeSentences
+	}
+	r.Charged = true
+	return r, nil
+}
+
+// refund returns the reserved sentence to the user. Daily sentence is not returned if the quota was reset after the reservation
+func refund(user *User, r *Reservation) bool {
+	if r.Charged && r.Bonus {
+		user.BonusSentences++
+		return true
+	}
+	if !r.Charged || user.QuotaResetAt != r.ResetAt {
+		return false
+	}
+	user.FreeSentences++
+	return true
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReserveAndRefund(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		reset := QuotaReset{Now: 100, Allowance: 2, NextReset: 200}
+
+		//The first reservation resets the quota of the new user
+		r, err := store.ReserveSentence(ctx, 1, reset)
+		if err != nil {
+			t.Fatalf("error reserving sentence: %v", err)
+		}
+		if !r.Charged || r.Bonus || r.ResetAt != 200 {
+			t.Fatalf("reservation = %+v", r)
+		}
+		if user := mustGetUser(t, store, 1); user.FreeSentences != 1 || user.QuotaResetAt != 200 {
+			t.Fatalf("user after reservation has %d free sentences reset at %d", user.FreeSentences, user.QuotaResetAt)
+		}
+
+		if err := store.RefundSentence(ctx, r); err != nil {
+			t.Fatalf("error refunding sentence: %v", err)
+		}
+		if user := mustGetUser(t, store, 1); user.FreeSentences != 2 {
+			t.Fatalf("user after refund has %d free sentences", user.FreeSentences)
+		}
+
+		for range 2 {
+			if _, err := store.ReserveSentence(ctx, 1, reset); err != nil {
+				t.Fatalf("error reserving sentence: %v", err)
+			}
+		}
+		if _, err := store.ReserveSentence(ctx, 1, reset); !errors.Is(err, ErrNoFreeSentences) {
+			t.Fatalf("reserving over the allowance returned %v", err)
+		}
+
+		//Sentence reserved before the reset is not refunded into the new day
+		r, err = store.ReserveSentence(ctx, 1, QuotaReset{Now: 200, Allowance: 2, NextReset: 300})
+		if err != nil {
+			t.Fatalf("error reserving sentence after reset: %v", err)
+		}
+		if _, err := store.ReserveSentence(ctx, 1, QuotaReset{Now: 300, Allowance: 2, NextReset: 400}); err != nil {
+			t.Fatalf("error reserving sentence after reset: %v", err)
+		}
+		if err := store.RefundSentence(ctx, r); err != nil {
+			t.Fatalf("error refunding sentence: %v", err)
+		}
+		if user := mustGetUser(t, store, 1); user.FreeSentences != 1 {
+			t.Fatalf("refund from the previous day left %d free sentences, want 1", user.FreeSentences)
+		}
+	})
+}
+
+func TestReserveBonusAndPremium(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		reset := QuotaReset{Now: 100, Allowance: 0, NextReset: 200}
+		user := mustGetUser(t, store, 1)
+		user.BonusSentences = 1
+		if err := store.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("error updating user: %v", err)
+		}
+
+		//Bonus sentences are used when daily ones run out and are refunded even after the reset
+		r, err := store.ReserveSentence(ctx, 1, reset)
+		if err != nil || !r.Bonus {
+			t.Fatalf("reserving bonus sentence = %+v, %v", r, err)
+		}
+		if _, err := store.ReserveSentence(ctx, 1, QuotaReset{Now: 200, Allowance: 0, NextReset: 300}); !errors.Is(err, ErrNoFreeSentences) {
+			t.Fatalf("reserving without bonus sentences returned %v", err)
+		}
+		if err := store.RefundSentence(ctx, r); err != nil {
+			t.Fatalf("error refunding sentence: %v", err)
+		}
+		if got := mustGetUser(t, store, 1).BonusSentences; got != 1 {
+			t.Fatalf("user has %d bonus sentences after refund", got)
+		}
+
+		//Premium users don't use free sentences
+		if err := store.UpdateUserPremium(ctx, 1, 1000); err != nil {
+			t.Fatalf("error updating premium: %v", err)
+		}
+		r, err = store.ReserveSentence(ctx, 1, reset)
+		if err != nil || r.Charged {
+			t.Fatalf("reserving premium sentence = %+v, %v", r, err)
+		}
+		if got := mustGetUser(t, store, 1).BonusSentences; got != 1 {
+			t.Fatalf("premium sentence used a bonus sentence")
+		}
+		if err := store.CommitSentence(ctx, r, 150); err != nil {
+			t.Fatalf("error committing sentence: %v", err)
+		}
+		if got := mustGetUser(t, store, 1).LastUsed; got != 150 {
+			t.Fatalf("last used = %d after commit", got)
+		}
+	})
+}
+
+func TestReserveConcurrently(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		reset := QuotaReset{Now: 100, Allowance: 5, NextReset: 200}
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var reserved int
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := store.ReserveSentence(ctx, 1, reset); err == nil {
+					mu.Lock()
+					reserved++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if reserved != 5 {
+			t.Fatalf("%d sentences were reserved with the allowance of 5", reserved)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoReferralBonus is returned by RewardReferral when the user was not invited or the bonus has already been given
+var ErrNoReferralBonus = errors.New("no referral bonus")
+
+// ReferralBonus is given both to the invited user and to the user who invited them
+type ReferralBonus struct {
+	Sentences int `yaml:"sentences"` //Bonus free sentences
+	Days      int `yaml:"days"`      //Days of premium
+}
+
+// ReferralStore is implemented by every storage backend that keeps referrals
+type ReferralStore interface {
+	// RewardReferral gives the bonus to the invited user and their referrer in a single transaction and returns referrer's chat id.
+	// Premium is extended from the unix time now or from the end of the current premium if it is later.
+	// Returns ErrNoReferralBonus if the user was not invited or the bonus has already been given
+	RewardReferral(ctx context.Context, chatId int64, bonus ReferralBonus, now int64) (int64, error)
+	// CountReferrals returns how many users the user has invited
+	CountReferrals(ctx context.Context, chatId int64) (int, error)
+}
+
+// rewardReferral gives the bonus to the invited user and their referrer once
+func rewardReferral(user, referrer *User, bonus ReferralBonus, now int64) error {
+	if user.ReferredBy == 0 || user.ReferralRewarded {
+		return ErrNoReferralBonus
+	}
+	for _, u := range []*User{user, referrer} {
+		u.BonusSentences += bonus.Sentences
+		if bonus.Days > 0 {
+			u.PremiumUntil = max(u.PremiumUntil, now) + int64(bonus.Days)*24*60*60
+		}
+	}
+	user.ReferralRewarded = true
+	return nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRewardReferral(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		for _, chatId := range []int64{2, 3} {
+			if err := store.CreateUser(ctx, &User{ChatId: chatId, ReferredBy: 1}); err != nil {
+				t.Fatalf("error creating user: %v", err)
+			}
+		}
+		bonus := ReferralBonus{Sentences: 5, Days: 1}
+
+		referrer, err := store.RewardReferral(ctx, 2, bonus, 100)
+		if err != nil || referrer != 1 {
+			t.Fatalf("RewardReferral = %d, %v", referrer, err)
+		}
+		for _, chatId := range []int64{1, 2} {
+			if user := mustGetUser(t, store, chatId); user.BonusSentences != 5 || user.PremiumUntil != 100+24*60*60 {
+				t.Fatalf("user %d after reward has %d bonus sentences and premium until %d", chatId, user.BonusSentences, user.PremiumUntil)
+			}
+		}
+
+		//The bonus is given once and only to invited users
+		if _, err := store.RewardReferral(ctx, 2, bonus, 100); !errors.Is(err, ErrNoReferralBonus) {
+			t.Fatalf("rewarding referral twice returned %v", err)
+		}
+		if _, err := store.RewardReferral(ctx, 1, bonus, 100); !errors.Is(err, ErrNoReferralBonus) {
+			t.Fatalf("rewarding user who was not invited returned %v", err)
+		}
+
+		if n, err := store.CountReferrals(ctx, 1); err != nil || n != 2 {
+			t.Fatalf("CountReferrals = %d, %v", n, err)
+		}
+	})
+}
@@ -0,0 +1,718 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations contains schema changes applied in order. The amount of applied migrations is kept in PRAGMA user_version,
+// so new migrations must only ever be appended to the end of the list
+var migrations = []string{
+	`CREATE TABLE users (
+		chat_id           INTEGER PRIMARY KEY,
+		user_name         TEXT    NOT NULL DEFAULT '',
+		sentence_language TEXT    NOT NULL DEFAULT '',
+		level             TEXT    NOT NULL DEFAULT '',
+		premium_until     INTEGER NOT NULL DEFAULT 0,
+		preferences_set   INTEGER NOT NULL DEFAULT 0,
+		last_used         INTEGER NOT NULL DEFAULT 0,
+		free_sentences    INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE cards (
+		id            INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id       INTEGER NOT NULL,
+		word          TEXT    NOT NULL,
+		sentence      TEXT    NOT NULL,
+		translation   TEXT    NOT NULL,
+		language      TEXT    NOT NULL,
+		audio_file_id TEXT    NOT NULL,
+		created_at    INTEGER NOT NULL
+	);
+	CREATE INDEX cards_chat_id ON cards (chat_id, created_at)`,
+	`ALTER TABLE cards ADD COLUMN level TEXT NOT NULL DEFAULT '';
+	ALTER TABLE cards ADD COLUMN model TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE cards ADD COLUMN due INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE cards ADD COLUMN interval INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE cards ADD COLUMN ease REAL NOT NULL DEFAULT 0;
+	ALTER TABLE cards ADD COLUMN repetitions INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE cards ADD COLUMN lapses INTEGER NOT NULL DEFAULT 0;
+	UPDATE cards SET due = created_at;
+	CREATE INDEX cards_due ON cards (chat_id, due)`,
+	`ALTER TABLE cards ADD COLUMN regenerated INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN quota_reset_at INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE payments (
+		charge_id          TEXT    PRIMARY KEY,
+		provider_charge_id TEXT    NOT NULL,
+		chat_id            INTEGER NOT NULL,
+		amount             INTEGER NOT NULL,
+		currency           TEXT    NOT NULL,
+		payload            TEXT    NOT NULL,
+		created_at         INTEGER NOT NULL,
+		duration           INTEGER NOT NULL,
+		premium_until      INTEGER NOT NULL
+	);
+	CREATE INDEX payments_chat_id ON payments (chat_id, created_at)`,
+	`ALTER TABLE payments ADD COLUMN refunded_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE users ADD COLUMN banned_until INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN subscription_charge_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN subscription_until INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN subscription_canceled INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE payments ADD COLUMN recipient INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX users_user_name ON users (user_name COLLATE NOCASE);
+	CREATE TABLE promo_codes (
+		code       TEXT    PRIMARY KEY,
+		days       INTEGER NOT NULL,
+		max_uses   INTEGER NOT NULL,
+		uses       INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE promo_redemptions (
+		code        TEXT    NOT NULL REFERENCES promo_codes (code),
+		chat_id     INTEGER NOT NULL,
+		redeemed_at INTEGER NOT NULL,
+		PRIMARY KEY (code, chat_id)
+	)`,
+	`ALTER TABLE users ADD COLUMN referred_by INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN referral_rewarded INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN bonus_sentences INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX users_referred_by ON users (referred_by)`,
+	`CREATE INDEX users_premium_until ON users (premium_until);
+	CREATE TABLE jobs (
+		name     TEXT    PRIMARY KEY,
+		last_run INTEGER NOT NULL
+	)`,
+	`ALTER TABLE users ADD COLUMN trial_until INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE jobs ADD COLUMN leased_until INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE users ADD COLUMN paid INTEGER NOT NULL DEFAULT 0;
+	UPDATE users SET paid = 1 WHERE subscription_charge_id != ''
+		OR chat_id IN (SELECT CASE WHEN recipient != 0 THEN recipient ELSE chat_id END FROM payments)`,
+	`CREATE INDEX users_trial_until ON users (trial_until)`,
+	//Lifetime plan used to be recorded only as a payment of 100 years of premium
+	`ALTER TABLE payments ADD COLUMN lifetime INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN lifetime INTEGER NOT NULL DEFAULT 0;
+	UPDATE payments SET lifetime = 1 WHERE duration = 3153600000;
+	UPDATE users SET lifetime = 1
+		WHERE chat_id IN (SELECT CASE WHEN recipient != 0 THEN recipient ELSE chat_id END FROM payments WHERE lifetime = 1 AND refunded_at = 0)`,
+	`ALTER TABLE users ADD COLUMN reminded_at INTEGER NOT NULL DEFAULT 0`,
+}
+
+// SQLiteStore keeps users and their cards in a local SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLite opens SQLite database at the given path and brings its schema up to date
+func NewSQLite(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	//SQLite allows only one writer at a time, using a single connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db}
+	if err := store.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// migrate applies migrations that have not been applied yet
+func (store *SQLiteStore) migrate(ctx context.Context) error {
+	var version int
+	if err := store.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := store.db.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("error applying migration %d: %w", i+1, err)
+		}
+		if _, err := store.db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (store *SQLiteStore) Close() error {
+	return store.db.Close()
+}
+
+const userColumns = "chat_id, user_name, sentence_language, level, premium_until, preferences_set, last_used, free_sentences, quota_reset_at, timezone, language, banned_until, subscription_charge_id, subscription_until, subscription_canceled, referred_by, referral_rewarded, bonus_sentences, trial_until, paid, lifetime, reminded_at"
+
+// userValues returns values of the user's fields in the order of userColumns
+func userValues(user *User) []any {
+	return []any{user.ChatId, user.UserName, user.SentenceLanguage, user.Level, user.PremiumUntil, user.PreferencesSet, user.LastUsed, user.FreeSentences, user.QuotaResetAt, user.Timezone, user.Language, user.BannedUntil, user.SubscriptionChargeID, user.SubscriptionUntil, user.SubscriptionCanceled, user.ReferredBy, user.ReferralRewarded, user.BonusSentences, user.TrialUntil, user.Paid, user.Lifetime, user.RemindedAt}
+}
+
+// userFields returns pointers to the user's fields in the order of userColumns to scan a row into
+func userFields(user *User) []any {
+	return []any{&user.ChatId, &user.UserName, &user.SentenceLanguage, &user.Level, &user.PremiumUntil, &user.PreferencesSet, &user.LastUsed, &user.FreeSentences, &user.QuotaResetAt, &user.Timezone, &user.Language, &user.BannedUntil, &user.SubscriptionChargeID, &user.SubscriptionUntil, &user.SubscriptionCanceled, &user.ReferredBy, &user.ReferralRewarded, &user.BonusSentences, &user.TrialUntil, &user.Paid, &user.Lifetime, &user.RemindedAt}
+}
+
+// CreateUser Creates user if user does not exist
+func (store *SQLiteStore) CreateUser(ctx context.Context, user *User) error {
+	_, err := store.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, userValues(user)...)
+	return err
+}
+
+// UpdateUser updates user overriding all fields with the provided user struct
+func (store *SQLiteStore) UpdateUser(ctx context.Context, user *User) error {
+	_, err := store.db.ExecContext(ctx, `INSERT OR REPLACE INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, userValues(user)...)
+	return err
+}
+
+// GetUser retrieves user from the database using telegram chat id
+func (store *SQLiteStore) GetUser(ctx context.Context, chatId int64) (*User, error) {
+	return getUser(ctx, store.db, chatId)
+}
+
+// FindUserByUserName retrieves user by telegram username ignoring case
+func (store *SQLiteStore) FindUserByUserName(ctx context.Context, userName string) (*User, error) {
+	var chatId int64
+	err := store.db.QueryRowContext(ctx, "SELECT chat_id FROM users WHERE user_name = ? COLLATE NOCASE LIMIT 1", userName).Scan(&chatId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store.GetUser(ctx, chatId)
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// getUser retrieves user using either the database or a transaction
+func getUser(ctx context.Context, q rowQuerier, chatId int64) (*User, error) {
+	var user User
+	err := q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE chat_id = ?`, chatId).Scan(userFields(&user)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ReserveSentence resets user's free sentences if the reset time has passed and takes one of them
+func (store *SQLiteStore) ReserveSentence(ctx context.Context, chatId int64, reset QuotaReset) (*Reservation, error) {
+	var r *Reservation
+	var reserveErr error
+	err := store.updateUserTx(ctx, chatId, func(user *User) {
+		r, reserveErr = reserve(user, reset)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, reserveErr
+}
+
+// CommitSentence marks reserved sentence as used at the unix time now
+func (store *SQLiteStore) CommitSentence(ctx context.Context, r *Reservation, now int64) error {
+	return store.exec(ctx, "UPDATE users SET last_used = ? WHERE chat_id = ?", now, r.ChatId)
+}
+
+// RefundSentence returns reserved sentence to the user
+func (store *SQLiteStore) RefundSentence(ctx context.Context, r *Reservation) error {
+	return store.updateUserTx(ctx, r.ChatId, func(user *User) {
+		refund(user, r)
+	})
+}
+
+// ApplyPayment records the payment and extends user's premium by its duration in a single transaction
+func (store *SQLiteStore) ApplyPayment(ctx context.Context, p *Payment) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	//Check if the payment has already been applied
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM payments WHERE charge_id = ?)", p.ChargeID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicatePayment
+	}
+
+	//Extend premium and record the payment
+	user, err := getUser(ctx, tx, p.PremiumChatId())
+	if err != nil {
+		return err
+	}
+	applyPayment(user, p)
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET premium_until = ?, paid = 1, lifetime = ? WHERE chat_id = ?", user.PremiumUntil, user.Lifetime, user.ChatId); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO payments
+		(charge_id, provider_charge_id, chat_id, recipient, amount, currency, payload, created_at, duration, premium_until, lifetime)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ChargeID, p.ProviderChargeID, p.ChatId, p.Recipient, p.Amount, p.Currency, p.Payload, p.CreatedAt, p.Duration, p.PremiumUntil, p.Lifetime); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const paymentColumns = "charge_id, provider_charge_id, chat_id, recipient, amount, currency, payload, created_at, duration, premium_until, refunded_at, lifetime"
+
+// GetPayment returns the payment by its charge id
+func (store *SQLiteStore) GetPayment(ctx context.Context, chargeId string) (*Payment, error) {
+	return getPayment(ctx, store.db, chargeId)
+}
+
+// getPayment returns the payment using either the database or a transaction
+func getPayment(ctx context.Context, q rowQuerier, chargeId string) (*Payment, error) {
+	var p Payment
+	err := q.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE charge_id = ?`, chargeId).Scan(
+		&p.ChargeID, &p.ProviderChargeID, &p.ChatId, &p.Recipient, &p.Amount, &p.Currency, &p.Payload, &p.CreatedAt, &p.Duration, &p.PremiumUntil, &p.RefundedAt, &p.Lifetime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RefundPayment marks the payment as refunded and takes back the premium it granted in a single transaction
+func (store *SQLiteStore) RefundPayment(ctx context.Context, chargeId string, now int64) (*Payment, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	p, err := getPayment(ctx, tx, chargeId)
+	if err != nil {
+		return nil, err
+	}
+	user, err := getUser(ctx, tx, p.PremiumChatId())
+	if err != nil {
+		return nil, err
+	}
+	if err := refundPayment(user, p, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET premium_until = ?, lifetime = ? WHERE chat_id = ?", user.PremiumUntil, user.Lifetime, user.ChatId); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE payments SET refunded_at = ? WHERE charge_id = ?", p.RefundedAt, p.ChargeID); err != nil {
+		return nil, err
+	}
+	return p, tx.Commit()
+}
+
+// CreatePromoCode saves new promo code
+func (store *SQLiteStore) CreatePromoCode(ctx context.Context, promo *PromoCode) error {
+	res, err := store.db.ExecContext(ctx, `INSERT INTO promo_codes (code, days, max_uses, uses, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (code) DO NOTHING`,
+		promo.Code, promo.Days, promo.MaxUses, promo.Uses, promo.ExpiresAt, promo.CreatedAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPromoCodeExists
+	}
+	return nil
+}
+
+// RedeemPromoCode extends user's premium by the days of the code and records that the user redeemed it in a single transaction
+func (store *SQLiteStore) RedeemPromoCode(ctx context.Context, code string, chatId int64, now int64) (*PromoCode, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	//Get the code, the user and check whether they have already redeemed it
+	promo := PromoCode{Code: code}
+	err = tx.QueryRowContext(ctx, "SELECT days, max_uses, uses, expires_at, created_at FROM promo_codes WHERE code = ?", code).Scan(
+		&promo.Days, &promo.MaxUses, &promo.Uses, &promo.ExpiresAt, &promo.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := getUser(ctx, tx, chatId)
+	if err != nil {
+		return nil, err
+	}
+	var redeemed bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM promo_redemptions WHERE code = ? AND chat_id = ?)", code, chatId).Scan(&redeemed); err != nil {
+		return nil, err
+	}
+
+	//Extend premium and record the redemption
+	if err := redeemPromoCode(&promo, user, redeemed, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET premium_until = ? WHERE chat_id = ?", user.PremiumUntil, chatId); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE promo_codes SET uses = ? WHERE code = ?", promo.Uses, code); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO promo_redemptions (code, chat_id, redeemed_at) VALUES (?, ?, ?)", code, chatId, now); err != nil {
+		return nil, err
+	}
+	return &promo, tx.Commit()
+}
+
+// RewardReferral gives the bonus to the invited user and their referrer in a single transaction and returns referrer's chat id
+func (store *SQLiteStore) RewardReferral(ctx context.Context, chatId int64, bonus ReferralBonus, now int64) (int64, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	user, err := getUser(ctx, tx, chatId)
+	if err != nil {
+		return 0, err
+	}
+	if user.ReferredBy == 0 || user.ReferralRewarded {
+		return 0, ErrNoReferralBonus
+	}
+	referrer, err := getUser(ctx, tx, user.ReferredBy)
+	if err != nil {
+		return 0, err
+	}
+	if err := rewardReferral(user, referrer, bonus, now); err != nil {
+		return 0, err
+	}
+	for _, u := range []*User{user, referrer} {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET bonus_sentences = ?, premium_until = ?, referral_rewarded = ? WHERE chat_id = ?",
+			u.BonusSentences, u.PremiumUntil, u.ReferralRewarded, u.ChatId); err != nil {
+			return 0, err
+		}
+	}
+	return referrer.ChatId, tx.Commit()
+}
+
+// CountReferrals returns how many users the user has invited
+func (store *SQLiteStore) CountReferrals(ctx context.Context, chatId int64) (int, error) {
+	var n int
+	err := store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE referred_by = ?", chatId).Scan(&n)
+	return n, err
+}
+
+// StartTrial gives user premium trial until the unix time until in a transaction
+func (store *SQLiteStore) StartTrial(ctx context.Context, chatId int64, until int64) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	user, err := getUser(ctx, tx, chatId)
+	if err != nil {
+		return err
+	}
+	if err := startTrial(user, until); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET trial_until = ? WHERE chat_id = ?", user.TrialUntil, chatId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetJobState returns state of the job
+func (store *SQLiteStore) GetJobState(ctx context.Context, name string) (*JobState, error) {
+	state := JobState{Name: name}
+	err := store.db.QueryRowContext(ctx, "SELECT last_run, leased_until FROM jobs WHERE name = ?", name).Scan(&state.LastRun, &state.LeasedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveJobState saves state of the job overriding the previous one
+func (store *SQLiteStore) SaveJobState(ctx context.Context, state *JobState) error {
+	_, err := store.db.ExecContext(ctx, "INSERT OR REPLACE INTO jobs (name, last_run, leased_until) VALUES (?, ?, ?)", state.Name, state.LastRun, state.LeasedUntil)
+	return err
+}
+
+// LeaseJob leases the job until the unix time until in a transaction
+func (store *SQLiteStore) LeaseJob(ctx context.Context, name string, now, until int64) (*JobState, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	state := JobState{Name: name}
+	err = tx.QueryRowContext(ctx, "SELECT last_run, leased_until FROM jobs WHERE name = ?", name).Scan(&state.LastRun, &state.LeasedUntil)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err := leaseJob(&state, now, until); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO jobs (name, last_run, leased_until) VALUES (?, ?, ?)", state.Name, state.LastRun, state.LeasedUntil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// UsersWithPremiumEnding returns users whose premium ends in the (after, until] interval
+func (store *SQLiteStore) UsersWithPremiumEnding(ctx context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(ctx, "premium_until", after, until)
+}
+
+// UsersWithTrialEnding returns users whose premium trial ends in the (after, until] interval
+func (store *SQLiteStore) UsersWithTrialEnding(ctx context.Context, after, until int64) ([]*User, error) {
+	return store.usersEnding(ctx, "trial_until", after, until)
+}
+
+// usersEnding returns users whose value of the column is in the (after, until] interval
+func (store *SQLiteStore) usersEnding(ctx context.Context, column string, after, until int64) ([]*User, error) {
+	rows, err := store.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+column+` > ? AND `+column+` <= ?`, after, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(userFields(&user)...); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	return users, rows.Err()
+}
+
+// updateUserTx reads the user, applies fn and saves user's quota fields in a single transaction
+func (store *SQLiteStore) updateUserTx(ctx context.Context, chatId int64, fn func(user *User)) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	user, err := getUser(ctx, tx, chatId)
+	if err != nil {
+		return err
+	}
+	fn(user)
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET free_sentences = ?, bonus_sentences = ?, quota_reset_at = ?, last_used = ? WHERE chat_id = ?`,
+		user.FreeSentences, user.BonusSentences, user.QuotaResetAt, user.LastUsed, chatId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetUserSentenceLanguage updates user's language of generated sentences
+func (store *SQLiteStore) SetUserSentenceLanguage(ctx context.Context, chatId int64, sentenceLanguage string) error {
+	return store.exec(ctx, "UPDATE users SET sentence_language = ? WHERE chat_id = ?", sentenceLanguage, chatId)
+}
+
+// UpdateUserPremium updates user premiumUntil field to a new time stamp provided in unix time format
+func (store *SQLiteStore) UpdateUserPremium(ctx context.Context, chatId int64, premiumUntil int64) error {
+	return store.exec(ctx, "UPDATE users SET premium_until = ? WHERE chat_id = ?", premiumUntil, chatId)
+}
+
+// SetUserLevel sets language level (e.g. A1, B2) and marks user's preferences as set
+func (store *SQLiteStore) SetUserLevel(ctx context.Context, chatId int64, level string) error {
+	return store.exec(ctx, "UPDATE users SET level = ?, preferences_set = 1 WHERE chat_id = ?", level, chatId)
+}
+
+// SetUserTimezone sets user's timezone used for the daily reset of free sentences
+func (store *SQLiteStore) SetUserTimezone(ctx context.Context, chatId int64, timezone string) error {
+	return store.exec(ctx, "UPDATE users SET timezone = ? WHERE chat_id = ?", timezone, chatId)
+}
+
+// SetUserSubscription records user's recurring subscription paid until the unix time, the subscription is no longer canceled
+func (store *SQLiteStore) SetUserSubscription(ctx context.Context, chatId int64, chargeId string, until int64) error {
+	return store.exec(ctx, "UPDATE users SET subscription_charge_id = ?, subscription_until = ?, subscription_canceled = 0, paid = 1 WHERE chat_id = ?", chargeId, until, chatId)
+}
+
+// SetUserSubscriptionCanceled marks whether user's subscription will be renewed when its current period ends
+func (store *SQLiteStore) SetUserSubscriptionCanceled(ctx context.Context, chatId int64, canceled bool) error {
+	return store.exec(ctx, "UPDATE users SET subscription_canceled = ? WHERE chat_id = ?", canceled, chatId)
+}
+
+// SetUserBannedUntil bans user until the unix time, zero lifts the ban
+func (store *SQLiteStore) SetUserBannedUntil(ctx context.Context, chatId int64, until int64) error {
+	return store.exec(ctx, "UPDATE users SET banned_until = ? WHERE chat_id = ?", until, chatId)
+}
+
+// SetUserRemindedAt records that user was reminded that premium or trial ends in the reminders period ending at the unix time
+func (store *SQLiteStore) SetUserRemindedAt(ctx context.Context, chatId int64, at int64) error {
+	return store.exec(ctx, "UPDATE users SET reminded_at = ? WHERE chat_id = ?", at, chatId)
+}
+
+// exec executes query that updates a single user, returns ErrUserNotFound if no rows were affected
+func (store *SQLiteStore) exec(ctx context.Context, query string, args ...any) error {
+	res, err := store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// AddCard saves the card and sets its ID
+func (store *SQLiteStore) AddCard(ctx context.Context, card *Card) error {
+	res, err := store.db.ExecContext(ctx, `INSERT INTO cards
+		(chat_id, word, sentence, translation, language, level, model, audio_file_id, created_at, regenerated, due, interval, ease, repetitions, lapses)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		card.ChatId, card.Word, card.Sentence, card.Translation, card.Language, card.Level, card.Model, card.AudioFileID, card.CreatedAt, card.Regenerated,
+		card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	card.ID = strconv.FormatInt(id, 10)
+	return nil
+}
+
+// GetCards returns all user's cards from the oldest to the newest
+func (store *SQLiteStore) GetCards(ctx context.Context, chatId int64) ([]*Card, error) {
+	return store.queryCards(ctx, `SELECT `+cardColumns+` FROM cards WHERE chat_id = ? ORDER BY created_at, id`, chatId)
+}
+
+// ListCards returns up to limit user's cards from the newest to the oldest skipping the first offset cards
+func (store *SQLiteStore) ListCards(ctx context.Context, chatId int64, offset, limit int) ([]*Card, bool, error) {
+	//Request one more card to find out if there are more cards
+	cards, err := store.queryCards(ctx, `SELECT `+cardColumns+` FROM cards WHERE chat_id = ?
+		ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`, chatId, limit+1, offset)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(cards) > limit {
+		return cards[:limit], true, nil
+	}
+	return cards, false, nil
+}
+
+// DeleteCard deletes user's card
+func (store *SQLiteStore) DeleteCard(ctx context.Context, chatId int64, cardId string) error {
+	_, err := store.db.ExecContext(ctx, "DELETE FROM cards WHERE chat_id = ? AND id = ?", chatId, cardId)
+	return err
+}
+
+// GetCard returns user's card
+func (store *SQLiteStore) GetCard(ctx context.Context, chatId int64, cardId string) (*Card, error) {
+	cards, err := store.queryCards(ctx, `SELECT `+cardColumns+` FROM cards WHERE chat_id = ? AND id = ?`, chatId, cardId)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, ErrCardNotFound
+	}
+	return cards[0], nil
+}
+
+// DueCards returns up to limit user's cards that are due at the unix time now, the most overdue first
+func (store *SQLiteStore) DueCards(ctx context.Context, chatId int64, now int64, limit int) ([]*Card, error) {
+	return store.queryCards(ctx, `SELECT `+cardColumns+` FROM cards WHERE chat_id = ? AND due <= ?
+		ORDER BY due, id LIMIT ?`, chatId, now, limit)
+}
+
+// UpdateCard updates card overriding all fields with the provided card struct
+func (store *SQLiteStore) UpdateCard(ctx context.Context, card *Card) error {
+	return store.execCard(ctx, `UPDATE cards SET word = ?, sentence = ?, translation = ?, language = ?, level = ?, model = ?,
+		audio_file_id = ?, created_at = ?, regenerated = ?, due = ?, interval = ?, ease = ?, repetitions = ?, lapses = ?
+		WHERE chat_id = ? AND id = ?`,
+		card.Word, card.Sentence, card.Translation, card.Language, card.Level, card.Model, card.AudioFileID, card.CreatedAt, card.Regenerated,
+		card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses, card.ChatId, card.ID)
+}
+
+// UpdateCardSchedule saves spaced repetition schedule fields of the card
+func (store *SQLiteStore) UpdateCardSchedule(ctx context.Context, card *Card) error {
+	return store.execCard(ctx, `UPDATE cards SET due = ?, interval = ?, ease = ?, repetitions = ?, lapses = ?
+		WHERE chat_id = ? AND id = ?`, card.Due, card.Interval, card.Ease, card.Repetitions, card.Lapses, card.ChatId, card.ID)
+}
+
+// execCard executes query that updates a single card, returns ErrCardNotFound if no rows were affected
+func (store *SQLiteStore) execCard(ctx context.Context, query string, args ...any) error {
+	res, err := store.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCardNotFound
+	}
+	return nil
+}
+
+const cardColumns = "id, chat_id, word, sentence, translation, language, level, model, audio_file_id, created_at, regenerated, due, interval, ease, repetitions, lapses"
+
+// queryCards runs query selecting cardColumns and scans the result
+func (store *SQLiteStore) queryCards(ctx context.Context, query string, args ...any) ([]*Card, error) {
+	rows, err := store.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []*Card
+	for rows.Next() {
+		var card Card
+		var id int64
+		if err := rows.Scan(&id, &card.ChatId, &card.Word, &card.Sentence, &card.Translation, &card.Language, &card.Level, &card.Model, &card.AudioFileID, &card.CreatedAt, &card.Regenerated,
+			&card.Due, &card.Interval, &card.Ease, &card.Repetitions, &card.Lapses); err != nil {
+			return nil, err
+		}
+		card.ID = strconv.FormatInt(id, 10)
+		cards = append(cards, &card)
+	}
+	return cards, rows.Err()
+}
@@ -0,0 +1,16 @@
+package db
+
+import "errors"
+
+// ErrTrialUnavailable is returned by StartTrial when the user has already used the trial or has ever paid for premium
+var ErrTrialUnavailable = errors.New("premium trial unavailable")
+
+// startTrial gives the user premium trial until the unix time until. Trial is given once and only to users who never paid for premium,
+// premium from promo codes and referrals does not take it away
+func startTrial(user *User, until int64) error {
+	if user.TrialUntil != 0 || user.Paid || user.SubscriptionChargeID != "" {
+		return ErrTrialUnavailable
+	}
+	user.TrialUntil = until
+	return nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStartTrial(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		if err := store.StartTrial(ctx, 1, 100); err != nil {
+			t.Fatalf("error starting trial: %v", err)
+		}
+		if got := mustGetUser(t, store, 1).TrialUntil; got != 100 {
+			t.Fatalf("trial ends at %d, want 100", got)
+		}
+		if err := store.StartTrial(ctx, 1, 200); !errors.Is(err, ErrTrialUnavailable) {
+			t.Fatalf("starting trial twice returned %v", err)
+		}
+		if err := store.StartTrial(ctx, 5, 100); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("starting trial of unknown user returned %v", err)
+		}
+	})
+}
+
+func TestStartTrialAfterPremium(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		//Premium from promo codes and referrals does not take the trial away
+		if err := store.CreateUser(ctx, &User{ChatId: 2, PremiumUntil: 50}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		if err := store.StartTrial(ctx, 2, 100); err != nil {
+			t.Fatalf("user with free premium could not start trial: %v", err)
+		}
+
+		if err := store.CreatePromoCode(ctx, &PromoCode{Code: "FREE", Days: 7}); err != nil {
+			t.Fatalf("error creating promo code: %v", err)
+		}
+		if err := store.CreateUser(ctx, &User{ChatId: 4}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		if _, err := store.RedeemPromoCode(ctx, "FREE", 4, 100); err != nil {
+			t.Fatalf("error redeeming promo code: %v", err)
+		}
+		if err := store.StartTrial(ctx, 4, 200); err != nil {
+			t.Fatalf("user who redeemed a promo code could not start trial: %v", err)
+		}
+
+		if err := store.ApplyPayment(ctx, &Payment{ChargeID: "a", ChatId: 1, CreatedAt: 5, Duration: 10}); err != nil {
+			t.Fatalf("error applying payment: %v", err)
+		}
+		if err := store.StartTrial(ctx, 1, 100); !errors.Is(err, ErrTrialUnavailable) {
+			t.Fatalf("starting trial after payment returned %v", err)
+		}
+
+		if err := store.CreateUser(ctx, &User{ChatId: 3}); err != nil {
+			t.Fatalf("error creating user: %v", err)
+		}
+		if err := store.SetUserSubscription(ctx, 3, "b", 100); err != nil {
+			t.Fatalf("error setting subscription: %v", err)
+		}
+		if err := store.StartTrial(ctx, 3, 100); !errors.Is(err, ErrTrialUnavailable) {
+			t.Fatalf("starting trial after subscribing returned %v", err)
+		}
+	})
+}
@@ -2,39 +2,47 @@ package gemini
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"strings"
 
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
-const (
-	requestStringEn = `
-Generate a simple %s-level sentence in %s using the word %s.  
-- The sentence should make it easy to understand the word from context.  
-- If the word doesn't exist or if it is from another language, return only "Error"
-- Otherwise, return the sentence and its English translation, separated by ";".  
-- Do not include any explanations or extra text."`
-	requestStringRu = `
-Generate a simple %s-level sentence in %s using the word %s.  
-- The sentence should make it easy to understand the word from context.  
-- If the word doesn't exist or if it is from another language, return only "Error"
-- Otherwise, return the sentence and its Russian translation, separated by ";".  
-- Do not include any explanations or extra text."`
-)
+// DefaultModel is the gemini model used when no other model is configured
+const DefaultModel = "gemini-2.5-pro-preview-03-25"
+
+// responseSchema makes gemini respond with JSON matching generator.Sentences
+var responseSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		generator.FieldSentence:       {Type: genai.TypeString, Description: "Generated sentence"},
+		generator.FieldTranslation:    {Type: genai.TypeString, Description: "Translation of the sentence"},
+		generator.FieldTargetWordForm: {Type: genai.TypeString, Description: "Form of the word used in the sentence"},
+		generator.FieldErrorReason:    {Type: genai.TypeString, Description: "Why the sentence could not be generated, empty on success"},
+	},
+	Required: []string{generator.FieldSentence, generator.FieldTranslation, generator.FieldTargetWordForm, generator.FieldErrorReason},
+}
+
+// Config contains gemini API settings
+type Config struct {
+	APIKey string `yaml:"api_key"`
+	Model  string `yaml:"model"`
+}
 
 type Client struct {
 	client *genai.Client
+	model  string
 }
 
-// New creates new gemini client
-func New(ctx context.Context, token string) (*Client, error) {
-	client, err := genai.NewClient(ctx, option.WithAPIKey(token))
+// New creates new gemini client that sends requests to the model specified in the config
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(cfg.APIKey))
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: client}, nil
+	return &Client{client: client, model: cfg.Model}, nil
 }
 
 // Close closes the client
@@ -45,34 +53,47 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// Request sends a text-only request to the gemini model specified in geminiVersion parameter
-func (c *Client) Request(ctx context.Context, request string, geminiVersion string) (string, error) {
-	//Specify model
-	model := c.client.GenerativeModel(geminiVersion)
+// Generate sends a text-only request to the gemini model and decodes its JSON response
+func (c *Client) Generate(ctx context.Context, request string) (*generator.Sentences, error) {
+	//Specify model and make it respond in JSON
+	model := c.client.GenerativeModel(c.model)
+	model.ResponseMIMEType = "application/json"
+	model.ResponseSchema = responseSchema
 
 	//Generate content
 	resp, err := model.GenerateContent(ctx, genai.Text(request))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	//Extract response
+	text, err := responseText(resp)
+	if err != nil {
+		return nil, err
+	}
+	sentences, err := generator.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sentences.Model = c.model
+	return sentences, nil
+}
+
+// responseText joins text parts of the response candidates, other parts are skipped
+func responseText(resp *genai.GenerateContentResponse) (string, error) {
 	var response strings.Builder
 	for _, cand := range resp.Candidates {
-		if cand.Content != nil {
-			for _, part := range cand.Content.Parts {
-				response.WriteString(string(part.(genai.Text)))
+		if cand.Content == nil {
+			continue
+		}
+		for _, part := range cand.Content.Parts {
+			if t, ok := part.(genai.Text); ok {
+				response.WriteString(string(t))
 			}
 		}
 	}
-
-	return response.String(), nil
-}
-
-// FormatRequestString formats request string based on the language
-func FormatRequestString(level, sentenceLanguage, word, language string) string {
-	if language == "ru" {
-		return fmt.Sprintf(requestStringRu, level, sentenceLanguage, word)
+	if response.Len() == 0 {
+		return "", errors.New("gemini response contains no text")
 	}
-	return fmt.Sprintf(requestStringEn, level, sentenceLanguage, word)
+	return response.String(), nil
 }
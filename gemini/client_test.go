@@ -0,0 +1,26 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestResponseText(t *testing.T) {
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{
+		{Content: &genai.Content{Parts: []genai.Part{genai.Text(`{"sentence":`), genai.Blob{MIMEType: "image/png"}, genai.Text(`"Hola"}`)}}},
+		{},
+	}}
+	if got, err := responseText(resp); err != nil || got != `{"sentence":"Hola"}` {
+		t.Fatalf("responseText = %q, %v", got, err)
+	}
+
+	for _, resp := range []*genai.GenerateContentResponse{
+		{},
+		{Candidates: []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.FunctionCall{Name: "f"}}}}}},
+	} {
+		if _, err := responseText(resp); err == nil {
+			t.Errorf("responseText(%+v) returned no error", resp)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	requestStringEn = `
+Generate a simple %s-level sentence in %s using the word %s.  
+- The sentence should make it easy to understand the word from context.  
+- Put the sentence into "sentence", its English translation into "translation" and the form of the word used in the sentence into "target_word_form".  
+- If the word doesn't exist or if it is from another language, leave the other fields empty and briefly explain why in "error_reason".  
+- Do not include any explanations or extra text.`
+	requestStringRu = `
+Generate a simple %s-level sentence in %s using the word %s.  
+- The sentence should make it easy to understand the word from context.  
+- Put the sentence into "sentence", its Russian translation into "translation" and the form of the word used in the sentence into "target_word_form".  
+- If the word doesn't exist or if it is from another language, leave the other fields empty and briefly explain why in "error_reason".  
+- Do not include any explanations or extra text.`
+)
+
+// Field names of the structured model response
+const (
+	FieldSentence       = "sentence"
+	FieldTranslation    = "translation"
+	FieldTargetWordForm = "target_word_form"
+	FieldErrorReason    = "error_reason"
+)
+
+// JSONSchema is JSON schema of the structured model response
+var JSONSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		FieldSentence:       map[string]any{"type": "string", "description": "Generated sentence"},
+		FieldTranslation:    map[string]any{"type": "string", "description": "Translation of the sentence"},
+		FieldTargetWordForm: map[string]any{"type": "string", "description": "Form of the word used in the sentence"},
+		FieldErrorReason:    map[string]any{"type": "string", "description": "Why the sentence could not be generated, empty on success"},
+	},
+	"required":             []string{FieldSentence, FieldTranslation, FieldTargetWordForm, FieldErrorReason},
+	"additionalProperties": false,
+}
+
+// ErrInvalidResponse is returned when the model response does not match the schema
+var ErrInvalidResponse = errors.New("invalid model response")
+
+// Sentences is the structured response of the model
+type Sentences struct {
+	Sentence       string `json:"sentence"`         //Sentence in the language user is learning
+	Translation    string `json:"translation"`      //Translation of the sentence to user's language
+	TargetWordForm string `json:"target_word_form"` //Form of the requested word used in the sentence
+	ErrorReason    string `json:"error_reason"`     //Set when the model refused to generate a sentence
+	Model          string `json:"-"`                //Name of the model that generated the response
+}
+
+// SentenceGenerator is implemented by every language model backend that can generate sentences
+type SentenceGenerator interface {
+	// Generate sends the prompt to the model and returns its structured response
+	Generate(ctx context.Context, prompt string) (*Sentences, error)
+	// Close releases resources held by the generator
+	Close() error
+}
+
+// FormatRequestString formats request string based on the language
+func FormatRequestString(level, sentenceLanguage, word, language string) string {
+	if language == "ru" {
+		return fmt.Sprintf(requestStringRu, level, sentenceLanguage, word)
+	}
+	return fmt.Sprintf(requestStringEn, level, sentenceLanguage, word)
+}
+
+// ExcludeSentences adds sentences the model must not repeat to the request string
+func ExcludeSentences(request string, sentences ...string) string {
+	for _, sentence := range sentences {
+		request += fmt.Sprintf("\n- The sentence must be different from: %s", sentence)
+	}
+	return request
+}
+
+// Parse decodes structured model response, returns error if the response is not valid
+func Parse(resp string) (*Sentences, error) {
+	var sentences Sentences
+	if err := json.Unmarshal([]byte(resp), &sentences); err != nil {
+		return nil, fmt.Errorf("%w: %v. Model response: %s", ErrInvalidResponse, err, resp)
+	}
+
+	//Refusal is a valid response
+	if sentences.ErrorReason != "" {
+		return &sentences, nil
+	}
+
+	if strings.TrimSpace(sentences.Sentence) == "" || strings.TrimSpace(sentences.Translation) == "" {
+		return nil, fmt.Errorf("%w: missing sentence or translation. Model response: %s", ErrInvalidResponse, resp)
+	}
+	return &sentences, nil
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse(`{"sentence":"Hola, amigo.","translation":"Hello, friend.","target_word_form":"amigo","error_reason":""}`)
+	if err != nil {
+		t.Fatalf("error parsing response: %v", err)
+	}
+	if s.Sentence != "Hola, amigo." || s.Translation != "Hello, friend." || s.TargetWordForm != "amigo" {
+		t.Fatalf("Parse = %+v", s)
+	}
+
+	//Refusal has no sentence but is valid
+	s, err = Parse(`{"sentence":"","translation":"","target_word_form":"","error_reason":"not a Spanish word"}`)
+	if err != nil || s.ErrorReason != "not a Spanish word" {
+		t.Fatalf("Parse of refusal = %+v, %v", s, err)
+	}
+
+	for _, resp := range []string{
+		"Hola, amigo.;Hello, friend.",
+		`{"sentence":" ","translation":"Hello, friend."}`,
+		`{"sentence":"Hola, amigo."}`,
+	} {
+		if _, err := Parse(resp); !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("Parse(%q) returned %v", resp, err)
+		}
+	}
+}
+
+func TestFormatRequestString(t *testing.T) {
+	if got := FormatRequestString("A1", "es-ES", "amigo", "ru"); !strings.Contains(got, "Russian translation") || !strings.Contains(got, "amigo") {
+		t.Errorf("russian request = %q", got)
+	}
+	if got := FormatRequestString("B2", "es-ES", "amigo", "en"); !strings.Contains(got, "English translation") || !strings.Contains(got, "B2") {
+		t.Errorf("english request = %q", got)
+	}
+}
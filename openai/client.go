@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/generator"
+)
+
+// DefaultBaseURL points to the OpenAI-compatible API of a locally running Ollama server
+const DefaultBaseURL = "http://localhost:11434/v1"
+
+// Config contains settings of the OpenAI-compatible API
+type Config struct {
+	BaseURL string `yaml:"base_url"` //e.g. http://localhost:11434/v1
+	APIKey  string `yaml:"api_key"`  //May be empty for servers that do not require authorization
+	Model   string `yaml:"model"`
+}
+
+// Client talks to any server implementing OpenAI chat completions API (OpenAI, Ollama, llama.cpp, vLLM, etc.)
+type Client struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type jsonSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
+}
+
+type responseFormat struct {
+	Type       string     `json:"type"`
+	JSONSchema jsonSchema `json:"json_schema"`
+}
+
+type chatRequest struct {
+	Model          string         `json:"model"`
+	Messages       []message      `json:"messages"`
+	ResponseFormat responseFormat `json:"response_format"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message message `json:"message"`
+	} `json:"choices"`
+}
+
+// New creates new client for the chat completions endpoint under the configured base URL
+func New(cfg Config) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Close does nothing because the client does not hold any resources
+func (c *Client) Close() error {
+	return nil
+}
+
+// Generate sends the request as a single user message and decodes the model's JSON reply
+func (c *Client) Generate(ctx context.Context, request string) (*generator.Sentences, error) {
+	//Encode request body asking for a reply matching the schema
+	body, err := json.Marshal(chatRequest{
+		Model:    c.model,
+		Messages: []message{{Role: "user", Content: request}},
+		ResponseFormat: responseFormat{
+			Type:       "json_schema",
+			JSONSchema: jsonSchema{Name: "sentences", Schema: generator.JSONSchema, Strict: true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//Create new request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	//Set headers
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	//Make a request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("chat completions request failed with status %d: %s", resp.StatusCode, msg)
+	}
+
+	//Decode the response
+	var res chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if len(res.Choices) == 0 {
+		return nil, errors.New("chat completions response contains no choices")
+	}
+	sentences, err := generator.Parse(res.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	sentences.Model = c.model
+	return sentences, nil
+}
@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newServer starts a stand-in chat completions server that checks the request and replies with the content
+func newServer(t *testing.T, content string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request to %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("authorization header = %q", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("error decoding request: %v", err)
+		}
+		if req.Model != "llama" || len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "prompt" {
+			t.Errorf("request = %+v", req)
+		}
+		if req.ResponseFormat.Type != "json_schema" || req.ResponseFormat.JSONSchema.Schema == nil {
+			t.Errorf("response format = %+v", req.ResponseFormat)
+		}
+		var res chatResponse
+		res.Choices = append(res.Choices, struct {
+			Message message `json:"message"`
+		}{Message: message{Role: "assistant", Content: content}})
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGenerate(t *testing.T) {
+	srv := newServer(t, `{"sentence":"Hola, amigo.","translation":"Hello, friend.","target_word_form":"amigo","error_reason":""}`)
+	c := New(Config{BaseURL: srv.URL + "/v1/", APIKey: "key", Model: "llama"})
+	got, err := c.Generate(context.Background(), "prompt")
+	if err != nil {
+		t.Fatalf("error generating: %v", err)
+	}
+	if got.Sentence != "Hola, amigo." || got.Translation != "Hello, friend." || got.TargetWordForm != "amigo" {
+		t.Fatalf("Generate = %+v", got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "status", handler: func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "model not found", http.StatusNotFound)
+		}},
+		{name: "no choices", handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"choices":[]}`))
+		}},
+		{name: "invalid json", handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`not json`))
+		}},
+		{name: "content not matching the schema", handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hola, amigo.;Hello, friend."}}]}`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			if _, err := New(Config{BaseURL: srv.URL, Model: "llama"}).Generate(context.Background(), "prompt"); err == nil {
+				t.Fatal("Generate returned no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package quota calculates daily free sentences of the users
+package quota
+
+import (
+	"time"
+	//Embed timezone database so user's timezones can be loaded in containers without it
+	_ "time/tzdata"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+)
+
+// Policy describes how many free sentences users get and when they get them
+type Policy struct {
+	DailyAllowance int `yaml:"daily_allowance"` //Free sentences user gets every day
+	ResetHour      int `yaml:"reset_hour"`      //Hour (0-23) of user's local time when free sentences are reset
+}
+
+// NextReset returns the first reset after now in the location
+func (p Policy) NextReset(now time.Time, loc *time.Location) time.Time {
+	now = now.In(loc)
+	reset := time.Date(now.Year(), now.Month(), now.Day(), p.ResetHour, 0, 0, 0, loc)
+	if !reset.After(now) {
+		reset = time.Date(now.Year(), now.Month(), now.Day()+1, p.ResetHour, 0, 0, 0, loc)
+	}
+	return reset
+}
+
+// Reset returns the reset that is applied to the user's free sentences when the sentence is reserved at now
+func (p Policy) Reset(now time.Time, loc *time.Location) db.QuotaReset {
+	return db.QuotaReset{Now: now.Unix(), Allowance: p.DailyAllowance, NextReset: p.NextReset(now, loc).Unix()}
+}
+
+// Remaining returns how many free sentences user has at now and when they are reset next
+func (p Policy) Remaining(user *db.User, now time.Time, loc *time.Location) (int, time.Time) {
+	//Reset time has passed but the user has not generated anything since, so the reset is not stored yet
+	if user.QuotaResetAt <= now.Unix() {
+		return p.DailyAllowance, p.NextReset(now, loc)
+	}
+	return max(user.FreeSentences, 0), time.Unix(user.QuotaResetAt, 0)
+}
+
+// Location returns user's timezone. If timezone is not set or invalid, it is inferred from the language code
+func Location(timezone, languageCode string) *time.Location {
+	if timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(InferTimezone(languageCode))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// InferTimezone guesses user's timezone from their telegram language code
+func InferTimezone(languageCode string) string {
+	switch languageCode {
+	case "ru", "tt":
+		return "Europe/Moscow"
+	case "uk":
+		return "Europe/Kyiv"
+	case "kk":
+		return "Asia/Almaty"
+	case "ka":
+		return "Asia/Tbilisi"
+	case "de":
+		return "Europe/Berlin"
+	case "es":
+		return "Europe/Madrid"
+	case "fr":
+		return "Europe/Paris"
+	case "it":
+		return "Europe/Rome"
+	case "tr":
+		return "Europe/Istanbul"
+	case "ja":
+		return "Asia/Tokyo"
+	case "ko":
+		return "Asia/Seoul"
+	default:
+		return "UTC"
+	}
+}
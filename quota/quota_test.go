@@ -0,0 +1,64 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dafraer/sentence-gen-tg-bot/db"
+)
+
+func TestNextReset(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Policy{DailyAllowance: 5, ResetHour: 4}
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{now: time.Date(2025, 1, 1, 3, 59, 0, 0, moscow), want: time.Date(2025, 1, 1, 4, 0, 0, 0, moscow)},
+		{now: time.Date(2025, 1, 1, 4, 0, 0, 0, moscow), want: time.Date(2025, 1, 2, 4, 0, 0, 0, moscow)},
+		{now: time.Date(2025, 1, 1, 23, 0, 0, 0, moscow), want: time.Date(2025, 1, 2, 4, 0, 0, 0, moscow)},
+		//Time of another timezone is converted to the user's one
+		{now: time.Date(2025, 1, 1, 0, 30, 0, 0, time.UTC), want: time.Date(2025, 1, 1, 4, 0, 0, 0, moscow)},
+	}
+	for _, tt := range tests {
+		if got := p.NextReset(tt.now, moscow); !got.Equal(tt.want) {
+			t.Errorf("NextReset(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	p := Policy{DailyAllowance: 5}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tomorrow := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	left, reset := p.Remaining(&db.User{FreeSentences: 2, QuotaResetAt: tomorrow.Unix()}, now, time.UTC)
+	if left != 2 || !reset.Equal(tomorrow) {
+		t.Errorf("Remaining before reset = %d, %v", left, reset)
+	}
+
+	//The reset is not stored until the user generates a sentence
+	left, reset = p.Remaining(&db.User{FreeSentences: 0, QuotaResetAt: now.Add(-time.Hour).Unix()}, now, time.UTC)
+	if left != 5 || !reset.Equal(tomorrow) {
+		t.Errorf("Remaining after reset = %d, %v", left, reset)
+	}
+}
+
+func TestLocation(t *testing.T) {
+	tests := []struct {
+		timezone, languageCode, want string
+	}{
+		{timezone: "Asia/Tokyo", languageCode: "ru", want: "Asia/Tokyo"},
+		{timezone: "", languageCode: "ru", want: "Europe/Moscow"},
+		{timezone: "Not/AZone", languageCode: "de", want: "Europe/Berlin"},
+		{timezone: "", languageCode: "xx", want: "UTC"},
+	}
+	for _, tt := range tests {
+		if got := Location(tt.timezone, tt.languageCode).String(); got != tt.want {
+			t.Errorf("Location(%q, %q) = %s, want %s", tt.timezone, tt.languageCode, got, tt.want)
+		}
+	}
+}
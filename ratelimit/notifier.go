@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Notifier lets a notice be sent to a chat at most once a minute, so users flooding the bot don't get a reply to every update.
+// It is safe for concurrent use
+type Notifier struct {
+	mu       sync.Mutex
+	notified map[int64]time.Time //When the chats were notified last time
+	pruned   time.Time           //When chats notified more than a window ago were removed last time
+}
+
+// NewNotifier creates notifier that has not notified anyone yet
+func NewNotifier() *Notifier {
+	return &Notifier{notified: make(map[int64]time.Time)}
+}
+
+// Allow returns true and records the notice if the chat has not been notified in the minute before now
+func (n *Notifier) Allow(chatId int64, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	//Forget chats that can be notified again anyway
+	if now.Sub(n.pruned) >= window {
+		n.pruned = now
+		for id, t := range n.notified {
+			if now.Sub(t) >= window {
+				delete(n.notified, id)
+			}
+		}
+	}
+
+	if t, ok := n.notified[chatId]; ok && now.Sub(t) < window {
+		return false
+	}
+	n.notified[chatId] = now
+	return true
+}
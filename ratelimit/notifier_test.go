@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	n := NewNotifier()
+	now := time.Now()
+	if !n.Allow(1, now) {
+		t.Fatal("first notice was not allowed")
+	}
+	if n.Allow(1, now.Add(59*time.Second)) {
+		t.Fatal("second notice within a minute was allowed")
+	}
+	if !n.Allow(2, now) {
+		t.Fatal("notice to another chat was not allowed")
+	}
+	if !n.Allow(1, now.Add(window)) {
+		t.Fatal("notice after a minute was not allowed")
+	}
+}
+
+func TestNotifierPrune(t *testing.T) {
+	n := NewNotifier()
+	now := time.Now()
+	n.Allow(1, now)
+	n.Allow(2, now.Add(window))
+	if _, ok := n.notified[1]; ok {
+		t.Error("chat notified a minute ago was not pruned")
+	}
+	if _, ok := n.notified[2]; !ok {
+		t.Error("chat notified just now was pruned")
+	}
+}
@@ -0,0 +1,118 @@
+// Package ratelimit limits how often users can send updates to the bot using a token bucket per chat
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// window is the period the rates are measured in, throttled users are notified at most once per window
+const window = time.Minute
+
+// Tier is the rate limit of a group of users
+type Tier struct {
+	PerMinute int `yaml:"per_minute"` //Updates user can send per minute on average
+	Burst     int `yaml:"burst"`      //Updates user can send at once after being idle
+}
+
+// Config describes rate limits of free and premium users and when throttled users are banned
+type Config struct {
+	Free       Tier `yaml:"free"`
+	Premium    Tier `yaml:"premium"`
+	BanAfter   int  `yaml:"ban_after"`   //Throttled updates within a minute after which user is banned, zero disables bans
+	BanMinutes int  `yaml:"ban_minutes"` //How long users are banned for
+}
+
+// Enabled returns false if rate limiting is disabled, i.e. limits of both tiers are zero
+func (c Config) Enabled() bool {
+	return c.Free.PerMinute > 0 || c.Premium.PerMinute > 0
+}
+
+// Decision tells what to do with the update
+type Decision struct {
+	Allowed bool //Update can be processed
+	Notify  bool //Update is throttled and user has not been told about it in the current window
+	Ban     bool //User keeps sending updates while being throttled and should be banned
+}
+
+// bucket is the token bucket of a single chat
+type bucket struct {
+	tokens    float64
+	updated   time.Time //When tokens were refilled last time
+	full      time.Time //When the bucket is refilled completely if no tokens are taken
+	notified  time.Time //Start of the window user was last told they are throttled in
+	throttled int       //Updates throttled since notified
+}
+
+// Limiter keeps token buckets of the chats. It is safe for concurrent use
+type Limiter struct {
+	cfg Config
+
+	mu      sync.Mutex
+	buckets map[int64]*bucket
+	pruned  time.Time //When full buckets were removed last time
+}
+
+// New creates limiter with the config
+func New(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg, buckets: make(map[int64]*bucket)}
+}
+
+// Allow takes a token from the chat's bucket at now. Premium users get limits of the premium tier.
+// A tier with zero per minute is not limited
+func (l *Limiter) Allow(chatId int64, premium bool, now time.Time) Decision {
+	tier := l.cfg.Free
+	if premium {
+		tier = l.cfg.Premium
+	}
+	if tier.PerMinute <= 0 {
+		return Decision{Allowed: true}
+	}
+	burst := float64(max(tier.Burst, 1))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	//New chats start with a full bucket
+	b, ok := l.buckets[chatId]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[chatId] = b
+	}
+
+	//Refill tokens for the time passed since the last update, bucket is capped by the burst of the current tier
+	elapsed := now.Sub(b.updated)
+	b.tokens = min(b.tokens+elapsed.Minutes()*float64(tier.PerMinute), burst)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.full = now.Add(time.Duration((burst - b.tokens) / float64(tier.PerMinute) * float64(time.Minute)))
+		return Decision{Allowed: true}
+	}
+
+	//Notify user once per window and count throttled updates to catch the ones who keep flooding
+	var d Decision
+	if now.Sub(b.notified) >= window {
+		b.notified = now
+		b.throttled = 0
+		d.Notify = true
+	}
+	b.throttled++
+	d.Ban = l.cfg.BanAfter > 0 && b.throttled > l.cfg.BanAfter
+	return d
+}
+
+// prune removes buckets that are full and whose chats are not being throttled, at most once per window.
+// Such chats get the same full bucket when they send an update next time
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < window {
+		return
+	}
+	l.pruned = now
+	for chatId, b := range l.buckets {
+		if !now.Before(b.full) && now.Sub(b.notified) >= window {
+			delete(l.buckets, chatId)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	Free:       Tier{PerMinute: 6, Burst: 2},
+	Premium:    Tier{PerMinute: 60, Burst: 10},
+	BanAfter:   3,
+	BanMinutes: 30,
+}
+
+func TestAllowBurstAndRefill(t *testing.T) {
+	l := New(testConfig)
+	now := time.Now()
+	for i := range 2 {
+		if d := l.Allow(1, false, now); !d.Allowed {
+			t.Fatalf("update %d of the burst was throttled", i)
+		}
+	}
+	if d := l.Allow(1, false, now); d.Allowed || !d.Notify {
+		t.Fatalf("update after the burst = %+v, want throttled with notice", d)
+	}
+
+	//Six updates per minute refill a token every ten seconds
+	if d := l.Allow(1, false, now.Add(5*time.Second)); d.Allowed {
+		t.Fatal("update was allowed before a token was refilled")
+	}
+	if d := l.Allow(1, false, now.Add(11*time.Second)); !d.Allowed {
+		t.Fatal("update was throttled after a token was refilled")
+	}
+
+	//Other chats have their own buckets
+	if d := l.Allow(2, false, now); !d.Allowed {
+		t.Fatal("update of another chat was throttled")
+	}
+}
+
+func TestAllowNotifiesOncePerWindow(t *testing.T) {
+	l := New(Config{Free: Tier{PerMinute: 1, Burst: 1}})
+	now := time.Now()
+	l.Allow(1, false, now)
+	if d := l.Allow(1, false, now); !d.Notify {
+		t.Fatal("first throttled update did not notify")
+	}
+	if d := l.Allow(1, false, now.Add(30*time.Second)); d.Notify {
+		t.Fatal("throttled update notified twice in a minute")
+	}
+	if d := l.Allow(1, false, now.Add(window+time.Second)); !d.Allowed {
+		t.Fatal("update was throttled after a minute")
+	}
+	if d := l.Allow(1, false, now.Add(window+2*time.Second)); !d.Notify {
+		t.Fatal("throttled update did not notify in the next minute")
+	}
+}
+
+func TestAllowBan(t *testing.T) {
+	l := New(testConfig)
+	now := time.Now()
+	var bans int
+	for range 2 + testConfig.BanAfter + 1 {
+		if l.Allow(1, false, now).Ban {
+			bans++
+		}
+	}
+	if bans != 1 {
+		t.Fatalf("flooding user got %d bans, want 1", bans)
+	}
+
+	//Bans are disabled with zero BanAfter
+	l = New(Config{Free: testConfig.Free})
+	for range 10 {
+		if l.Allow(1, false, now).Ban {
+			t.Fatal("user was banned with bans disabled")
+		}
+	}
+}
+
+func TestAllowPremium(t *testing.T) {
+	l := New(testConfig)
+	now := time.Now()
+	for i := range testConfig.Premium.Burst {
+		if d := l.Allow(1, true, now); !d.Allowed {
+			t.Fatalf("update %d of the premium burst was throttled", i)
+		}
+	}
+
+	//Unlimited tier
+	l = New(Config{Free: testConfig.Free})
+	for range 100 {
+		if d := l.Allow(1, true, now); !d.Allowed {
+			t.Fatal("premium user was throttled with unlimited premium tier")
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := New(testConfig)
+	now := time.Now()
+	l.Allow(1, false, now)
+	l.Allow(2, false, now)
+	l.Allow(2, false, now)
+	l.Allow(2, false, now.Add(5*time.Second))
+
+	//Chat 1 has a full bucket after a minute, chat 2 was throttled in the last minute
+	l.Allow(3, false, now.Add(window-time.Second))
+	l.Allow(3, false, now.Add(window))
+	if _, ok := l.buckets[1]; ok {
+		t.Error("full bucket was not pruned")
+	}
+	if _, ok := l.buckets[2]; !ok {
+		t.Error("bucket of throttled chat was pruned")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if (Config{}).Enabled() {
+		t.Error("zero config is enabled")
+	}
+	if !(Config{Premium: Tier{PerMinute: 1}}).Enabled() {
+		t.Error("config with premium limit is disabled")
+	}
+}
@@ -0,0 +1,76 @@
+// Package srs schedules card reviews using SM-2 spaced repetition algorithm
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+// Grade is user's answer to the review
+type Grade int
+
+const (
+	Again Grade = iota //User did not remember the card
+	Hard               //User remembered the card with serious difficulty
+	Good               //User remembered the card after a hesitation
+	Easy               //User remembered the card easily
+)
+
+const (
+	// DefaultEase is the ease factor of a card that was never reviewed
+	DefaultEase = 2.5
+	// MinEase is the lowest ease factor a card can have
+	MinEase = 1.3
+	// relearnDelay is the delay before a forgotten card is shown again
+	relearnDelay = 10 * time.Minute
+	day          = 24 * time.Hour
+)
+
+// Schedule is the review state of a card
+type Schedule struct {
+	Due         time.Time //When the card should be reviewed next
+	Interval    int       //Days between the last review and the due date, zero while the card is being learned
+	Ease        float64   //SM-2 ease factor, zero means DefaultEase
+	Repetitions int       //Successful reviews in a row
+	Lapses      int       //How many times the card was forgotten
+}
+
+// Review returns the schedule after the card was reviewed at now with the grade
+func (s Schedule) Review(grade Grade, now time.Time) Schedule {
+	if s.Ease == 0 {
+		s.Ease = DefaultEase
+	}
+
+	//SM-2 expects answer quality from 0 to 5, grades are mapped to 1, 3, 4 and 5
+	quality := map[Grade]float64{Again: 1, Hard: 3, Good: 4, Easy: 5}[grade]
+	s.Ease = math.Max(MinEase, s.Ease+0.1-(5-quality)*(0.08+(5-quality)*0.02))
+
+	//Forgotten card is learned from the beginning
+	if grade == Again {
+		s.Repetitions = 0
+		s.Interval = 0
+		s.Lapses++
+		s.Due = now.Add(relearnDelay)
+		return s
+	}
+
+	s.Repetitions++
+	switch s.Repetitions {
+	case 1:
+		s.Interval = 1
+	case 2:
+		s.Interval = 6
+	default:
+		s.Interval = int(math.Round(float64(s.Interval) * s.Ease))
+	}
+
+	//Hard and easy answers shorten and lengthen the interval
+	switch grade {
+	case Hard:
+		s.Interval = max(1, int(math.Round(float64(s.Interval)*0.8)))
+	case Easy:
+		s.Interval = int(math.Round(float64(s.Interval) * 1.3))
+	}
+	s.Due = now.Add(time.Duration(s.Interval) * day)
+	return s
+}
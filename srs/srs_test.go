@@ -0,0 +1,64 @@
+package srs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReviewIntervals(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		grades   []Grade
+		interval int
+		ease     float64
+	}{
+		{name: "first good", grades: []Grade{Good}, interval: 1, ease: 2.5},
+		{name: "second good", grades: []Grade{Good, Good}, interval: 6, ease: 2.5},
+		{name: "third good", grades: []Grade{Good, Good, Good}, interval: 15, ease: 2.5},
+		{name: "easy", grades: []Grade{Easy}, interval: 1, ease: 2.6},
+		{name: "easy lengthens", grades: []Grade{Good, Easy}, interval: 8, ease: 2.6},
+		{name: "hard shortens", grades: []Grade{Good, Hard}, interval: 5, ease: 2.36},
+		{name: "hard is at least a day", grades: []Grade{Hard}, interval: 1, ease: 2.36},
+		{name: "relearned", grades: []Grade{Good, Good, Again, Good}, interval: 1, ease: 1.96},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Schedule
+			for _, g := range tt.grades {
+				s = s.Review(g, now)
+			}
+			if s.Interval != tt.interval {
+				t.Errorf("interval = %d, want %d", s.Interval, tt.interval)
+			}
+			if math.Abs(s.Ease-tt.ease) > 1e-9 {
+				t.Errorf("ease = %v, want %v", s.Ease, tt.ease)
+			}
+			if want := now.Add(time.Duration(tt.interval) * day); !s.Due.Equal(want) {
+				t.Errorf("due = %v, want %v", s.Due, want)
+			}
+		})
+	}
+}
+
+func TestReviewAgain(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := Schedule{Interval: 15, Ease: 2.5, Repetitions: 3}.Review(Again, now)
+	if s.Interval != 0 || s.Repetitions != 0 || s.Lapses != 1 {
+		t.Fatalf("forgotten card has %+v", s)
+	}
+	if want := now.Add(relearnDelay); !s.Due.Equal(want) {
+		t.Fatalf("forgotten card is due %v, want %v", s.Due, want)
+	}
+}
+
+func TestMinEase(t *testing.T) {
+	var s Schedule
+	for range 10 {
+		s = s.Review(Again, time.Now())
+	}
+	if s.Ease != MinEase {
+		t.Fatalf("ease = %v, want %v", s.Ease, MinEase)
+	}
+}
@@ -3,27 +3,102 @@ package text
 import (
 	"fmt"
 	"github.com/go-telegram/bot/models"
+	"strings"
+	"time"
 )
 
 type Messages struct {
-	Start              map[string]string                       //Sent on /start command
-	Help               map[string]string                       //Sent on /help command
-	Lang               map[string]string                       //Sent when prompting user to choose the language
-	Level              map[string]string                       //Sent when prompting user to choose language level (e.g. A1)
-	PreferencesSet     map[string]string                       //Sent after user finishes set up
-	UnknownCommand     map[string]string                       //Sent when receiving unknown command
-	ResponseMsg        map[string]string                       //Sent when sending generated sentences to the user
-	TooLong            map[string]string                       //Sent when message exceeds maxMessageLen set in bot.go
-	BadRequest         map[string]string                       //Sent when unable to make sentences due to word being inappropriate or not existing
-	Premium            map[string]string                       //Sent when user uses /premium command if they don't have premium yet
-	LimitReached       map[string]string                       //Sent when user reaches free limit of 50 sentences per day
-	PremiumTitle       map[string]string                       //Title of the message with the invoice and text of premium inline
-	SuccessfulPayment  map[string]string                       //Sent when payment is successful
-	FailedPayment      map[string]string                       //Sent when payment has failed
-	PreferencesNotSet  map[string]string                       //Sent when user tries to generate sentences without setting the preferences
-	AlreadyPremium     map[string]func(int) string             //Sent when premium user tries to buy premium value is a function because of conjugation
-	PremiumDescription map[string]string                       //Sent in the description of the invoice
-	LanguageMarkup     map[string]*models.InlineKeyboardMarkup //Contains markup for inline keyboards with language
+	Start                 map[string]string                               //Sent on /start command
+	Help                  map[string]string                               //Sent on /help command
+	Lang                  map[string]string                               //Sent when prompting user to choose the language
+	Level                 map[string]string                               //Sent when prompting user to choose language level (e.g. A1)
+	PreferencesSet        map[string]string                               //Sent after user finishes set up
+	UnknownCommand        map[string]string                               //Sent when receiving unknown command
+	ResponseMsg           map[string]string                               //Sent when sending generated sentences to the user
+	TooLong               map[string]string                               //Sent when message exceeds maxMessageLen set in bot.go
+	BadRequest            map[string]string                               //Sent when unable to make sentences due to word being inappropriate or not existing
+	Premium               map[string]string                               //Sent when user uses /premium command if they don't have premium yet
+	LimitReached          map[string]func(int, time.Duration) string      //Sent when user reaches daily limit of free sentences, formatted with the limit and time until reset
+	PremiumTitle          map[string]string                               //Text of the inline button opening premium plans
+	PlanButton            map[string]func(int, int) string                //Inline button choosing premium plan, formatted with its days (zero for lifetime) and price
+	PlanTitle             map[string]func(int) string                     //Title of the invoice, formatted with days of the plan (zero for lifetime)
+	SuccessfulPayment     map[string]func(int) string                     //Sent when payment is successful, formatted with days of the plan (zero for lifetime)
+	FailedPayment         map[string]string                               //Sent when payment has failed
+	SubscribeButton       map[string]func(int) string                     //Inline button opening invoice of the monthly subscription, formatted with its price
+	SubscriptionTitle     map[string]string                               //Title of the subscription invoice
+	SubscriptionStarted   map[string]string                               //Sent after the first payment of the subscription, formatted with the renewal date
+	SubscriptionRenewed   map[string]string                               //Sent after the subscription is renewed, formatted with the next renewal date
+	SubscriptionActive    map[string]string                               //Sent on /premium command to subscribers, formatted with the renewal date
+	SubscriptionCanceled  map[string]string                               //Sent on /premium command to subscribers who canceled, formatted with the date the subscription ends
+	SubscriptionEnded     map[string]string                               //Sent when canceling subscription that has already ended
+	CancelSubscription    map[string]string                               //Button canceling the subscription
+	ResumeSubscription    map[string]string                               //Button resuming canceled subscription
+	RedeemUsage           map[string]string                               //Sent on /redeem command without a code
+	PromoNotFound         map[string]string                               //Sent when redeeming promo code that does not exist or has expired
+	PromoUsedUp           map[string]string                               //Sent when redeeming promo code that has no uses left
+	PromoRedeemed         map[string]string                               //Sent when redeeming promo code the user has already redeemed
+	PromoApplied          map[string]func(int) string                     //Sent after redeeming promo code, formatted with the days of premium it granted
+	PromoUsage            map[string]string                               //Sent to admin on /promo command with invalid arguments
+	PromoExists           map[string]string                               //Sent to admin when creating promo code that already exists
+	PromoCreated          map[string]string                               //Sent to admin after creating promo code, formatted with the code
+	GiftUsage             map[string]string                               //Sent on /gift command without arguments, formatted with user's gift link
+	GiftRecipientNotFound map[string]string                               //Sent when the user premium is gifted to does not use the bot
+	GiftPlans             map[string]string                               //Sent with the plans that can be gifted, formatted with the recipient
+	GiftSent              map[string]string                               //Sent to the payer after the gift is paid, formatted with the recipient
+	GiftReceived          map[string]func(int, string) string             //Sent to the recipient of the gift, formatted with days of the plan (zero for lifetime) and the payer
+	QuotaBonus            map[string]string                               //Added to the quota message when user has bonus sentences, formatted with their amount
+	ReferralBonus         map[string]func(int, int) string                //Description of the referral bonus, formatted with bonus sentences and days of premium
+	Invite                map[string]string                               //Sent on /invite command, formatted with the bonus, invite link and amount of invited users
+	ReferralRewarded      map[string]string                               //Sent to the invited user after their first sentence, formatted with the bonus
+	ReferrerRewarded      map[string]string                               //Sent to the referrer after the first sentence of the invited user, formatted with the bonus
+	PremiumExpiring       map[string]func(int, string) string             //Reminder sent before premium ends, formatted with days left and the date it ends
+	PremiumEnded          map[string]string                               //Sent when premium ends
+	RenewPremium          map[string]string                               //Inline button of the premium reminders opening premium plans
+	TrialExpiring         map[string]func(int, string) string             //Reminder sent before the premium trial ends, formatted with days left and the date it ends
+	TrialEnded            map[string]string                               //Sent when the premium trial ends
+	BuyPremium            map[string]string                               //Inline button of the trial reminders opening premium plans
+	ButtonOutdated        map[string]string                               //Toast shown when user presses a button that is no longer supported
+	Apology               map[string]string                               //Sent when an unexpected error occurs while processing user's update
+	Banned                map[string]string                               //Sent when banned user uses the bot, contains date and time the ban ends
+	Throttled             map[string]string                               //Sent at most once a minute when user sends updates too fast
+	InvoiceOutdated       map[string]string                               //Shown when paying invoice for a plan that is no longer sold or has a different price
+	PaymentUserNotFound   map[string]string                               //Shown when paying invoice before using /start
+	PaymentBanned         map[string]string                               //Shown when banned user tries to pay
+	PreferencesNotSet     map[string]string                               //Sent when user tries to generate sentences without setting the preferences
+	LifetimePremium       map[string]string                               //Sent when user with lifetime premium tries to buy premium
+	AlreadyPremium        map[string]func(int, bool) string               //Sent when premium user tries to buy premium, formatted with days left and whether user is on trial
+	TrialButton           map[string]func(int) string                     //Inline button starting premium trial, formatted with its days
+	TrialStarted          map[string]func(int, string) string             //Sent when premium trial starts, formatted with its days and the date it ends
+	TrialUnavailable      map[string]string                               //Sent when user who has used the trial or had premium tries to start it
+	PremiumDescription    map[string]string                               //Sent in the description of the invoice
+	LanguageMarkup        map[string]*models.InlineKeyboardMarkup         //Contains markup for inline keyboards with language, callback data of the buttons are language codes
+	NothingToExport       map[string]string                               //Sent on /export command when user has not generated any sentences yet
+	Export                map[string]string                               //Caption of the exported Anki deck
+	DeckName              map[string]string                               //Name of the exported Anki deck
+	HistoryEmpty          map[string]string                               //Sent on /history command when user has not generated any sentences yet
+	HistoryTitle          map[string]string                               //Title of the history page, formatted with the page number
+	RegenerateButton      map[string]string                               //Button generating a different sentence instead of the current one
+	SlowAudioButton       map[string]string                               //Button sending slowed down audio of the sentence
+	MoreExamplesButton    map[string]string                               //Button generating one more sentence for the same word
+	RegenerateLimit       map[string]string                               //Sent when the sentence was regenerated too many times, formatted with the limit
+	Timezone              map[string]string                               //Sent when prompting user to choose timezone
+	Quota                 map[string]func(int, int, time.Duration) string //Sent on /quota command with remaining and daily free sentences and time until reset
+	QuotaPremium          map[string]string                               //Sent on /quota command to premium users
+	Refunded              map[string]string                               //Sent to the user when their payment is refunded, formatted with the amount of stars
+	RefundUsage           map[string]string                               //Sent to admin on /refund command with invalid arguments
+	RefundNotFound        map[string]string                               //Sent to admin when the payment to refund does not exist or belongs to another user
+	RefundAlreadyRefunded map[string]string                               //Sent to admin when the payment has already been refunded
+	RefundFailed          map[string]string                               //Sent to admin when telegram could not refund the payment, formatted with the error
+	RefundNotRecorded     map[string]string                               //Sent to admin when the stars were returned but the refund was not recorded, formatted with the charge id
+	RefundDone            map[string]string                               //Sent to admin after the refund, formatted with the amount of stars and user's chat id
+	Busy                  map[string]string                               //Sent when user sends too many messages while previous ones are being processed
+	CardDeleted           map[string]string                               //Sent when pressing a button of the sentence deleted from the history
+	ReviewFront           map[string]string                               //Card under review before the sentence is revealed, formatted with the translation
+	ReviewShow            map[string]string                               //Button revealing the sentence of the card under review
+	ReviewGrades          map[string][]string                             //Again, Hard, Good and Easy grade buttons
+	ReviewNext            map[string]string                               //Sent after grading the card, formatted with the next review date
+	ReviewEmpty           map[string]string                               //Sent on /review command when there are no cards to review
+	ReviewDone            map[string]string                               //Sent when all due cards have been reviewed
 }
 
 // Load returns a Message object with all the message in russian and english
@@ -47,12 +122,26 @@ Happy learning! 📚✨`,
 ✅ /preferences – Выберите язык и уровень сложности для персонализированных предложений.  
 ✅ /help – Посмотреть список команд и их описание.  
 ✅ /premium – Получите неограниченную генерацию предложений.  
+✅ /export – Скачайте все ваши предложения в виде колоды Anki.  
+✅ /history – Посмотрите и удалите ранее созданные предложения.  
+✅ /review – Повторите слова с помощью интервальных повторений.  
+✅ /quota – Узнайте, сколько бесплатных предложений у вас осталось.  
+✅ /redeem – Активируйте промокод.  
+✅ /gift – Подарите Premium другу.  
+✅ /invite – Пригласите друзей и получите бонус.  
 Нужна помощь? Напишите мне – @dafraer`,
 		"en": `
 📌 Available Commands:
 ✅ /preferences – Set your language and difficulty level for personalized sentences.  
 ✅ /help – View this list of commands and their explanations.  
 ✅ /premium – Get unlimited sentence generation.
+✅ /export – Download all your sentences as an Anki deck.
+✅ /history – Browse and delete your previous sentences.
+✅ /review – Practice your words with spaced repetition.
+✅ /quota – Check how many free sentences you have left.
+✅ /redeem – Redeem a promo code.
+✅ /gift – Gift Premium to a friend.
+✅ /invite – Invite friends and get a bonus.
 Need help? Just send me a message – @dafraer`,
 	}
 	msgs.Lang = map[string]string{
@@ -92,53 +181,349 @@ Please note that AI may occasionally make inaccuracies and mistakes.`,
 	}
 	msgs.Premium = map[string]string{
 		"ru": `
-Перейдите на Premium и получите 30 дней безлимитного доступа!
+Перейдите на Premium и получите безлимитный доступ!
 Генерируйте неограниченное количество предложений и поддержите разработчика, покрывая расходы на API. 💙
-Оформите подписку сейчас и улучшите процесс обучения! ✨`,
+Выберите план и улучшите процесс обучения! ✨`,
 		"en": `
-Go Premium for 30 Days of Unlimited Access!
+Go Premium for Unlimited Access!
 Generate unlimited sentences and support the creator by covering API costs. 💙
-Upgrade now and enhance your learning experience! ✨`,
+Choose a plan and enhance your learning experience! ✨`,
 	}
-	msgs.LimitReached = map[string]string{
-		"ru": `
+	msgs.LimitReached = map[string]func(int, time.Duration) string{
+		"ru": func(limit int, untilReset time.Duration) string {
+			return fmt.Sprintf(`
 🚨Дневной лимит исчерпан!🚨
-Вы использовали 50 бесплатных предложений. Хотите безлимитный доступ? 
-Оформите Premium, чтобы продолжать обучение и поддержать бота! 💙`,
-		"en": `
+Вы использовали %d %s. Новые появятся через %s. Хотите безлимитный доступ? 
+Оформите Premium, чтобы продолжать обучение и поддержать бота! 💙`, limit, conjugateFreeSentencesRu(limit), formatDurationRu(untilReset))
+		},
+		"en": func(limit int, untilReset time.Duration) string {
+			return fmt.Sprintf(`
 🚨Daily Limit Reached!🚨
-You've used all 50 free sentences for today. Want unlimited access? 
-Upgrade to Premium to keep learning and support the bot! 💙`,
+You've used all %d free sentences for today. New ones arrive in %s. Want unlimited access? 
+Upgrade to Premium to keep learning and support the bot! 💙`, limit, formatDurationEn(untilReset))
+		},
 	}
 	msgs.PremiumTitle = map[string]string{
-		"ru": "Подписка Premium - 30 дней",
-		"en": "Premium Subscription - 30 days",
+		"ru": "Подписка Premium",
+		"en": "Premium Subscription",
 	}
-	msgs.SuccessfulPayment = map[string]string{
-		"ru": `
+	msgs.PlanButton = map[string]func(int, int) string{
+		"ru": func(days, price int) string {
+			if days == 0 {
+				return fmt.Sprintf("Навсегда — %d ⭐", price)
+			}
+			return fmt.Sprintf("%d %s — %d ⭐", days, conjugateDaysRu(days), price)
+		},
+		"en": func(days, price int) string {
+			if days == 0 {
+				return fmt.Sprintf("Lifetime — %d ⭐", price)
+			}
+			return fmt.Sprintf("%d days — %d ⭐", days, price)
+		},
+	}
+	msgs.PlanTitle = map[string]func(int) string{
+		"ru": func(days int) string {
+			if days == 0 {
+				return "Подписка Premium - навсегда"
+			}
+			return fmt.Sprintf("Подписка Premium - %d %s", days, conjugateDaysRu(days))
+		},
+		"en": func(days int) string {
+			if days == 0 {
+				return "Premium Subscription - lifetime"
+			}
+			return fmt.Sprintf("Premium Subscription - %d days", days)
+		},
+	}
+	msgs.SuccessfulPayment = map[string]func(int) string{
+		"ru": func(days int) string {
+			period := "навсегда"
+			if days != 0 {
+				period = fmt.Sprintf("на %d %s", days, conjugateDaysRu(days))
+			}
+			return fmt.Sprintf(`
 ✅ Оплата успешно обработана! ✅
-Теперь у вас неограниченный доступ к боту на 30 дней. Спасибо за поддержку! Желаю вам успехов в изучении языков! 📚✨`,
-		"en": `
+Теперь у вас неограниченный доступ к боту %s. Спасибо за поддержку! Желаю вам успехов в изучении языков! 📚✨`, period)
+		},
+		"en": func(days int) string {
+			period := "forever"
+			if days != 0 {
+				period = fmt.Sprintf("for %d days", days)
+			}
+			return fmt.Sprintf(`
 ✅ Payment successfully processed! ✅
-You now have unlimited access for 30 days. Thank you for your support! Wishing you success in your language learning journey! 📚✨`,
+You now have unlimited access %s. Thank you for your support! Wishing you success in your language learning journey! 📚✨`, period)
+		},
 	}
 	msgs.FailedPayment = map[string]string{
 		"ru": "Извините, что-то пошло не так.😔 Напишите @dafraer для решения проблемы",
 		"en": "Sorry, something went wrong.😔 Write @dafraer to solve your issue",
 	}
+	msgs.SubscribeButton = map[string]func(int) string{
+		"ru": func(price int) string {
+			return fmt.Sprintf("🔁 Подписка — %d ⭐ в месяц", price)
+		},
+		"en": func(price int) string {
+			return fmt.Sprintf("🔁 Subscription — %d ⭐ per month", price)
+		},
+	}
+	msgs.SubscriptionTitle = map[string]string{
+		"ru": "Ежемесячная подписка Premium",
+		"en": "Monthly Premium Subscription",
+	}
+	msgs.SubscriptionStarted = map[string]string{
+		"ru": `
+✅ Подписка оформлена! ✅
+Теперь у вас неограниченный доступ к боту. Подписка продлится автоматически %s, отменить её можно в /premium. Спасибо за поддержку! 📚✨`,
+		"en": `
+✅ You're subscribed! ✅
+You now have unlimited access. The subscription renews automatically on %s, you can cancel it in /premium. Thank you for your support! 📚✨`,
+	}
+	msgs.SubscriptionRenewed = map[string]string{
+		"ru": "🔁 Подписка Premium продлена. Следующее продление: %s. Спасибо, что остаётесь с нами! 💙",
+		"en": "🔁 Your Premium subscription has been renewed. Next renewal: %s. Thank you for staying with us! 💙",
+	}
+	msgs.SubscriptionActive = map[string]string{
+		"ru": "У вас активна подписка Premium 🎉\nСледующее продление: %s.",
+		"en": "Your Premium subscription is active 🎉\nNext renewal: %s.",
+	}
+	msgs.SubscriptionCanceled = map[string]string{
+		"ru": "Подписка Premium отменена и не будет продлена. Premium доступ сохранится до %s.",
+		"en": "Your Premium subscription is canceled and won't be renewed. Premium access lasts until %s.",
+	}
+	msgs.SubscriptionEnded = map[string]string{
+		"ru": "Ваша подписка уже закончилась. Используйте /premium, чтобы оформить новую.",
+		"en": "Your subscription has already ended. Use /premium to get a new one.",
+	}
+	msgs.CancelSubscription = map[string]string{
+		"ru": "Отменить подписку",
+		"en": "Cancel subscription",
+	}
+	msgs.ResumeSubscription = map[string]string{
+		"ru": "Возобновить подписку",
+		"en": "Resume subscription",
+	}
+	msgs.RedeemUsage = map[string]string{
+		"ru": "Отправьте промокод после команды, например: /redeem SPRING",
+		"en": "Send the promo code after the command, e.g. /redeem SPRING",
+	}
+	msgs.PromoNotFound = map[string]string{
+		"ru": "❌ Такого промокода нет или срок его действия истёк.",
+		"en": "❌ This promo code doesn't exist or has expired.",
+	}
+	msgs.PromoUsedUp = map[string]string{
+		"ru": "❌ Этот промокод больше нельзя использовать.",
+		"en": "❌ This promo code can't be used anymore.",
+	}
+	msgs.PromoRedeemed = map[string]string{
+		"ru": "Вы уже использовали этот промокод.",
+		"en": "You've already used this promo code.",
+	}
+	msgs.PromoApplied = map[string]func(int) string{
+		"ru": func(days int) string {
+			return fmt.Sprintf("🎉 Промокод активирован! Вы получили %d %s Premium.", days, conjugateDaysRu(days))
+		},
+		"en": func(days int) string {
+			return fmt.Sprintf("🎉 Promo code applied! You've got %d days of Premium.", days)
+		},
+	}
+	msgs.PromoUsage = map[string]string{
+		"ru": "Использование: /promo <код> <дней premium> [макс. активаций, 0 - без ограничений] [дней действия, 0 - бессрочно]",
+		"en": "Usage: /promo <code> <premium days> [max uses, 0 - unlimited] [days valid, 0 - forever]",
+	}
+	msgs.PromoExists = map[string]string{
+		"ru": "Такой промокод уже существует.",
+		"en": "This promo code already exists.",
+	}
+	msgs.PromoCreated = map[string]string{
+		"ru": "Промокод %s создан.",
+		"en": "Promo code %s created.",
+	}
+	msgs.GiftUsage = map[string]string{
+		"ru": "🎁 Чтобы подарить Premium, отправьте /gift @имя_пользователя. Получатель должен пользоваться ботом.\n\nХотите Premium в подарок? Поделитесь этой ссылкой с друзьями:\n%s",
+		"en": "🎁 To gift Premium, send /gift @username. The recipient must be a user of the bot.\n\nWant Premium as a gift? Share this link with your friends:\n%s",
+	}
+	msgs.GiftRecipientNotFound = map[string]string{
+		"ru": "Этот пользователь не пользуется ботом. Попросите его сначала запустить бота командой /start.",
+		"en": "This user doesn't use the bot. Ask them to start the bot with /start command first.",
+	}
+	msgs.GiftPlans = map[string]string{
+		"ru": "🎁 Выберите план, который хотите подарить %s:",
+		"en": "🎁 Choose the plan you want to gift to %s:",
+	}
+	msgs.GiftSent = map[string]string{
+		"ru": "✅ Подарок оплачен! %s получил(а) Premium. Спасибо за поддержку! 💙",
+		"en": "✅ Gift paid! %s has got Premium. Thank you for your support! 💙",
+	}
+	msgs.GiftReceived = map[string]func(int, string) string{
+		"ru": func(days int, from string) string {
+			period := "навсегда"
+			if days != 0 {
+				period = fmt.Sprintf("на %d %s", days, conjugateDaysRu(days))
+			}
+			return fmt.Sprintf("🎁 %s подарил(а) вам Premium %s! Наслаждайтесь неограниченной генерацией предложений! ✨", from, period)
+		},
+		"en": func(days int, from string) string {
+			period := "forever"
+			if days != 0 {
+				period = fmt.Sprintf("for %d days", days)
+			}
+			return fmt.Sprintf("🎁 %s has gifted you Premium %s! Enjoy unlimited sentence generation! ✨", from, period)
+		},
+	}
+	msgs.QuotaBonus = map[string]string{
+		"ru": "🎁 Бонусных предложений: %d. Они используются, когда заканчиваются ежедневные, и не сгорают.",
+		"en": "🎁 Bonus sentences: %d. They are used when the daily ones run out and never expire.",
+	}
+	msgs.ReferralBonus = map[string]func(int, int) string{
+		"ru": func(sentences, days int) string {
+			var parts []string
+			if sentences > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", sentences, conjugateBonusSentencesRu(sentences)))
+			}
+			if days > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s Premium", days, conjugateDaysRu(days)))
+			}
+			return strings.Join(parts, " и ")
+		},
+		"en": func(sentences, days int) string {
+			var parts []string
+			if sentences > 0 {
+				parts = append(parts, fmt.Sprintf("%d bonus sentences", sentences))
+			}
+			if days > 0 {
+				parts = append(parts, fmt.Sprintf("%d days of Premium", days))
+			}
+			return strings.Join(parts, " and ")
+		},
+	}
+	msgs.Invite = map[string]string{
+		"ru": "🤝 Приглашайте друзей! Когда друг создаст своё первое предложение, вы оба получите %s.\n\nВаша ссылка:\n%s\n\nПриглашено друзей: %d",
+		"en": "🤝 Invite your friends! When a friend generates their first sentence, you both get %s.\n\nYour link:\n%s\n\nFriends invited: %d",
+	}
+	msgs.ReferralRewarded = map[string]string{
+		"ru": "🎁 Спасибо, что пришли по приглашению! Вы получили %s.",
+		"en": "🎁 Thanks for joining by invitation! You've got %s.",
+	}
+	msgs.ReferrerRewarded = map[string]string{
+		"ru": "🎉 Ваш друг создал первое предложение! Вы получили %s.",
+		"en": "🎉 Your friend has generated their first sentence! You've got %s.",
+	}
+	msgs.PremiumExpiring = map[string]func(int, string) string{
+		"ru": func(days int, date string) string {
+			return fmt.Sprintf("⏳ Ваш Premium закончится через %d %s, %s. Продлите его, чтобы и дальше создавать предложения без ограничений!", days, conjugateDaysRu(days), date)
+		},
+		"en": func(days int, date string) string {
+			if days == 1 {
+				return fmt.Sprintf("⏳ Your Premium ends in 1 day, on %s. Renew it to keep generating unlimited sentences!", date)
+			}
+			return fmt.Sprintf("⏳ Your Premium ends in %d days, on %s. Renew it to keep generating unlimited sentences!", days, date)
+		},
+	}
+	msgs.PremiumEnded = map[string]string{
+		"ru": "Ваш Premium закончился. Спасибо, что поддерживали бота! 💙 Продлите Premium, чтобы снова создавать предложения без ограничений.",
+		"en": "Your Premium has ended. Thank you for supporting the bot! 💙 Renew Premium to generate unlimited sentences again.",
+	}
+	msgs.RenewPremium = map[string]string{
+		"ru": "Продлить Premium",
+		"en": "Renew Premium",
+	}
+	msgs.TrialExpiring = map[string]func(int, string) string{
+		"ru": func(days int, date string) string {
+			return fmt.Sprintf("⏳ Ваш пробный Premium закончится через %d %s, %s. Выберите тариф, чтобы и дальше создавать предложения без ограничений!", days, conjugateDaysRu(days), date)
+		},
+		"en": func(days int, date string) string {
+			if days == 1 {
+				return fmt.Sprintf("⏳ Your Premium trial ends in 1 day, on %s. Choose a plan to keep generating unlimited sentences!", date)
+			}
+			return fmt.Sprintf("⏳ Your Premium trial ends in %d days, on %s. Choose a plan to keep generating unlimited sentences!", days, date)
+		},
+	}
+	msgs.TrialEnded = map[string]string{
+		"ru": "Ваш пробный Premium закончился. Надеемся, он вам понравился! 💙 Выберите тариф, чтобы снова создавать предложения без ограничений.",
+		"en": "Your Premium trial has ended. We hope you enjoyed it! 💙 Choose a plan to generate unlimited sentences again.",
+	}
+	msgs.BuyPremium = map[string]string{
+		"ru": "Купить Premium",
+		"en": "Get Premium",
+	}
+	msgs.ButtonOutdated = map[string]string{
+		"ru": "Эта кнопка устарела. Пожалуйста, воспользуйтесь командой ещё раз.",
+		"en": "This button is outdated. Please use the command again.",
+	}
+	msgs.Apology = map[string]string{
+		"ru": "Извините, при обработке вашего запроса произошла ошибка.😔 Пожалуйста, попробуйте ещё раз позже.",
+		"en": "Sorry, something went wrong while processing your request.😔 Please try again later.",
+	}
+	msgs.Banned = map[string]string{
+		"ru": "🚫 Вам запрещено пользоваться ботом до %s.",
+		"en": "🚫 You are not allowed to use the bot until %s.",
+	}
+	msgs.Throttled = map[string]string{
+		"ru": "⏳ Вы отправляете сообщения слишком часто. Пожалуйста, подождите минуту и попробуйте снова.",
+		"en": "⏳ You are sending messages too fast. Please wait a minute and try again.",
+	}
+	msgs.InvoiceOutdated = map[string]string{
+		"ru": "Этот счёт устарел. Используйте /premium, чтобы получить новый.",
+		"en": "This invoice is outdated. Use /premium to get a new one.",
+	}
+	msgs.PaymentUserNotFound = map[string]string{
+		"ru": "Сначала запустите бота командой /start.",
+		"en": "Start the bot with /start command first.",
+	}
+	msgs.PaymentBanned = map[string]string{
+		"ru": "Ваш доступ к боту ограничен, оплата невозможна. Напишите @dafraer, если считаете это ошибкой.",
+		"en": "Your access to the bot is restricted, so the payment can't be accepted. Write @dafraer if you think this is a mistake.",
+	}
 	msgs.PreferencesNotSet = map[string]string{
 		"ru": "⚙️Сначала настройте бота используя команду /preferences! Без этого бот не будет работать.",
 		"en": "⚙️Set your preferences using /preferences command first! The bot won’t work until you do.",
 	}
-	msgs.AlreadyPremium = map[string]func(int) string{
+	msgs.LifetimePremium = map[string]string{
+		"ru": `
+Ваш Premium доступ бессрочный!🎉
+Спасибо за поддержку бота! 💙
+Наслаждайтесь неограниченной генерацией предложений!`,
+		"en": `
+You have lifetime Premium access!🎉
+Thank you for supporting the bot! 💙
+Enjoy your unlimited sentence generation!`,
+	}
+	msgs.AlreadyPremium = map[string]func(int, bool) string{
 		"ru": conjugateAlreadyPremiumMessageRu,
-		"en": func(n int) string {
+		"en": func(n int, trial bool) string {
+			if trial {
+				return fmt.Sprintf(`
+You're on a Premium trial!🎉
+Your trial ends in %d days. Enjoy your unlimited sentence generation!
+Choose a plan below to keep Premium after the trial 💙`, n)
+			}
 			return fmt.Sprintf(`
 You're already a Premium user!🎉
 You currently have %d days of Premium access left. Thank you for supporting the bot! 💙  
 Enjoy your unlimited sentence generation!`, n)
 		},
 	}
+	msgs.TrialButton = map[string]func(int) string{
+		"ru": func(days int) string {
+			return fmt.Sprintf("🎁 Попробовать бесплатно — %d %s", days, conjugateDaysRu(days))
+		},
+		"en": func(days int) string {
+			return fmt.Sprintf("🎁 Try free for %d days", days)
+		},
+	}
+	msgs.TrialStarted = map[string]func(int, string) string{
+		"ru": func(days int, date string) string {
+			return fmt.Sprintf("🎉 Пробный Premium на %d %s активирован! Создавайте предложения без ограничений до %s.", days, conjugateDaysRu(days), date)
+		},
+		"en": func(days int, date string) string {
+			return fmt.Sprintf("🎉 Your %d-day Premium trial has started! Generate unlimited sentences until %s.", days, date)
+		},
+	}
+	msgs.TrialUnavailable = map[string]string{
+		"ru": "Пробный период доступен только один раз и только тем, кто ещё не покупал Premium. Выберите тариф с помощью /premium.",
+		"en": "The trial is available only once and only to users who have never bought Premium. Choose a plan with /premium.",
+	}
 	msgs.PremiumDescription = map[string]string{
 		"ru": "Откройте неограниченную генерацию предложений",
 		"en": "Unlock unlimited sentence generation",
@@ -203,15 +588,133 @@ Enjoy your unlimited sentence generation!`, n)
 			},
 		},
 	}
+	msgs.NothingToExport = map[string]string{
+		"ru": "У вас пока нет предложений для экспорта. Отправьте мне слово, чтобы создать первое!",
+		"en": "You don't have any sentences to export yet. Send me a word to create the first one!",
+	}
+	msgs.Export = map[string]string{
+		"ru": "📦 Ваша колода Anki готова! Откройте файл в Anki, чтобы импортировать карточки.",
+		"en": "📦 Your Anki deck is ready! Open the file in Anki to import the cards.",
+	}
+	msgs.HistoryEmpty = map[string]string{
+		"ru": "История пуста. Отправьте мне слово, чтобы создать первое предложение!",
+		"en": "Your history is empty. Send me a word to create the first sentence!",
+	}
+	msgs.HistoryTitle = map[string]string{
+		"ru": "📜 Ваши предложения (страница %d):",
+		"en": "📜 Your sentences (page %d):",
+	}
+	msgs.RegenerateButton = map[string]string{
+		"ru": "🔄 Другое",
+		"en": "🔄 Regenerate",
+	}
+	msgs.SlowAudioButton = map[string]string{
+		"ru": "🐢 Медленно",
+		"en": "🐢 Slow audio",
+	}
+	msgs.MoreExamplesButton = map[string]string{
+		"ru": "➕ Ещё пример",
+		"en": "➕ More examples",
+	}
+	msgs.RegenerateLimit = map[string]string{
+		"ru": "Предложение можно заменить бесплатно не более %d раз. Отправьте слово ещё раз или нажмите «Ещё пример», чтобы получить новое предложение.",
+		"en": "A sentence can be regenerated for free at most %d times. Send the word again or press \"More examples\" to get a new sentence.",
+	}
+	msgs.Timezone = map[string]string{
+		"ru": "🕒 Выберите ваш часовой пояс, чтобы бесплатные предложения обновлялись по вашему времени!",
+		"en": "🕒 Please choose your timezone so your free sentences are renewed on your local time!",
+	}
+	msgs.Quota = map[string]func(int, int, time.Duration) string{
+		"ru": func(remaining, limit int, untilReset time.Duration) string {
+			return fmt.Sprintf("📊 Осталось бесплатных предложений: %d из %d.\nОбновление через %s.", remaining, limit, formatDurationRu(untilReset))
+		},
+		"en": func(remaining, limit int, untilReset time.Duration) string {
+			return fmt.Sprintf("📊 Free sentences left: %d of %d.\nThey are renewed in %s.", remaining, limit, formatDurationEn(untilReset))
+		},
+	}
+	msgs.QuotaPremium = map[string]string{
+		"ru": "💎 У вас Premium — генерация предложений не ограничена!",
+		"en": "💎 You have Premium — sentence generation is unlimited!",
+	}
+	msgs.Refunded = map[string]string{
+		"ru": "💸 Ваш платёж на %d ⭐ возвращён. Дни Premium, полученные за него, отменены.",
+		"en": "💸 Your payment of %d ⭐ has been refunded. The Premium days it granted have been removed.",
+	}
+	msgs.RefundUsage = map[string]string{
+		"ru": "Использование: /refund <chat id пользователя> <telegram_payment_charge_id>",
+		"en": "Usage: /refund <user chat id> <telegram_payment_charge_id>",
+	}
+	msgs.RefundNotFound = map[string]string{
+		"ru": "Платёж этого пользователя с таким charge id не найден.",
+		"en": "No payment of this user with such charge id was found.",
+	}
+	msgs.RefundAlreadyRefunded = map[string]string{
+		"ru": "Этот платёж уже возвращён.",
+		"en": "This payment has already been refunded.",
+	}
+	msgs.RefundFailed = map[string]string{
+		"ru": "Telegram не смог вернуть платёж: %v",
+		"en": "Telegram could not refund the payment: %v",
+	}
+	msgs.RefundNotRecorded = map[string]string{
+		"ru": "Звёзды возвращены, но не удалось записать возврат, Premium не отозван. Идентификатор платежа: %s",
+		"en": "The stars were returned, but the refund could not be recorded and Premium was not taken back. Charge id: %s",
+	}
+	msgs.RefundDone = map[string]string{
+		"ru": "✅ %d ⭐ возвращены пользователю %d.",
+		"en": "✅ %d ⭐ refunded to user %d.",
+	}
+	msgs.Busy = map[string]string{
+		"ru": "⏳ Я ещё обрабатываю ваши предыдущие сообщения. Пожалуйста, попробуйте ещё раз чуть позже.",
+		"en": "⏳ I'm still working on your previous messages. Please try again in a moment.",
+	}
+	msgs.CardDeleted = map[string]string{
+		"ru": "Это предложение было удалено из истории.",
+		"en": "This sentence has been deleted from your history.",
+	}
+	msgs.ReviewFront = map[string]string{
+		"ru": "🔁 Вспомните предложение:\n%s",
+		"en": "🔁 Recall the sentence:\n%s",
+	}
+	msgs.ReviewShow = map[string]string{
+		"ru": "Показать предложение",
+		"en": "Show sentence",
+	}
+	msgs.ReviewGrades = map[string][]string{
+		"ru": {"Снова", "Трудно", "Хорошо", "Легко"},
+		"en": {"Again", "Hard", "Good", "Easy"},
+	}
+	msgs.ReviewNext = map[string]string{
+		"ru": "Следующее повторение: %s (UTC)",
+		"en": "Next review: %s (UTC)",
+	}
+	msgs.ReviewEmpty = map[string]string{
+		"ru": "Сейчас нечего повторять. Отправьте мне новые слова или возвращайтесь позже!",
+		"en": "Nothing to review right now. Send me new words or come back later!",
+	}
+	msgs.ReviewDone = map[string]string{
+		"ru": "🎉 Вы повторили все карточки! Возвращайтесь позже.",
+		"en": "🎉 You've reviewed all due cards! Come back later.",
+	}
+	msgs.DeckName = map[string]string{
+		"ru": "WordBuddy",
+		"en": "WordBuddy",
+	}
 	return &msgs
 }
 
 // conjugateAlreadyPremiumMessageRu returns AlreadyPremium message with conjugated дни word
-func conjugateAlreadyPremiumMessageRu(daysAmount int) string {
+func conjugateAlreadyPremiumMessageRu(daysAmount int, trial bool) string {
 	msg := `
 Вы уже Premium пользователь!🎉  
 У вас %s %d %s доступа к Premium. Спасибо за поддержку! 💙  
 Наслаждайтесь неограниченной генерацией предложений!`
+	if trial {
+		msg = `
+У вас пробный Premium!🎉  
+До конца пробного периода %s %d %s. Наслаждайтесь неограниченной генерацией предложений!  
+Выберите тариф ниже, чтобы сохранить Premium после пробного периода 💙`
+	}
 	d := "дней"
 	left := "осталось"
 	if daysAmount%10 == 1 {
@@ -227,3 +730,63 @@ func conjugateAlreadyPremiumMessageRu(daysAmount int) string {
 	}
 	return fmt.Sprintf(msg, left, daysAmount, d)
 }
+
+// conjugateDaysRu returns "дней" conjugated for the amount
+func conjugateDaysRu(amount int) string {
+	switch {
+	case amount%100 >= 11 && amount%100 <= 14:
+		return "дней"
+	case amount%10 == 1:
+		return "день"
+	case amount%10 >= 2 && amount%10 <= 4:
+		return "дня"
+	default:
+		return "дней"
+	}
+}
+
+// conjugateBonusSentencesRu returns "бонусных предложений" conjugated for the amount
+func conjugateBonusSentencesRu(amount int) string {
+	switch {
+	case amount%100 >= 11 && amount%100 <= 14:
+		return "бонусных предложений"
+	case amount%10 == 1:
+		return "бонусное предложение"
+	case amount%10 >= 2 && amount%10 <= 4:
+		return "бонусных предложения"
+	default:
+		return "бонусных предложений"
+	}
+}
+
+// conjugateFreeSentencesRu returns "бесплатных предложений" conjugated for the amount
+func conjugateFreeSentencesRu(amount int) string {
+	switch {
+	case amount%100 >= 11 && amount%100 <= 14:
+		return "бесплатных предложений"
+	case amount%10 == 1:
+		return "бесплатное предложение"
+	case amount%10 >= 2 && amount%10 <= 4:
+		return "бесплатных предложения"
+	default:
+		return "бесплатных предложений"
+	}
+}
+
+// formatDurationRu formats duration rounded up to minutes, e.g. "5 ч 3 мин"
+func formatDurationRu(d time.Duration) string {
+	hours, minutes := splitDuration(d)
+	return fmt.Sprintf("%d ч %d мин", hours, minutes)
+}
+
+// formatDurationEn formats duration rounded up to minutes, e.g. "5h 3m"
+func formatDurationEn(d time.Duration) string {
+	hours, minutes := splitDuration(d)
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// splitDuration returns hours and minutes of the duration rounded up to minutes
+func splitDuration(d time.Duration) (int, int) {
+	minutes := int((d + time.Minute - 1) / time.Minute)
+	return minutes / 60, minutes % 60
+}
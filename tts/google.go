@@ -0,0 +1,62 @@
+package tts
+
+import (
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"context"
+)
+
+// GoogleName is the name of Google Text-to-Speech provider
+const GoogleName = "google"
+
+// Google generates audio using Google Text-to-Speech API
+type Google struct {
+	tts *texttospeech.Client
+}
+
+// NewGoogle creates new Google Text-to-Speech provider using application default credentials
+func NewGoogle(ctx context.Context) (*Google, error) {
+	client, err := texttospeech.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Google{client}, nil
+}
+
+// Close closes Google Text-to-Speech client
+func (g *Google) Close() error {
+	return g.tts.Close()
+}
+
+// Name returns provider name
+func (g *Google) Name() string {
+	return GoogleName
+}
+
+// Synthesize generates mp3 audio using Google Text-to-Speech
+func (g *Google) Synthesize(ctx context.Context, r *Request) ([]byte, error) {
+	// Perform the text-to-speech request on the text input with the selected voice parameters and audio file type.
+	req := texttospeechpb.SynthesizeSpeechRequest{
+		// Set the text input to be synthesized.
+		Input: &texttospeechpb.SynthesisInput{
+			InputSource: &texttospeechpb.SynthesisInput_Text{Text: r.Text},
+		},
+		// Build the voice request, select the language code (e.g. "en-US") and the SSML voice gender ("neutral").
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			LanguageCode: r.LanguageCode,
+			SsmlGender:   texttospeechpb.SsmlVoiceGender_NEUTRAL,
+		},
+		// Select the type of audio file you want returned.
+		AudioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
+			SpeakingRate:  r.SpeakingRate,
+		},
+	}
+
+	//Generate speech
+	resp, err := g.tts.SynthesizeSpeech(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.AudioContent, nil
+}
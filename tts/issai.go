@@ -0,0 +1,62 @@
+package tts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// ISSAIName is the name of ISSAI provider
+	ISSAIName = "issai"
+	// ISSAIEndpoint is ISSAI tatar text-to-speech endpoint. !!! Unofficial API - might break
+	ISSAIEndpoint = "https://issai.nu.edu.kz/tatartts/?speaker=female&text="
+)
+
+// ISSAI generates tatar audio using the website of ISSAI
+type ISSAI struct {
+	endpoint string
+}
+
+// NewISSAI creates new ISSAI provider, text is appended to the endpoint as a query parameter
+func NewISSAI(endpoint string) *ISSAI {
+	return &ISSAI{endpoint: endpoint}
+}
+
+// Name returns provider name
+func (i *ISSAI) Name() string {
+	return ISSAIName
+}
+
+// Synthesize generates mp3 audio using ISSAI API. Speaking rate is not supported and is ignored
+// !!! Unofficial API - might break
+func (i *ISSAI) Synthesize(ctx context.Context, r *Request) ([]byte, error) {
+	//Create new request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.endpoint+url.QueryEscape(r.Text), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	//Make a request
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("issai request failed with status %d", resp.StatusCode)
+	}
+
+	//Get b64 from the response
+	var b64 string
+	if err := json.NewDecoder(resp.Body).Decode(&b64); err != nil {
+		return nil, err
+	}
+
+	//Return decoded mp3 and error
+	return base64.StdEncoding.DecodeString(b64)
+}
@@ -0,0 +1,48 @@
+package tts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestISSAI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("text"); got != "Сәлам, дус!" {
+			t.Errorf("text = %q", got)
+		}
+		_ = json.NewEncoder(w).Encode(base64.StdEncoding.EncodeToString([]byte("mp3")))
+	}))
+	defer srv.Close()
+
+	audio, err := NewISSAI(srv.URL+"/tatartts/?speaker=female&text=").Synthesize(context.Background(), &Request{Text: "Сәлам, дус!", LanguageCode: "tatar"})
+	if err != nil || string(audio) != "mp3" {
+		t.Fatalf("Synthesize = %q, %v", audio, err)
+	}
+}
+
+func TestISSAIErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "status", handler: func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}},
+		{name: "not base64", handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`"not base64!"`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			if _, err := NewISSAI(srv.URL+"/?text=").Synthesize(context.Background(), &Request{Text: "Сәлам"}); err == nil {
+				t.Fatal("Synthesize returned no error")
+			}
+		})
+	}
+}
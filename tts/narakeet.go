@@ -0,0 +1,65 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NarakeetName is the name of Narakeet provider
+	NarakeetName = "narakeet"
+	// NarakeetEndpoint is Narakeet text-to-speech API endpoint
+	NarakeetEndpoint = "https://api.narakeet.com/text-to-speech/mp3"
+)
+
+// Narakeet generates audio using Narakeet API. Used for georgian because Google doesn't have georgian tts
+type Narakeet struct {
+	endpoint string
+	apiKey   string
+}
+
+// NewNarakeet creates new Narakeet provider that sends requests to the endpoint
+func NewNarakeet(endpoint, apiKey string) *Narakeet {
+	return &Narakeet{endpoint: endpoint, apiKey: apiKey}
+}
+
+// Name returns provider name
+func (n *Narakeet) Name() string {
+	return NarakeetName
+}
+
+// Synthesize generates mp3 audio using Narakeet API
+func (n *Narakeet) Synthesize(ctx context.Context, r *Request) ([]byte, error) {
+	//Create new request
+	endpoint := n.endpoint
+	if r.SpeakingRate != 0 {
+		endpoint += "?voice-speed=" + strconv.FormatFloat(r.SpeakingRate, 'f', -1, 64)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(r.Text))
+	if err != nil {
+		return nil, err
+	}
+
+	//Set headers
+	req.Header.Set("x-api-key", n.apiKey)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("accept", "application/octet-stream")
+
+	//Make a request
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("narakeet request failed with status %d", resp.StatusCode)
+	}
+
+	//Get mp3 data from the request
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,53 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNarakeet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != "გამარჯობა" {
+			t.Errorf("request %s with body %q", r.Method, body)
+		}
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		_, _ = w.Write([]byte("mp3"))
+	}))
+	defer srv.Close()
+
+	audio, err := NewNarakeet(srv.URL, "key").Synthesize(context.Background(), &Request{Text: "გამარჯობა", LanguageCode: "ka-GE"})
+	if err != nil || string(audio) != "mp3" {
+		t.Fatalf("Synthesize = %q, %v", audio, err)
+	}
+}
+
+func TestNarakeetSpeakingRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("voice-speed"); got != "0.7" {
+			t.Errorf("voice-speed = %q", got)
+		}
+		_, _ = w.Write([]byte("mp3"))
+	}))
+	defer srv.Close()
+
+	if _, err := NewNarakeet(srv.URL, "key").Synthesize(context.Background(), &Request{Text: "გამარჯობა", SpeakingRate: 0.7}); err != nil {
+		t.Fatalf("error synthesizing: %v", err)
+	}
+}
+
+func TestNarakeetError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "invalid key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := NewNarakeet(srv.URL, "key").Synthesize(context.Background(), &Request{Text: "გამარჯობა"}); err == nil {
+		t.Fatal("Synthesize returned no error")
+	}
+}
@@ -1,123 +1,158 @@
 package tts
 
 import (
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
-	"net/http"
-	"net/url"
 	"strings"
 )
 
-const georgianAPIEndPoint = "https://api.narakeet.com/text-to-speech/mp3"
+// DefaultLanguage is the registry key for providers used when a language has no providers of its own
+const DefaultLanguage = "*"
 
-// !!! Unofficial API - might break
-const tatarAPIEndPoint = "https://issai.nu.edu.kz/tatartts/?speaker=female&text="
+// DefaultRoutes voices georgian with Narakeet and tatar with ISSAI because Google doesn't support them.
+// Every other language is voiced with Google
+var DefaultRoutes = map[string][]string{
+	"ka-GE":         {NarakeetName},
+	"tatar":         {ISSAIName},
+	DefaultLanguage: {GoogleName},
+}
 
-type Client struct {
-	tts    *texttospeech.Client
-	apiKey string
+// Config contains tts providers settings
+type Config struct {
+	NarakeetAPIKey string              `yaml:"narakeet_api_key"`
+	Routes         map[string][]string `yaml:"routes"` //Language code to the provider names, see DefaultRoutes
 }
 
-// New creates new tts client
-func New(ctx context.Context, apiKey string) (*Client, error) {
-	client, err := texttospeech.NewClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return &Client{client, apiKey}, nil
+// Request describes the audio that should be generated
+type Request struct {
+	Text         string
+	LanguageCode string  //e.g. en-US
+	SpeakingRate float64 //1 is the normal speed, 0.5 is twice as slow. Zero means normal speed
 }
 
-// Close closes tts client
-func (c *Client) Close() error {
-	if err := c.tts.Close(); err != nil {
-		return err
-	}
-	return nil
+// Provider is implemented by every text-to-speech service the bot can use
+type Provider interface {
+	// Name returns the name provider is referred to by in the routes
+	Name() string
+	// Synthesize generates mp3 audio for the request
+	Synthesize(ctx context.Context, req *Request) ([]byte, error)
 }
 
-// Generate generates mp3 audio based on the text and language provided
-func (c *Client) Generate(ctx context.Context, text string, languageCode string) ([]byte, error) {
-	if languageCode == "ka-GE" {
-		return c.generateGeorgian(ctx, text)
+// Registry maps language codes to the ordered list of providers that voice them.
+// Providers are tried in order until one of them succeeds
+type Registry struct {
+	routes map[string][]Provider
+}
+
+// NewRegistry creates registry from routes mapping language codes to provider names.
+// Routes under DefaultLanguage key are used for languages that are not listed
+func NewRegistry(providers []Provider, routes map[string][]string) (*Registry, error) {
+	//Index providers by their names
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
 	}
 
-	if languageCode == "tatar" {
-		return c.generateTatar(ctx, text)
+	//Resolve provider names
+	r := &Registry{routes: make(map[string][]Provider, len(routes))}
+	for languageCode, names := range routes {
+		for _, name := range names {
+			p, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tts provider %q for language %q", name, languageCode)
+			}
+			r.routes[languageCode] = append(r.routes[languageCode], p)
+		}
 	}
-	// Perform the text-to-speech request on the text input with the selected voice parameters and audio file type.
-	req := texttospeechpb.SynthesizeSpeechRequest{
-		// Set the text input to be synthesized.
-		Input: &texttospeechpb.SynthesisInput{
-			InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
-		},
-		// Build the voice request, select the language code (e.g. "en-US") and the SSML voice gender ("neutral").
-		Voice: &texttospeechpb.VoiceSelectionParams{
-			LanguageCode: languageCode,
-			SsmlGender:   texttospeechpb.SsmlVoiceGender_NEUTRAL,
-		},
-		// Select the type of audio file you want returned.
-		AudioConfig: &texttospeechpb.AudioConfig{
-			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-		},
+	return r, nil
+}
+
+// Providers returns providers for the language code in the order they should be tried
+func (r *Registry) Providers(languageCode string) []Provider {
+	if providers, ok := r.routes[languageCode]; ok {
+		return providers
 	}
+	return r.routes[DefaultLanguage]
+}
 
-	//Generate speech
-	resp, err := c.tts.SynthesizeSpeech(ctx, &req)
-	if err != nil {
-		log.Fatal(err)
+// ParseRoutes parses routes written as "ka-GE=narakeet,google;tatar=issai;*=google"
+func ParseRoutes(s string) (map[string][]string, error) {
+	routes := make(map[string][]string)
+	for _, route := range strings.Split(s, ";") {
+		if strings.TrimSpace(route) == "" {
+			continue
+		}
+		languageCode, names, ok := strings.Cut(route, "=")
+		if !ok || strings.TrimSpace(languageCode) == "" || strings.TrimSpace(names) == "" {
+			return nil, fmt.Errorf("invalid tts route %q", route)
+		}
+		for _, name := range strings.Split(names, ",") {
+			routes[strings.TrimSpace(languageCode)] = append(routes[strings.TrimSpace(languageCode)], strings.TrimSpace(name))
+		}
 	}
-	return resp.AudioContent, nil
+	return routes, nil
+}
+
+type Client struct {
+	registry *Registry
+	closers  []io.Closer
 }
 
-// Separate function for georgian because Google doesn't have georgian tts
-func (c *Client) generateGeorgian(ctx context.Context, text string) ([]byte, error) {
-	//Create new request
-	req, err := http.NewRequestWithContext(ctx, "POST", georgianAPIEndPoint, strings.NewReader(text))
+// New creates new tts client with Google, Narakeet and ISSAI providers routed according to the config
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	google, err := NewGoogle(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	//Set headers
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("Content-Type", "text/plain")
-	req.Header.Set("accept", "application/octet-stream")
-
-	//Make a request
-	resp, err := http.DefaultClient.Do(req)
+	registry, err := NewRegistry([]Provider{
+		google,
+		NewNarakeet(NarakeetEndpoint, cfg.NarakeetAPIKey),
+		NewISSAI(ISSAIEndpoint),
+	}, cfg.Routes)
 	if err != nil {
+		_ = google.Close()
 		return nil, err
 	}
+	return &Client{registry: registry, closers: []io.Closer{google}}, nil
+}
 
-	//Get mp3 data from the request
-	audioContent, err := io.ReadAll(resp.Body)
-	return audioContent, err
+// NewWithRegistry creates tts client that uses providers from the registry
+func NewWithRegistry(registry *Registry) *Client {
+	return &Client{registry: registry}
 }
 
-// !!! Unofficial API - might break
-func (c *Client) generateTatar(ctx context.Context, text string) ([]byte, error) {
-	//Create new request
-	req, err := http.NewRequestWithContext(ctx, "GET", tatarAPIEndPoint+url.QueryEscape(text), http.NoBody)
-	if err != nil {
-		return nil, err
+// Close closes tts client
+func (c *Client) Close() error {
+	var errs []error
+	for _, closer := range c.closers {
+		errs = append(errs, closer.Close())
 	}
+	return errors.Join(errs...)
+}
 
-	//Make a request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
+// Generate generates mp3 audio based on the text and language provided
+func (c *Client) Generate(ctx context.Context, text string, languageCode string) ([]byte, error) {
+	return c.Synthesize(ctx, &Request{Text: text, LanguageCode: languageCode})
+}
 
-	//Get b64 from the response
-	var b64 string
-	if err := json.NewDecoder(resp.Body).Decode(&b64); err != nil {
-		return nil, err
+// Synthesize generates mp3 audio for the request
+func (c *Client) Synthesize(ctx context.Context, req *Request) ([]byte, error) {
+	providers := c.registry.Providers(req.LanguageCode)
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no tts providers for language %q", req.LanguageCode)
 	}
 
-	//Return decoded mp3 and error
-	return base64.StdEncoding.DecodeString(b64)
+	//Try providers in order until one of them succeeds
+	var errs []error
+	for _, p := range providers {
+		audio, err := p.Synthesize(ctx, req)
+		if err == nil {
+			return audio, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return nil, errors.Join(errs...)
 }
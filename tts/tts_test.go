@@ -0,0 +1,84 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeProvider returns its audio or error and records the requests
+type fakeProvider struct {
+	name     string
+	audio    []byte
+	err      error
+	requests []Request
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Synthesize(_ context.Context, req *Request) ([]byte, error) {
+	p.requests = append(p.requests, *req)
+	return p.audio, p.err
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" ka-GE = narakeet, google ;tatar=issai;;*=google")
+	if err != nil {
+		t.Fatalf("error parsing routes: %v", err)
+	}
+	want := map[string][]string{"ka-GE": {"narakeet", "google"}, "tatar": {"issai"}, "*": {"google"}}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("routes = %v, want %v", routes, want)
+	}
+
+	for _, s := range []string{"ka-GE", "=google", "ka-GE="} {
+		if _, err := ParseRoutes(s); err == nil {
+			t.Errorf("ParseRoutes(%q) returned no error", s)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	google, narakeet := &fakeProvider{name: "google"}, &fakeProvider{name: "narakeet"}
+	r, err := NewRegistry([]Provider{google, narakeet}, map[string][]string{"ka-GE": {"narakeet", "google"}, DefaultLanguage: {"google"}})
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	if got := r.Providers("ka-GE"); len(got) != 2 || got[0] != narakeet || got[1] != google {
+		t.Errorf("providers of ka-GE = %v", got)
+	}
+	if got := r.Providers("es-ES"); len(got) != 1 || got[0] != google {
+		t.Errorf("providers of unlisted language = %v", got)
+	}
+
+	if _, err := NewRegistry([]Provider{google}, map[string][]string{"ka-GE": {"narakeet"}}); err == nil {
+		t.Error("registry with unknown provider was created")
+	}
+}
+
+func TestGenerateFallback(t *testing.T) {
+	failing := &fakeProvider{name: "narakeet", err: errors.New("quota exceeded")}
+	working := &fakeProvider{name: "google", audio: []byte("mp3")}
+	r, err := NewRegistry([]Provider{failing, working}, map[string][]string{DefaultLanguage: {"narakeet", "google"}})
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	audio, err := NewWithRegistry(r).Generate(context.Background(), "Hola", "es-ES")
+	if err != nil || string(audio) != "mp3" {
+		t.Fatalf("Generate = %q, %v", audio, err)
+	}
+	want := Request{Text: "Hola", LanguageCode: "es-ES"}
+	if len(failing.requests) != 1 || failing.requests[0] != want || len(working.requests) != 1 {
+		t.Fatalf("providers got %v and %v", failing.requests, working.requests)
+	}
+
+	//Errors of all providers are returned
+	working.err = errors.New("unavailable")
+	if _, err := NewWithRegistry(r).Generate(context.Background(), "Hola", "es-ES"); !errors.Is(err, failing.err) || !errors.Is(err, working.err) {
+		t.Fatalf("Generate returned %v", err)
+	}
+}